@@ -0,0 +1,209 @@
+package basware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator adds the credentials to an API request. NewRequest calls it
+// for every request.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// Reauthenticator is an Authenticator whose credentials can be renewed, e.g.
+// a token revoked before it expired. When a request is rejected with 401
+// Unauthorized, Invalidate is called with the request; when it returns true
+// the request is authenticated and sent once more.
+type Reauthenticator interface {
+	Authenticator
+	Invalidate(req *http.Request) bool
+}
+
+// BasicAuth authenticates with HTTP basic authentication
+type BasicAuth struct {
+	Credentials CredentialsProvider
+}
+
+func (a BasicAuth) Authenticate(req *http.Request) error {
+	credentials, err := a.Credentials.Credentials(req.Context())
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(credentials.Username, credentials.Password)
+	return nil
+}
+
+// Invalidate reads the credentials again and reports whether they changed
+// since the request was sent, e.g. when they were rotated in the meantime.
+// Requests rejected with unchanged credentials aren't sent again.
+func (a BasicAuth) Invalidate(req *http.Request) bool {
+	credentials, err := a.Credentials.Credentials(req.Context())
+	if err != nil {
+		return false
+	}
+	username, password, _ := req.BasicAuth()
+	return credentials.Username != username || credentials.Password != password
+}
+
+// OAuth2ClientCredentials authenticates with bearer tokens of the OAuth2
+// client credentials grant. Tokens are cached and renewed shortly before they
+// expire, so requests don't fail on an expiring token.
+type OAuth2ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	// Credentials provides the client id (username) and secret (password)
+	// for every token request instead of ClientID and ClientSecret
+	Credentials CredentialsProvider
+
+	// HTTP client used for the token requests; http.DefaultClient when nil
+	HTTPClient *http.Client
+
+	// Tokens are renewed when they expire within this duration; one minute
+	// when zero. Short-lived tokens are used for at least half their
+	// lifetime, whatever the duration.
+	RefreshBefore time.Duration
+
+	mu       sync.Mutex
+	token    string
+	expiry   time.Time
+	lifetime time.Duration
+}
+
+// NewOAuth2ClientCredentials returns an authenticator requesting tokens from
+// the token endpoint
+func NewOAuth2ClientCredentials(tokenURL string, clientID string, clientSecret string, scopes ...string) *OAuth2ClientCredentials {
+	return &OAuth2ClientCredentials{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+	}
+}
+
+func (a *OAuth2ClientCredentials) Authenticate(req *http.Request) error {
+	token, err := a.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Invalidate drops the cached token when the request was sent with it. A
+// token renewed by a concurrent request is kept.
+func (a *OAuth2ClientCredentials) Invalidate(req *http.Request) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if req.Header.Get("Authorization") == "Bearer "+a.token {
+		a.token = ""
+	}
+	return true
+}
+
+// Token returns the cached token or requests a new one when it (almost)
+// expired
+func (a *OAuth2ClientCredentials) Token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	refreshBefore := a.RefreshBefore
+	if refreshBefore == 0 {
+		refreshBefore = time.Minute
+	}
+	if a.lifetime > 0 && refreshBefore > a.lifetime/2 {
+		refreshBefore = a.lifetime / 2
+	}
+	if a.token != "" && (a.expiry.IsZero() || time.Now().Add(refreshBefore).Before(a.expiry)) {
+		return a.token, nil
+	}
+
+	token, expiresIn, err := a.requestToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	a.token = token
+	a.expiry = time.Time{}
+	a.lifetime = expiresIn
+	if expiresIn > 0 {
+		a.expiry = time.Now().Add(expiresIn)
+	}
+	return a.token, nil
+}
+
+// requestToken requests a token with the client credentials grant (RFC 6749
+// section 4.4)
+func (a *OAuth2ClientCredentials) requestToken(ctx context.Context) (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(a.Scopes) > 0 {
+		form.Set("scope", strings.Join(a.Scopes, " "))
+	}
+	req, err := http.NewRequest(http.MethodPost, a.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	clientID, clientSecret := a.ClientID, a.ClientSecret
+	if a.Credentials != nil {
+		credentials, err := a.Credentials.Credentials(ctx)
+		if err != nil {
+			return "", 0, err
+		}
+		clientID, clientSecret = credentials.Username, credentials.Password
+	}
+	req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+
+	httpClient := a.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	body := struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", 0, err
+	}
+	if err := json.Unmarshal(data, &body); err != nil && resp.StatusCode == http.StatusOK {
+		return "", 0, fmt.Errorf("Invalid token response: %s", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		if body.Error == "" {
+			body.Error = resp.Status
+		}
+		return "", 0, fmt.Errorf("Token request to %s failed: %s", a.TokenURL, strings.TrimSpace(body.Error+" "+body.ErrorDescription))
+	}
+	if body.AccessToken == "" {
+		return "", 0, fmt.Errorf("Expected an access_token in the token response")
+	}
+	if body.TokenType != "" && !strings.EqualFold(body.TokenType, "bearer") {
+		return "", 0, fmt.Errorf("Expected a bearer token, got %s", body.TokenType)
+	}
+	return body.AccessToken, time.Duration(body.ExpiresIn) * time.Second, nil
+}
@@ -0,0 +1,86 @@
+package basware_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	basware "github.com/tim-online/go-basware"
+	"github.com/tim-online/go-basware/baswaretest"
+)
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	server := baswaretest.NewServer()
+	defer server.Close()
+	server.Tokens = baswaretest.NewTokenEndpoint("client", "s3cr=t&")
+	client := server.NewClient()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Notifications.List(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if server.Tokens.Issued() != 1 {
+		t.Errorf("Expected the token to be cached, got %d tokens", server.Tokens.Issued())
+	}
+
+	// the rejected request is sent again, including its body
+	server.Tokens.RevokeAll()
+	post := client.Invoices.NewPostPathParams()
+	post.BumID = "bum-1"
+	if _, err := client.Invoices.Post(ctx, post, newInvoiceBody(t)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.Document(baswaretest.KindInvoices, "bum-1"); !ok || server.Tokens.Issued() != 2 {
+		t.Errorf("Expected the invoice to be sent with a new token, got %d tokens", server.Tokens.Issued())
+	}
+
+	// a token living shorter than RefreshBefore is used for half its
+	// lifetime instead of being renewed for every request
+	auth := client.Authenticator().(*basware.OAuth2ClientCredentials)
+	auth.RefreshBefore = 2 * time.Hour
+	server.Tokens.ExpiresIn = 2 * time.Second
+	server.Tokens.RevokeAll()
+	for i := 0; i < 3; i++ {
+		if _, err := client.Notifications.List(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if server.Tokens.Issued() != 3 {
+		t.Errorf("Expected the short-lived token to be cached, got %d tokens", server.Tokens.Issued())
+	}
+
+	// tokens expiring within the refresh margin are renewed
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := client.Notifications.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if server.Tokens.Issued() != 4 {
+		t.Errorf("Expected the token to be renewed, got %d tokens", server.Tokens.Issued())
+	}
+
+	auth.ClientSecret = "wrong"
+	server.Tokens.RevokeAll()
+	_, err := client.Notifications.List(ctx)
+	if err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Errorf("Expected an invalid client error, got %v", err)
+	}
+}
+
+func TestBasicAuthIsDefault(t *testing.T) {
+	server := baswaretest.NewServer()
+	defer server.Close()
+	server.Username, server.Password = "user", "secret"
+	client := server.NewClient()
+
+	if _, ok := client.Authenticator().(basware.BasicAuth); !ok {
+		t.Fatalf("Expected basic authentication, got %T", client.Authenticator())
+	}
+	client.SetPassword("wrong")
+	_, err := client.Notifications.List(context.Background())
+	if errResp, ok := err.(*basware.ErrorResponse); !ok || errResp.Response.StatusCode != 401 {
+		t.Errorf("Expected an authentication error, got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package basware
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// ibanLengths holds the IBAN length per country of the SWIFT IBAN registry
+var ibanLengths = map[string]int{
+	"AD": 24, "AE": 23, "AL": 28, "AT": 20, "AZ": 28, "BA": 20, "BE": 16,
+	"BG": 22, "BH": 22, "BI": 27, "BR": 29, "BY": 28, "CH": 21, "CR": 22,
+	"CY": 28, "CZ": 24, "DE": 22, "DJ": 27, "DK": 18, "DO": 28, "EE": 20,
+	"EG": 29, "ES": 24, "FI": 18, "FK": 18, "FO": 18, "FR": 27, "GB": 22,
+	"GE": 22, "GI": 23, "GL": 18, "GR": 27, "GT": 28, "HR": 21, "HU": 28,
+	"IE": 22, "IL": 23, "IQ": 23, "IS": 26, "IT": 27, "JO": 30, "KW": 30,
+	"KZ": 20, "LB": 28, "LC": 32, "LI": 21, "LT": 20, "LU": 20, "LV": 21,
+	"LY": 25, "MC": 27, "MD": 24, "ME": 22, "MK": 19, "MN": 20, "MR": 27,
+	"MT": 31, "MU": 30, "NI": 28, "NL": 18, "NO": 15, "OM": 23, "PK": 24,
+	"PL": 28, "PS": 29, "PT": 25, "QA": 29, "RO": 24, "RS": 22, "RU": 33,
+	"SA": 24, "SC": 31, "SD": 18, "SE": 24, "SI": 19, "SK": 24, "SM": 27,
+	"SO": 23, "ST": 25, "SV": 28, "TL": 23, "TN": 24, "TR": 26, "UA": 29,
+	"VA": 22, "VG": 24, "XK": 20, "YE": 30,
+}
+
+var (
+	ibanFormat     = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]+$`)
+	bicFormat      = regexp.MustCompile(`^[A-Z]{4}[A-Z]{2}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+	bsbFormat      = regexp.MustCompile(`^[0-9]{3}-?[0-9]{3}$`)
+	sortCodeFormat = regexp.MustCompile(`^[0-9]{2}-?[0-9]{2}-?[0-9]{2}$`)
+	ukAccountID    = regexp.MustCompile(`^[0-9]{8}$`)
+)
+
+// NormalizeIBAN returns the IBAN in its electronic format: upper case without
+// spaces
+func NormalizeIBAN(iban string) string {
+	return strings.ToUpper(strings.Replace(strings.TrimSpace(iban), " ", "", -1))
+}
+
+// FormatIBAN returns the IBAN in its print format: upper case in groups of
+// four characters
+func FormatIBAN(iban string) string {
+	iban = NormalizeIBAN(iban)
+	groups := []string{}
+	for i := 0; i < len(iban); i += 4 {
+		end := i + 4
+		if end > len(iban) {
+			end = len(iban)
+		}
+		groups = append(groups, iban[i:end])
+	}
+	return strings.Join(groups, " ")
+}
+
+// ValidateIBAN checks the length of the IBAN for its country and its ISO 7064
+// MOD 97-10 check digits. Both the electronic and the print format are
+// accepted.
+func ValidateIBAN(iban string) error {
+	n := NormalizeIBAN(iban)
+	if !ibanFormat.MatchString(n) {
+		return fmt.Errorf("Expected an IBAN, got \"%s\"", iban)
+	}
+
+	length, ok := ibanLengths[n[:2]]
+	if !ok {
+		return fmt.Errorf("Unknown IBAN country code \"%s\"", n[:2])
+	}
+	if len(n) != length {
+		return fmt.Errorf("Expected a %s IBAN of %d characters, got %d", n[:2], length, len(n))
+	}
+	if !isValidMod9710(n[4:] + n[:4]) {
+		return fmt.Errorf("Invalid check digits in IBAN \"%s\"", iban)
+	}
+	return nil
+}
+
+// ValidateBIC checks the syntax of a BIC (SWIFT code) of 8 or 11 characters
+func ValidateBIC(bic string) error {
+	if !bicFormat.MatchString(strings.ToUpper(strings.TrimSpace(bic))) {
+		return fmt.Errorf("Invalid BIC \"%s\"", bic)
+	}
+	return nil
+}
+
+// ValidateBSB checks the syntax of an Australian bank state branch number,
+// e.g. 342-085
+func ValidateBSB(bsb string) error {
+	if !bsbFormat.MatchString(strings.TrimSpace(bsb)) {
+		return fmt.Errorf("Invalid BSB \"%s\"", bsb)
+	}
+	return nil
+}
+
+// ValidateSortCode checks the syntax of a UK sort code, e.g. 40-47-84
+func ValidateSortCode(sortCode string) error {
+	if !sortCodeFormat.MatchString(strings.TrimSpace(sortCode)) {
+		return fmt.Errorf("Invalid sort code \"%s\"", sortCode)
+	}
+	return nil
+}
+
+// Validate checks the account identifiers and the financial institution of
+// the account: IBANs, the BIC and the BSB or sort code of the branch. Other
+// schemes are not checked.
+func (fa FinancialAccountItem) Validate() error {
+	var errors *multierror.Error
+	for _, f := range fa.invalidFields() {
+		errors = multierror.Append(errors, fmt.Errorf("%s: %s", f.path, f.err))
+	}
+	return errors.ErrorOrNil()
+}
+
+type fieldError struct {
+	path string
+	err  error
+}
+
+// invalidFields returns the paths of the invalid account fields relative to
+// the account
+func (fa FinancialAccountItem) invalidFields() []fieldError {
+	invalid := []fieldError{}
+	check := func(path string, err error) {
+		if err != nil {
+			invalid = append(invalid, fieldError{path: path, err: err})
+		}
+	}
+
+	branchScheme := strings.ToUpper(fa.FinancialInstitutionBranchSchemeID)
+	for i, id := range fa.Ids {
+		path := fmt.Sprintf("ids[%d].id", i)
+		switch {
+		case strings.ToUpper(id.SchemeID) == "IBAN" || (id.SchemeID == "" && looksLikeIBAN(id.ID)):
+			check(path, ValidateIBAN(id.ID))
+		case isSortCodeScheme(branchScheme) && !ukAccountID.MatchString(id.ID):
+			check(path, fmt.Errorf("Expected a UK account number of 8 digits, got \"%s\"", id.ID))
+		}
+	}
+
+	switch strings.ToUpper(fa.FinancialInstitutionIDSchemeID) {
+	case "", "BIC":
+		if fa.FinancialInstitutionID != "" {
+			check("financialInstitutionId", ValidateBIC(fa.FinancialInstitutionID))
+		}
+	}
+
+	switch {
+	case branchScheme == "BSB":
+		check("financialInstitutionBranchId", ValidateBSB(fa.FinancialInstitutionBranchID))
+	case isSortCodeScheme(branchScheme):
+		check("financialInstitutionBranchId", ValidateSortCode(fa.FinancialInstitutionBranchID))
+	}
+	return invalid
+}
+
+func isSortCodeScheme(scheme string) bool {
+	switch scheme {
+	case "SORTCODE", "SORT CODE", "GB:SORTCODE":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package basware_test
+
+import (
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+func TestValidateIBAN(t *testing.T) {
+	tests := map[string]bool{
+		"GB82WEST12345698765432":       true,
+		"DE89 3704 0044 0532 0130 00":  true,
+		"fi2112345600000785":           true,
+		"NO9386011117947":              true,
+		"NL91ABNA0417164300":           true,
+		"GB82WEST12345698765433":       false,
+		"DE89370400440532013000123":    false,
+		"XX82WEST12345698765432":       false,
+		"NL91 ABNA 0417 1643 00 extra": false,
+	}
+	for iban, valid := range tests {
+		if err := basware.ValidateIBAN(iban); (err == nil) != valid {
+			t.Errorf("%s: expected valid to be %v, got %v", iban, valid, err)
+		}
+	}
+
+	if s := basware.FormatIBAN("fi2112345600000785"); s != "FI21 1234 5600 0007 85" {
+		t.Errorf("unexpected print format %s", s)
+	}
+	if s := basware.NormalizeIBAN(" FI21 1234 5600 0007 85"); s != "FI2112345600000785" {
+		t.Errorf("unexpected electronic format %s", s)
+	}
+}
+
+func TestFinancialAccountItemValidate(t *testing.T) {
+	tests := []struct {
+		account basware.FinancialAccountItem
+		valid   bool
+	}{
+		{basware.FinancialAccountItem{Ids: []basware.ID{{ID: "FI2112345600000785", SchemeID: "IBAN"}}, FinancialInstitutionID: "NDEAFIHH"}, true},
+		{basware.FinancialAccountItem{Ids: []basware.ID{{ID: "FI2112345600000786"}}}, false},
+		{basware.FinancialAccountItem{Ids: []basware.ID{{ID: "FI2112345600000785"}}, FinancialInstitutionID: "NDEA1IHH"}, false},
+		{basware.FinancialAccountItem{Ids: []basware.ID{{ID: "12345678"}}, FinancialInstitutionBranchID: "342-085", FinancialInstitutionBranchSchemeID: "BSB"}, true},
+		{basware.FinancialAccountItem{Ids: []basware.ID{{ID: "12345678"}}, FinancialInstitutionBranchID: "3420850", FinancialInstitutionBranchSchemeID: "BSB"}, false},
+		{basware.FinancialAccountItem{Ids: []basware.ID{{ID: "31926819"}}, FinancialInstitutionBranchID: "40-47-84", FinancialInstitutionBranchSchemeID: "SORTCODE"}, true},
+		{basware.FinancialAccountItem{Ids: []basware.ID{{ID: "3192681"}}, FinancialInstitutionBranchID: "40-47-84", FinancialInstitutionBranchSchemeID: "SORTCODE"}, false},
+		{basware.FinancialAccountItem{Ids: []basware.ID{{ID: "1234.56.789", SchemeID: "BBAN"}}}, true},
+	}
+	for i, test := range tests {
+		if err := test.account.Validate(); (err == nil) != test.valid {
+			t.Errorf("%d: expected valid to be %v, got %v", i, test.valid, err)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package baswaretest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Modes of a Recorder
+const (
+	// ModeRecord sends requests to the API and records the interactions
+	ModeRecord = "record"
+
+	// ModeReplay answers requests from the cassette without network access
+	ModeReplay = "replay"
+)
+
+// Redacted replaces scrubbed values in cassettes
+const Redacted = "REDACTED"
+
+// DefaultScrubHeaders are the headers left out of cassettes
+var DefaultScrubHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+// DefaultScrubFields are the JSON fields holding personal data that are
+// redacted in cassettes. A field matches by name or by the end of its path,
+// e.g. contact.name.
+var DefaultScrubFields = []string{"contact.name", "telephone", "telefax", "electronicMail", "addressLine", "addressLine2", "locality"}
+
+// DefaultIgnoreFields are the JSON fields that change on every run and aren't
+// used to match requests
+var DefaultIgnoreFields = []string{"clientToken"}
+
+// Cassette holds recorded interactions
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request with its response
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request as stored in a cassette
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is a response as stored in a cassette
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Recorder is an http.RoundTripper that records interactions with the API to
+// a cassette file and replays them. Use it as transport of the http.Client
+// passed to basware.NewClient:
+//
+//	rec, err := baswaretest.NewRecorder("testdata/send_invoice.json", baswaretest.ModeReplay)
+//	client := basware.NewClient(&http.Client{Transport: rec}, username, password)
+//	defer rec.Close()
+//
+// Requests match a recorded interaction on method, path, query and JSON body.
+// Headers, like X-BW-REQUEST-ID, aren't matched. Every interaction is replayed
+// once, in recorded order.
+type Recorder struct {
+	// Mode of the recorder: ModeRecord or ModeReplay
+	Mode string
+
+	// Transport sending the requests in record mode, defaults to
+	// http.DefaultTransport
+	Transport http.RoundTripper
+
+	// Headers and JSON fields to redact, and JSON fields ignored when
+	// matching requests
+	ScrubHeaders []string
+	ScrubFields  []string
+	IgnoreFields []string
+
+	path string
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewRecorder returns a recorder of a cassette file. In replay mode the file
+// must exist.
+func NewRecorder(path string, mode string) (*Recorder, error) {
+	r := &Recorder{
+		Mode:         mode,
+		ScrubHeaders: DefaultScrubHeaders,
+		ScrubFields:  DefaultScrubFields,
+		IgnoreFields: DefaultIgnoreFields,
+		path:         path,
+	}
+
+	switch mode {
+	case ModeRecord:
+	case ModeReplay:
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &r.cassette); err != nil {
+			return nil, fmt.Errorf("Invalid cassette %s: %s", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	default:
+		return nil, fmt.Errorf("Invalid recorder mode \"%s\"", mode)
+	}
+	return r, nil
+}
+
+// RoundTrip records or replays a request
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := []byte{}
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	recorded := RecordedRequest{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  req.URL.RawQuery,
+		Header: r.scrubHeader(req.Header),
+		Body:   r.scrubBody(body),
+	}
+
+	if r.Mode == ModeReplay {
+		return r.replay(req, recorded)
+	}
+	return r.record(req, recorded)
+}
+
+func (r *Recorder) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	key := r.matchKey(recorded)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || r.matchKey(interaction.Request) != key {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        cloneHeader(interaction.Response.Header),
+			Body:          ioutil.NopCloser(strings.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("No unused interaction in cassette %s matches %s %s with body %s", r.path, recorded.Method, req.URL.RequestURI(), recorded.Body)
+}
+
+func (r *Recorder) record(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.scrubHeader(resp.Header),
+			Body:       r.scrubBody(body),
+		},
+	})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Close writes the cassette in record mode. In replay mode it fails when
+// recorded interactions weren't replayed.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Mode == ModeReplay {
+		unused := []string{}
+		for i, used := range r.used {
+			if !used {
+				req := r.cassette.Interactions[i].Request
+				unused = append(unused, req.Method+" "+req.Path)
+			}
+		}
+		if len(unused) > 0 {
+			return fmt.Errorf("Interactions of cassette %s weren't replayed: %s", r.path, strings.Join(unused, ", "))
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, append(data, '\n'), 0644)
+}
+
+func (r *Recorder) scrubHeader(h http.Header) http.Header {
+	h = cloneHeader(h)
+	for _, name := range r.ScrubHeaders {
+		h.Del(name)
+	}
+	return h
+}
+
+// scrubBody redacts the personal data of a JSON body. Other bodies are
+// recorded as is.
+func (r *Recorder) scrubBody(body []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+	v = mapJSON("", v, func(path string, v interface{}) (interface{}, bool) {
+		if _, ok := v.(string); ok && matchesField(path, r.ScrubFields) {
+			return Redacted, true
+		}
+		return v, true
+	})
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// matchKey returns what a request is matched on: its method, path, query and
+// normalized body without the ignored fields
+func (r *Recorder) matchKey(req RecordedRequest) string {
+	body := req.Body
+	var v interface{}
+	if err := json.Unmarshal([]byte(body), &v); err == nil {
+		v = mapJSON("", v, func(path string, v interface{}) (interface{}, bool) {
+			return v, !matchesField(path, r.IgnoreFields)
+		})
+		data, _ := json.Marshal(v)
+		body = string(data)
+	}
+	return req.Method + " " + req.Path + "?" + req.Query + " " + body
+}
+
+// mapJSON replaces the values of a decoded JSON document. Values for which f
+// returns false are dropped from their object. Paths leave out array indices.
+func mapJSON(path string, v interface{}, f func(path string, v interface{}) (interface{}, bool)) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			p := key
+			if path != "" {
+				p = path + "." + key
+			}
+			value, keep := f(p, mapJSON(p, value, f))
+			if keep {
+				v[key] = value
+			} else {
+				delete(v, key)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = mapJSON(path, value, f)
+		}
+	}
+	return v
+}
+
+func matchesField(path string, fields []string) bool {
+	for _, field := range fields {
+		if path == field || strings.HasSuffix(path, "."+field) {
+			return true
+		}
+	}
+	return false
+}
+
+func cloneHeader(h http.Header) http.Header {
+	clone := http.Header{}
+	for name, values := range h {
+		clone[name] = append([]string{}, values...)
+	}
+	return clone
+}
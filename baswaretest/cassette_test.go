@@ -0,0 +1,84 @@
+package baswaretest_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+	"github.com/tim-online/go-basware/baswaretest"
+)
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassettes", "invoice.json")
+
+	server := baswaretest.NewServer()
+	server.Username, server.Password = "user", "secret"
+	baseURL, _ := url.Parse(server.URL + "/")
+
+	newClient := func(rec *baswaretest.Recorder) *basware.Client {
+		client := basware.NewClient(&http.Client{Transport: rec}, "user", "secret")
+		client.SetBaseURL(*baseURL)
+		return client
+	}
+	run := func(client *basware.Client, body *basware.InvoicesPostRequestBody) error {
+		if err := postInvoice(client, "bum-1", body); err != nil {
+			return err
+		}
+		params := client.Invoices.NewGetPathParams()
+		params.BumID = "bum-1"
+		_, err := client.Invoices.Get(context.Background(), params)
+		return err
+	}
+
+	rec, err := baswaretest.NewRecorder(path, baswaretest.ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := newInvoice(t)
+	body.Data.AccountingCustomerParty.Contact.ElectronicMail = "jane@example.com"
+	if err := run(newClient(rec), body); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"Authorization", "jane@example.com"} {
+		if strings.Contains(string(data), s) {
+			t.Errorf("Expected %s to be scrubbed from the cassette", s)
+		}
+	}
+
+	// replay without the server, with a new client token
+	rec, err = baswaretest.NewRecorder(path, baswaretest.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body = newInvoice(t)
+	body.ClientToken = "5f0c8f4e-7f7c-4f4e-8a8b-0d5c3a0e2b9a"
+	body.Data.AccountingCustomerParty.Contact.ElectronicMail = "john@example.com"
+	client := newClient(rec)
+	if err := run(client, body); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Error(err)
+	}
+
+	// every interaction is replayed once
+	params := client.Invoices.NewGetPathParams()
+	params.BumID = "bum-1"
+	if _, err := client.Invoices.Get(context.Background(), params); err == nil || !strings.Contains(err.Error(), "No unused interaction") {
+		t.Errorf("Expected an unmatched request error, got %v", err)
+	}
+}
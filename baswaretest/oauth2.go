@@ -0,0 +1,129 @@
+package baswaretest
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// TokenPath is the path of the token endpoint of the fake, without leading
+// slash
+const TokenPath = "oauth2/token"
+
+// TokenEndpoint is a fake OAuth2 token endpoint of the client credentials
+// grant. Set it as Tokens of a Fake to require its bearer tokens, or serve
+// it on its own.
+type TokenEndpoint struct {
+	ClientID     string
+	ClientSecret string
+
+	// Lifetime of the issued tokens; one hour when zero
+	ExpiresIn time.Duration
+
+	mu     sync.Mutex
+	tokens map[string]time.Time
+	issued int
+}
+
+// NewTokenEndpoint returns a token endpoint for the client
+func NewTokenEndpoint(clientID string, clientSecret string) *TokenEndpoint {
+	return &TokenEndpoint{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		tokens:       map[string]time.Time{},
+	}
+}
+
+// Issued returns the number of tokens issued
+func (e *TokenEndpoint) Issued() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.issued
+}
+
+// Valid reports whether the token was issued and hasn't expired or been
+// revoked
+func (e *TokenEndpoint) Valid(token string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	expiry, ok := e.tokens[token]
+	return ok && time.Now().Before(expiry)
+}
+
+// RevokeAll revokes the issued tokens, so the next requests are rejected with
+// 401 Unauthorized
+func (e *TokenEndpoint) RevokeAll() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tokens = map[string]time.Time{}
+}
+
+func (e *TokenEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeTokenError(w, http.StatusMethodNotAllowed, "invalid_request", "Expected a POST request")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if r.PostForm.Get("grant_type") != "client_credentials" {
+		writeTokenError(w, http.StatusBadRequest, "unsupported_grant_type", "Expected grant_type client_credentials")
+		return
+	}
+
+	// the credentials are form encoded in the basic authentication or sent
+	// as form parameters
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != e.ClientID || clientSecret != e.ClientSecret {
+		writeTokenError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return
+	}
+
+	expiresIn := e.ExpiresIn
+	if expiresIn == 0 {
+		expiresIn = time.Hour
+	}
+	token := strings.Replace(uuid.NewV4().String(), "-", "", -1)
+	e.mu.Lock()
+	if e.tokens == nil {
+		e.tokens = map[string]time.Time{}
+	}
+	e.tokens[token] = time.Now().Add(expiresIn)
+	e.issued++
+	e.mu.Unlock()
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int64(expiresIn / time.Second),
+	})
+}
+
+// authenticate checks the bearer token of the request
+func (e *TokenEndpoint) authenticate(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	return e.Valid(strings.TrimPrefix(auth, "Bearer "))
+}
+
+// writeTokenError responds in the error format of RFC 6749 section 5.2
+func writeTokenError(w http.ResponseWriter, status int, code string, description string) {
+	writeJSON(w, status, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
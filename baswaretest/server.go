@@ -0,0 +1,462 @@
+// Package baswaretest provides an in memory fake of the Basware API for
+// testing code that uses the basware client without network access.
+package baswaretest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	basware "github.com/tim-online/go-basware"
+)
+
+// Document kinds of the fake, named after their endpoint
+const (
+	KindInvoices    = "invoices"
+	KindCreditNotes = "creditNotes"
+)
+
+// Document is a business document the fake received
+type Document struct {
+	BumID    string                          `json:"bumId"`
+	Request  basware.InvoicesPostRequestBody `json:"request"`
+	Received time.Time                       `json:"received"`
+
+	// Status is updated by delivered and failed notifications
+	Status  string    `json:"status,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// Failure makes the fake fail matching requests
+type Failure struct {
+	// Method of the requests to fail; empty matches every method
+	Method string
+
+	// Path prefix of the requests to fail without leading slash, e.g.
+	// v1/invoices; empty matches every path
+	Path string
+
+	// HTTP status to respond with, e.g. http.StatusTooManyRequests. Zero
+	// handles the request normally after the delay.
+	Status int
+
+	// Time to wait before responding; longer than the client's timeout
+	// simulates a timeout
+	Delay time.Duration
+
+	// Number of requests to fail; zero fails one
+	Count int
+}
+
+func (f Failure) matches(r *http.Request, path string) bool {
+	return (f.Method == "" || f.Method == r.Method) && strings.HasPrefix(path, f.Path)
+}
+
+// clientToken remembers the request a client token was first used with
+type clientToken struct {
+	path string
+	hash [sha256.Size]byte
+}
+
+// Fake is an http.Handler implementing the invoices, credit notes, files and
+// notifications endpoints of the Basware API in memory. Posted documents are
+// checked against the API's JSON schema and client tokens are only processed
+// once.
+type Fake struct {
+	// Credentials of the basic authentication; not checked when both are
+	// empty
+	Username string
+	Password string
+
+	// Tokens makes the fake require bearer tokens of the token endpoint
+	// instead of basic authentication. It's served at TokenPath.
+	Tokens *TokenEndpoint
+
+	mu            sync.Mutex
+	documents     map[string]map[string]Document
+	clientTokens  map[string]clientToken
+	files         map[string]basware.FilesPostRequestBody
+	notifications []basware.Notification
+	failures      []Failure
+}
+
+// NewFake returns an empty fake
+func NewFake() *Fake {
+	return &Fake{
+		documents: map[string]map[string]Document{
+			KindInvoices:    {},
+			KindCreditNotes: {},
+		},
+		clientTokens: map[string]clientToken{},
+		files:        map[string]basware.FilesPostRequestBody{},
+	}
+}
+
+// Fail scripts a failure of the next matching requests
+func (f *Fake) Fail(failure Failure) {
+	if failure.Count == 0 {
+		failure.Count = 1
+	}
+	f.mu.Lock()
+	f.failures = append(f.failures, failure)
+	f.mu.Unlock()
+}
+
+// Document returns a received invoice or credit note
+func (f *Fake) Document(kind string, bumID string) (Document, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	doc, ok := f.documents[kind][bumID]
+	return doc, ok
+}
+
+// File returns an uploaded file
+func (f *Fake) File(refID string) (basware.FilesPostRequestBody, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, ok := f.files[refID]
+	return file, ok
+}
+
+// AddNotification queues a notification for the client. An empty id and
+// creation time are filled in. Delivered and failed notifications update the
+// status of their document.
+func (f *Fake) AddNotification(n basware.Notification) basware.Notification {
+	if n.NotificationID == "" {
+		n.NotificationID = basware.NotificationID(uuid.NewV4().String())
+	}
+	if n.Created == "" {
+		n.Created = time.Now().UTC().Format(time.RFC3339)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notifications = append(f.notifications, n)
+
+	kind := KindInvoices
+	if n.DocumentType == "CREDIT_NOTE" {
+		kind = KindCreditNotes
+	}
+	doc, ok := f.documents[kind][n.BumID.String()]
+	if !ok {
+		return n
+	}
+	switch n.NotificationType {
+	case basware.NotificationTypeDocumentDelivered:
+		doc.Status = basware.DocumentStatusDelivered
+	case basware.NotificationTypeDocumentFailed:
+		doc.Status = basware.DocumentStatusFailed
+	default:
+		return n
+	}
+	doc.Updated = time.Now().UTC()
+	f.documents[kind][doc.BumID] = doc
+	return n
+}
+
+// Notifications returns the notifications that haven't been acknowledged
+func (f *Fake) Notifications() []basware.Notification {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]basware.Notification{}, f.notifications...)
+}
+
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if f.Tokens != nil && path == TokenPath {
+		f.Tokens.ServeHTTP(w, r)
+		return
+	}
+
+	if failure, ok := f.nextFailure(r, path); ok {
+		select {
+		case <-time.After(failure.Delay):
+		case <-r.Context().Done():
+			return
+		}
+		if failure.Status != 0 {
+			if failure.Status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "1")
+			}
+			writeError(w, failure.Status, "SERVER", "Error.005.0001", http.StatusText(failure.Status), nil)
+			return
+		}
+	}
+
+	if f.Tokens != nil {
+		if !f.Tokens.authenticate(r) {
+			writeError(w, http.StatusUnauthorized, "AUTHENTICATION", "Error.001.0001", "Authentication failed", nil)
+			return
+		}
+	} else if f.Username != "" || f.Password != "" {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != f.Username || password != f.Password {
+			writeError(w, http.StatusUnauthorized, "AUTHENTICATION", "Error.001.0001", "Authentication failed", nil)
+			return
+		}
+	}
+
+	parts := strings.Split(path, "/")
+	switch {
+	case len(parts) == 3 && parts[0] == "v1" && (parts[1] == KindInvoices || parts[1] == KindCreditNotes):
+		switch r.Method {
+		case http.MethodPost:
+			f.postDocument(w, r, parts[1], parts[2])
+			return
+		case http.MethodGet:
+			f.getDocument(w, parts[1], parts[2])
+			return
+		}
+	case len(parts) == 4 && parts[0] == "v1" && (parts[1] == KindInvoices || parts[1] == KindCreditNotes) && parts[3] == "status" && r.Method == http.MethodGet:
+		f.getStatus(w, parts[1], parts[2])
+		return
+	case path == "v1/files" && r.Method == http.MethodPost:
+		f.postFile(w, r)
+		return
+	case len(parts) == 3 && parts[0] == "v1" && parts[1] == "files" && r.Method == http.MethodGet:
+		f.getFile(w, parts[2])
+		return
+	case path == "v1/notifications" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, basware.NotificationsGetResponse{Notifications: f.Notifications()})
+		return
+	case len(parts) == 4 && parts[0] == "v1" && parts[1] == "notifications" && parts[3] == "acknowledge" && r.Method == http.MethodPost:
+		f.acknowledge(w, parts[2])
+		return
+	}
+	writeError(w, http.StatusNotFound, "NOT_FOUND", "Error.004.0001", "No endpoint "+r.Method+" "+r.URL.Path, nil)
+}
+
+func (f *Fake) nextFailure(r *http.Request, path string) (Failure, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, failure := range f.failures {
+		if !failure.matches(r, path) {
+			continue
+		}
+		f.failures[i].Count--
+		if f.failures[i].Count == 0 {
+			f.failures = append(f.failures[:i], f.failures[i+1:]...)
+		}
+		return failure, true
+	}
+	return Failure{}, false
+}
+
+func (f *Fake) postDocument(w http.ResponseWriter, r *http.Request, kind string, bumID string) {
+	if err := basware.BumID(bumID).Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION", "Error.004.0002", err.Error(), basware.ValidationErrors{{FieldID: "bumId", FieldMessage: err.Error()}})
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION", "Error.004.0002", err.Error(), nil)
+		return
+	}
+	// credit notes share the schema of invoices
+	if errs := basware.ValidateInvoicesPostRequestJSON(data); len(errs) > 0 {
+		writeError(w, http.StatusBadRequest, "VALIDATION", "Error.004.0002", "Required field is missing from the request sent by the API client, or a field in the request does not match the expected pattern. For example, a date is given in a false format.", errs)
+		return
+	}
+
+	request := basware.InvoicesPostRequestBody{}
+	if err := json.Unmarshal(data, &request); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION", "Error.004.0002", err.Error(), nil)
+		return
+	}
+	if request.ClientToken == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION", "Error.004.0002", "Expected a clientToken", basware.ValidationErrors{{FieldID: "clientToken", FieldMessage: "clientToken must not be empty"}})
+		return
+	}
+
+	path := kind + "/" + bumID
+	hash := sha256.Sum256(normalizeJSON(data))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if token, ok := f.clientTokens[request.ClientToken]; ok {
+		if token.path != path || token.hash != hash {
+			writeError(w, http.StatusConflict, "CONFLICT", "Error.004.0009", "The clientToken was already used for a different document", nil)
+			return
+		}
+		// a retry of a processed request
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if _, ok := f.documents[kind][bumID]; ok {
+		writeError(w, http.StatusConflict, "CONFLICT", "Error.004.0010", "A document with bumId "+bumID+" already exists", nil)
+		return
+	}
+
+	f.clientTokens[request.ClientToken] = clientToken{path: path, hash: hash}
+	now := time.Now().UTC()
+	f.documents[kind][bumID] = Document{BumID: bumID, Request: request, Received: now, Status: basware.DocumentStatusReceived, Updated: now}
+	documentType := "INVOICE"
+	if kind == KindCreditNotes {
+		documentType = "CREDIT_NOTE"
+	}
+	f.notifications = append(f.notifications, basware.Notification{
+		NotificationID:   basware.NotificationID(uuid.NewV4().String()),
+		NotificationType: basware.NotificationTypeDocumentReceived,
+		BumID:            basware.BumID(bumID),
+		DocumentType:     documentType,
+		Created:          time.Now().UTC().Format(time.RFC3339),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (f *Fake) getDocument(w http.ResponseWriter, kind string, bumID string) {
+	doc, ok := f.Document(kind, bumID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Error.004.0001", "No document with bumId "+bumID, nil)
+		return
+	}
+
+	self := "v1/" + kind + "/" + url.PathEscape(bumID)
+	links := basware.Links{
+		{Href: self, Method: http.MethodGet, Rel: basware.LinkRelSelf},
+		{Href: self + "/status", Method: http.MethodGet, Rel: basware.LinkRelStatus},
+	}
+	for _, ref := range doc.Request.FileRefs {
+		links = append(links, basware.Link{Href: "v1/files/" + url.PathEscape(ref.RefID), Method: http.MethodGet, Rel: basware.LinkRelFile})
+	}
+	if kind == KindInvoices {
+		// the credit note gets the bumId of the invoice with a suffix
+		links = append(links, basware.Link{Href: "v1/" + KindCreditNotes + "/" + url.PathEscape(bumID) + "-credit", Method: http.MethodPost, Rel: basware.LinkRelCredit})
+	}
+	writeJSON(w, http.StatusOK, basware.InvoicesGetResponse{
+		Data:     doc.Request.Data,
+		FileRefs: doc.Request.FileRefs,
+		Links:    links,
+		Version:  "1.0",
+	})
+}
+
+func (f *Fake) getStatus(w http.ResponseWriter, kind string, bumID string) {
+	doc, ok := f.Document(kind, bumID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Error.004.0001", "No document with bumId "+bumID, nil)
+		return
+	}
+	// documents of older states have no status
+	if doc.Status == "" {
+		doc.Status, doc.Updated = basware.DocumentStatusReceived, doc.Received
+	}
+	writeJSON(w, http.StatusOK, basware.DocumentStatus{
+		BumID:   basware.BumID(doc.BumID),
+		Status:  doc.Status,
+		Updated: doc.Updated.Format(time.RFC3339),
+	})
+}
+
+func (f *Fake) postFile(w http.ResponseWriter, r *http.Request) {
+	file := basware.FilesPostRequestBody{}
+	if err := json.NewDecoder(r.Body).Decode(&file); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION", "Error.004.0002", err.Error(), nil)
+		return
+	}
+	if file.FileName == "" || len(file.Content) == 0 {
+		writeError(w, http.StatusBadRequest, "VALIDATION", "Error.004.0002", "Expected a file name and content", basware.ValidationErrors{{FieldID: "fileName", FieldMessage: "fileName and content are required"}})
+		return
+	}
+
+	refID := uuid.NewV4().String()
+	f.mu.Lock()
+	f.files[refID] = file
+	f.mu.Unlock()
+	writeJSON(w, http.StatusCreated, basware.FilesPostResponseBody{RefID: refID})
+}
+
+func (f *Fake) getFile(w http.ResponseWriter, refID string) {
+	file, ok := f.File(refID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Error.004.0001", "No file with refId "+refID, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, file)
+}
+
+func (f *Fake) acknowledge(w http.ResponseWriter, notificationID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, n := range f.notifications {
+		if string(n.NotificationID) == notificationID {
+			f.notifications = append(f.notifications[:i], f.notifications[i+1:]...)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "NOT_FOUND", "Error.004.0001", "No notification "+notificationID, nil)
+}
+
+// Server is a fake running on a local test server
+type Server struct {
+	*Fake
+	*httptest.Server
+}
+
+// NewServer starts a server with an empty fake. Close it when done.
+func NewServer() *Server {
+	fake := NewFake()
+	return &Server{Fake: fake, Server: httptest.NewServer(fake)}
+}
+
+// NewClient returns a basware client using the server. With Tokens it
+// authenticates with the OAuth2 client credentials of the token endpoint.
+func (s *Server) NewClient() *basware.Client {
+	c := basware.NewClient(s.Server.Client(), s.Username, s.Password)
+	u, _ := url.Parse(s.URL + "/")
+	c.SetBaseURL(*u)
+	if s.Tokens != nil {
+		auth := basware.NewOAuth2ClientCredentials(s.URL+"/"+TokenPath, s.Tokens.ClientID, s.Tokens.ClientSecret)
+		auth.HTTPClient = s.Server.Client()
+		c.SetAuthenticator(auth)
+	}
+	return c
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError responds in the ErrorResponse format of the API
+func writeError(w http.ResponseWriter, status int, errorType string, code string, message string, validationErrors basware.ValidationErrors) {
+	if validationErrors == nil {
+		validationErrors = basware.ValidationErrors{}
+	}
+	writeJSON(w, status, basware.ErrorResponse{
+		Version: "1.0",
+		Errors: basware.Errors{
+			ValidationErrors: validationErrors,
+			Message:          message,
+			ID:               uuid.NewV4().String(),
+			Type:             errorType,
+			Info:             message,
+			Code:             code,
+		},
+	})
+}
+
+// normalizeJSON re-encodes a document so formatting and key order don't
+// matter when comparing requests
+func normalizeJSON(data []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return bytes.TrimSpace(data)
+	}
+	normalized, _ := json.Marshal(v)
+	return normalized
+}
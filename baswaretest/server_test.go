@@ -0,0 +1,224 @@
+package baswaretest_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	basware "github.com/tim-online/go-basware"
+	"github.com/tim-online/go-basware/baswaretest"
+)
+
+const invoiceJSON = `{
+	"clientToken": "8d1c7a6e-4b0a-4f6e-9a39-1a0b7f6b2c11",
+	"data": {
+		"id": "INV-1",
+		"issueDate": "2024-03-01",
+		"documentCurrencyCode": "EUR",
+		"accountingSupplierParty": {"partyName": "Seller Oy"},
+		"accountingCustomerParty": {"partyName": "Buyer Oy"},
+		"legalMonetaryTotal": {
+			"lineExtensionAmount": {"currencyId": "EUR", "amount": 100},
+			"payableAmount": {"currencyId": "EUR", "amount": 100}
+		},
+		"invoiceLine": [{
+			"id": "1",
+			"lineExtension": {"currencyId": "EUR", "amount": 100},
+			"item": {"name": "Widget"}
+		}]
+	}
+}`
+
+func newInvoice(t *testing.T) *basware.InvoicesPostRequestBody {
+	body := &basware.InvoicesPostRequestBody{}
+	if err := json.Unmarshal([]byte(invoiceJSON), body); err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func postInvoice(client *basware.Client, bumID basware.BumID, body *basware.InvoicesPostRequestBody) error {
+	params := client.Invoices.NewPostPathParams()
+	params.BumID = bumID
+	_, err := client.Invoices.Post(context.Background(), params, body)
+	return err
+}
+
+func errorResponse(t *testing.T, err error, status int) *basware.ErrorResponse {
+	errResp, ok := err.(*basware.ErrorResponse)
+	if !ok {
+		t.Fatalf("Expected an ErrorResponse, got %v", err)
+	}
+	if errResp.Response.StatusCode != status {
+		t.Fatalf("Expected status %d, got %d", status, errResp.Response.StatusCode)
+	}
+	return errResp
+}
+
+func TestServerInvoices(t *testing.T) {
+	server := baswaretest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	if err := postInvoice(client, "bum-1", newInvoice(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a retry with the same client token is accepted without side effects
+	if err := postInvoice(client, "bum-1", newInvoice(t)); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(server.Notifications()); n != 1 {
+		t.Errorf("Expected 1 notification, got %d", n)
+	}
+
+	// reusing the client token for another document conflicts
+	other := newInvoice(t)
+	other.Data.ID = "INV-2"
+	errorResponse(t, postInvoice(client, "bum-2", other), http.StatusConflict)
+
+	params := client.Invoices.NewGetPathParams()
+	params.BumID = "bum-1"
+	resp, err := client.Invoices.Get(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.ID != "INV-1" || len(resp.Links) == 0 || resp.Links[0].Rel != "self" {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+
+	params.BumID = "unknown"
+	_, err = client.Invoices.Get(context.Background(), params)
+	errorResponse(t, err, http.StatusNotFound)
+}
+
+func TestServerValidation(t *testing.T) {
+	server := baswaretest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	body := newInvoice(t)
+	body.Data.IssueDate = "01.03.2024"
+	errResp := errorResponse(t, postInvoice(client, "bum-1", body), http.StatusBadRequest)
+	if errResp.Errors.Type != "VALIDATION" || errResp.Errors.Code != "Error.004.0002" {
+		t.Errorf("Unexpected errors %+v", errResp.Errors)
+	}
+	found := false
+	for _, e := range errResp.Errors.ValidationErrors {
+		if e.FieldID == "data.issueDate" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected a validation error of data.issueDate, got %v", errResp.Errors.ValidationErrors)
+	}
+	if _, ok := server.Document(baswaretest.KindInvoices, "bum-1"); ok {
+		t.Error("Expected the invalid invoice not to be stored")
+	}
+}
+
+func TestServerFailures(t *testing.T) {
+	server := baswaretest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	server.Fail(baswaretest.Failure{Method: http.MethodPost, Path: "v1/invoices", Status: http.StatusInternalServerError})
+	errorResponse(t, postInvoice(client, "bum-1", newInvoice(t)), http.StatusInternalServerError)
+	// the failure is used up
+	if err := postInvoice(client, "bum-1", newInvoice(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Fail(baswaretest.Failure{Status: http.StatusTooManyRequests, Count: 2})
+	for i := 0; i < 2; i++ {
+		_, err := client.Notifications.List(context.Background())
+		errResp := errorResponse(t, err, http.StatusTooManyRequests)
+		if errResp.Response.Header.Get("Retry-After") == "" {
+			t.Error("Expected a Retry-After header")
+		}
+	}
+
+	server.Fail(baswaretest.Failure{Delay: time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.Notifications.List(ctx); err == nil {
+		t.Error("Expected a timeout")
+	}
+}
+
+func TestServerFilesAndNotifications(t *testing.T) {
+	server := baswaretest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	file := client.Files.NewPostRequestBodyFromPDF("invoice.pdf", []byte("%PDF-1.7"))
+	fileResp, err := client.Files.Post(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored, ok := server.File(fileResp.RefID); !ok || stored.FileName != "invoice.pdf" {
+		t.Errorf("Expected the file to be stored, got %+v", stored)
+	}
+
+	if err := postInvoice(client, "bum-1", newInvoice(t)); err != nil {
+		t.Fatal(err)
+	}
+	server.AddNotification(basware.Notification{NotificationType: basware.NotificationTypeDocumentDelivered, BumID: "bum-1"})
+
+	list, err := client.Notifications.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Notifications) != 2 || list.Notifications[0].NotificationType != basware.NotificationTypeDocumentReceived {
+		t.Fatalf("Unexpected notifications %+v", list.Notifications)
+	}
+
+	params := client.Notifications.NewAcknowledgePathParams()
+	params.NotificationID = list.Notifications[0].NotificationID
+	if err := client.Notifications.Acknowledge(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	if n := server.Notifications(); len(n) != 1 || n[0].NotificationType != basware.NotificationTypeDocumentDelivered {
+		t.Errorf("Unexpected notifications after acknowledging %+v", n)
+	}
+}
+
+func TestFakeState(t *testing.T) {
+	server := baswaretest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+	if err := postInvoice(client, "bum-1", newInvoice(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(server.State())
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := baswaretest.State{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := baswaretest.NewServer()
+	defer restored.Close()
+	if err := restored.Restore(state); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := restored.Document(baswaretest.KindInvoices, "bum-1"); !ok {
+		t.Error("Expected the invoice to be restored")
+	}
+	if n := len(restored.Notifications()); n != 1 {
+		t.Errorf("Expected 1 notification, got %d", n)
+	}
+
+	// the client token is still known
+	if err := postInvoice(restored.NewClient(), "bum-1", newInvoice(t)); err != nil {
+		t.Fatal(err)
+	}
+	other := newInvoice(t)
+	other.Data.ID = "INV-2"
+	errorResponse(t, postInvoice(restored.NewClient(), "bum-2", other), http.StatusConflict)
+}
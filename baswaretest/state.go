@@ -0,0 +1,93 @@
+package baswaretest
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+// State is a snapshot of the data of a fake, e.g. to persist it between runs
+type State struct {
+	Invoices      map[string]Document                     `json:"invoices"`
+	CreditNotes   map[string]Document                     `json:"creditNotes"`
+	Files         map[string]basware.FilesPostRequestBody `json:"files"`
+	Notifications []basware.Notification                  `json:"notifications"`
+
+	// Requests the client tokens were used for
+	ClientTokens map[string]ClientToken `json:"clientTokens"`
+}
+
+// ClientToken is the request a client token was first used with
+type ClientToken struct {
+	// Document kind and bumId
+	Path string `json:"path"`
+
+	// SHA-256 of the normalized request body, hex encoded
+	Hash string `json:"hash"`
+}
+
+// State returns a snapshot of the documents, files, notifications and client
+// tokens of the fake. Scripted failures aren't included.
+func (f *Fake) State() State {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s := State{
+		Invoices:      map[string]Document{},
+		CreditNotes:   map[string]Document{},
+		Files:         map[string]basware.FilesPostRequestBody{},
+		Notifications: append([]basware.Notification{}, f.notifications...),
+		ClientTokens:  map[string]ClientToken{},
+	}
+	for id, doc := range f.documents[KindInvoices] {
+		s.Invoices[id] = doc
+	}
+	for id, doc := range f.documents[KindCreditNotes] {
+		s.CreditNotes[id] = doc
+	}
+	for id, file := range f.files {
+		s.Files[id] = file
+	}
+	for token, ct := range f.clientTokens {
+		s.ClientTokens[token] = ClientToken{Path: ct.path, Hash: hex.EncodeToString(ct.hash[:])}
+	}
+	return s
+}
+
+// Restore replaces the data of the fake with a snapshot
+func (f *Fake) Restore(s State) error {
+	clientTokens := map[string]clientToken{}
+	for token, ct := range s.ClientTokens {
+		hash, err := hex.DecodeString(ct.Hash)
+		if err != nil || len(hash) != len(clientToken{}.hash) {
+			return fmt.Errorf("Invalid hash of client token %s", token)
+		}
+		c := clientToken{path: ct.Path}
+		copy(c.hash[:], hash)
+		clientTokens[token] = c
+	}
+
+	documents := map[string]map[string]Document{
+		KindInvoices:    {},
+		KindCreditNotes: {},
+	}
+	for id, doc := range s.Invoices {
+		documents[KindInvoices][id] = doc
+	}
+	for id, doc := range s.CreditNotes {
+		documents[KindCreditNotes][id] = doc
+	}
+	files := map[string]basware.FilesPostRequestBody{}
+	for id, file := range s.Files {
+		files[id] = file
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.documents = documents
+	f.files = files
+	f.clientTokens = clientTokens
+	f.notifications = append([]basware.Notification{}, s.Notifications...)
+	return nil
+}
@@ -0,0 +1,161 @@
+package basware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pathParam matches the parameters of a path template, e.g. {bumId}
+var pathParam = regexp.MustCompile(`{([^{}/]+)}`)
+
+// Call sends a request to an endpoint, including those the library doesn't
+// wrap yet, e.g.:
+//
+//	out := &InvoicesGetResponse{}
+//	_, err := client.Call(ctx, http.MethodGet, "v1/invoices/{bumId}", map[string]string{"bumId": id}, nil, nil, out)
+//
+// The parameters of the path template are escaped. pathParams and query can be
+// a map[string]string, url.Values or a struct, whose fields are named by their
+// json tag; empty query values are left out. The body is sent as JSON and the
+// response is decoded into out, which may be nil. Errors are returned like Do
+// returns them.
+func (c *Client) Call(ctx context.Context, method string, pathTemplate string, pathParams interface{}, query interface{}, body interface{}, out interface{}) (*http.Response, error) {
+	apiURL, err := c.endpointURL(pathTemplate, pathParams)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := encodeParams(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) > 0 {
+		q := apiURL.Query()
+		for k, vs := range values {
+			for _, v := range vs {
+				if v != "" {
+					q.Add(k, v)
+				}
+			}
+		}
+		apiURL.RawQuery = q.Encode()
+	}
+
+	httpReq, err := c.NewRequest(ctx, method, apiURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if out == nil {
+		var discard interface{}
+		out = &discard
+	}
+	return c.Do(httpReq, out)
+}
+
+// endpointURL fills in the path template and appends it to the base URL.
+// Parameters are path escaped; missing, empty and dot segment values are
+// rejected so a request can't end up at another endpoint.
+func (c *Client) endpointURL(pathTemplate string, pathParams interface{}) (url.URL, error) {
+	params, err := encodeParams(pathParams)
+	if err != nil {
+		return url.URL{}, err
+	}
+
+	var missing []string
+	path := pathParam.ReplaceAllStringFunc(pathTemplate, func(p string) string {
+		name := p[1 : len(p)-1]
+		value := ""
+		if vs := params[name]; len(vs) > 0 {
+			value = vs[0]
+		}
+		if value == "" || value == "." || value == ".." {
+			missing = append(missing, name)
+			return p
+		}
+		return url.PathEscape(value)
+	})
+	if len(missing) > 0 {
+		return url.URL{}, fmt.Errorf("Expected a value for path parameter %s of %s", strings.Join(missing, ", "), pathTemplate)
+	}
+
+	unescaped, err := url.PathUnescape(path)
+	if err != nil {
+		return url.URL{}, err
+	}
+	baseURL := c.BaseURL()
+	apiURL, err := url.Parse(baseURL.String())
+	if err != nil {
+		return url.URL{}, err
+	}
+	apiURL.RawPath = apiURL.EscapedPath() + path
+	apiURL.Path = apiURL.Path + unescaped
+	return *apiURL, nil
+}
+
+// encodeParams converts path or query parameters into values
+func encodeParams(params interface{}) (url.Values, error) {
+	switch p := params.(type) {
+	case nil:
+		return url.Values{}, nil
+	case url.Values:
+		return p, nil
+	case map[string]string:
+		values := url.Values{}
+		for k, v := range p {
+			values.Set(k, v)
+		}
+		return values, nil
+	}
+
+	v := reflect.ValueOf(params)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return url.Values{}, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Expected parameters as map, url.Values or struct, got %T", params)
+	}
+
+	// the JSON encoding names the fields like the API does
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	values := url.Values{}
+	for _, k := range keys {
+		switch f := fields[k].(type) {
+		case nil:
+		case []interface{}:
+			for _, item := range f {
+				values.Add(k, fmt.Sprint(item))
+			}
+		case float64:
+			values.Set(k, strconv.FormatFloat(f, 'f', -1, 64))
+		default:
+			values.Set(k, fmt.Sprint(f))
+		}
+	}
+	return values, nil
+}
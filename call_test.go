@@ -0,0 +1,64 @@
+package basware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+	"github.com/tim-online/go-basware/baswaretest"
+)
+
+func TestCall(t *testing.T) {
+	var requestURI string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestURI = r.RequestURI
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer server.Close()
+
+	client := basware.NewClient(nil, "user", "secret")
+	baseURL, _ := url.Parse(server.URL + "/api/")
+	client.SetBaseURL(*baseURL)
+
+	query := struct {
+		Status   string   `json:"status"`
+		Page     int      `json:"page"`
+		Types    []string `json:"types"`
+		Optional string   `json:"optional,omitempty"`
+	}{"SENT", 2, []string{"a b", "c&d"}, ""}
+	out := map[string]string{}
+	_, err := client.Call(context.Background(), http.MethodGet, "v1/documents/{bumId}/status", map[string]string{"bumId": "a/b c?"}, query, nil, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "/api/v1/documents/a%2Fb%20c%3F/status?page=2&status=SENT&types=a+b&types=c%26d"
+	if requestURI != expected {
+		t.Errorf("Expected %s, got %s", expected, requestURI)
+	}
+	if out["status"] != "ok" {
+		t.Errorf("Expected the response to be decoded, got %v", out)
+	}
+
+	for _, id := range []basware.BumID{"", ".", ".."} {
+		_, err := client.Call(context.Background(), http.MethodGet, "v1/invoices/{bumId}", &basware.InvoiceGetPathParams{BumID: id}, nil, nil, nil)
+		if err == nil {
+			t.Errorf("Expected an error for bumId %q", id)
+		}
+	}
+}
+
+func TestCallErrorResponse(t *testing.T) {
+	server := baswaretest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	_, err := client.Call(context.Background(), http.MethodGet, "v1/invoices/{bumId}", url.Values{"bumId": {"unknown"}}, nil, nil, nil)
+	errResp, ok := err.(*basware.ErrorResponse)
+	if !ok || errResp.Response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a not found error response, got %v", err)
+	}
+}
@@ -0,0 +1,733 @@
+package basware
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Namespaces of the UN/CEFACT Cross Industry Invoice D16B
+const (
+	ciiNamespaceRSM = "urn:un:unece:uncefact:data:standard:CrossIndustryInvoice:100"
+	ciiNamespaceRAM = "urn:un:unece:uncefact:data:standard:ReusableAggregateBusinessInformationEntity:100"
+	ciiNamespaceUDT = "urn:un:unece:uncefact:data:standard:UnqualifiedDataType:100"
+	ciiNamespaceQDT = "urn:un:unece:uncefact:data:standard:QualifiedDataType:100"
+
+	// Specification identifier of the EN 16931 core invoice, also used as the
+	// Factur-X/ZUGFeRD EN 16931 profile
+	ciiGuidelineEN16931 = "urn:cen.eu:en16931:2017"
+
+	// UNTDID 1001 document type codes
+	ciiTypeCodeInvoice    = "380"
+	ciiTypeCodeCreditNote = "381"
+
+	// UNTDID 2379 date format CCYYMMDD
+	ciiDateFormat = "102"
+)
+
+// ParseCII converts an UN/CEFACT Cross Industry Invoice (D16B) as used by
+// ZUGFeRD/Factur-X and XRechnung into the Basware invoice model. Elements
+// without a Basware equivalent are reported in the warnings of the returned
+// document.
+func ParseCII(r io.Reader) (*ImportedDocument, error) {
+	root, err := parseXMLNode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if root.name() != "CrossIndustryInvoice" {
+		return nil, fmt.Errorf("Expected CrossIndustryInvoice, got \"%s\"", root.name())
+	}
+
+	c := &ciiConverter{}
+	doc := &ImportedDocument{}
+	doc.Type, doc.Invoice = c.invoice(root)
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	c.reportUnused(root)
+	doc.Warnings = c.warnings
+	return doc, nil
+}
+
+// NewPostRequestBodyFromCII creates a request body from a Cross Industry
+// Invoice. The returned warnings list the CII elements that couldn't be
+// mapped.
+func (s *InvoicesService) NewPostRequestBodyFromCII(r io.Reader) (*InvoicesPostRequestBody, ConversionWarnings, error) {
+	doc, err := ParseCII(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return doc.InvoicesPostRequestBody(), doc.Warnings, nil
+}
+
+// MarshalCII converts an invoice into a Cross Industry Invoice (D16B) using the
+// EN 16931 guideline. The warnings list the invoice fields that have no place
+// in CII.
+func MarshalCII(inv Invoice, docType DocumentType) ([]byte, ConversionWarnings, error) {
+	c := &ciiConverter{}
+	root := c.crossIndustryInvoice(inv, docType)
+	b, err := root.marshal()
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, c.warnings, nil
+}
+
+type ciiConverter struct {
+	converter
+}
+
+// date converts a CII date time string into CCYY-MM-DD
+func (c *ciiConverter) date(path string, n *xmlNode) string {
+	value := n.text("DateTimeString")
+	if value == "" {
+		return ""
+	}
+
+	format := n.child("DateTimeString").attr("format")
+	if format != ciiDateFormat || len(value) != 8 {
+		c.warn(path, "unsupported date format %s for \"%s\"", format, value)
+		return ""
+	}
+	return value[0:4] + "-" + value[4:6] + "-" + value[6:8]
+}
+
+func (c *ciiConverter) invoice(root *xmlNode) (DocumentType, Invoice) {
+	inv := Invoice{}
+	docType := DocumentTypeInvoice
+
+	// the guideline only describes the envelope
+	root.text("ExchangedDocumentContext", "GuidelineSpecifiedDocumentContextParameter", "ID")
+	root.text("ExchangedDocumentContext", "BusinessProcessSpecifiedDocumentContextParameter", "ID")
+
+	header := root.child("ExchangedDocument")
+	inv.ID = header.text("ID")
+	if header.text("TypeCode") == ciiTypeCodeCreditNote {
+		docType = DocumentTypeCreditNote
+	}
+	inv.IssueDate = c.date("ExchangedDocument/IssueDateTime", header.child("IssueDateTime"))
+
+	notes := []string{}
+	for _, n := range header.children("IncludedNote") {
+		notes = append(notes, n.text("Content"))
+	}
+	inv.Note = strings.Join(notes, "\n")
+
+	tx := root.child("SupplyChainTradeTransaction")
+
+	agreement := tx.child("ApplicableHeaderTradeAgreement")
+	inv.BuyerReference.ID = agreement.text("BuyerReference")
+	inv.AccountingSupplierParty = c.party(agreement.child("SellerTradeParty")).accountingSupplierParty()
+	inv.AccountingCustomerParty = c.party(agreement.child("BuyerTradeParty")).accountingCustomerParty()
+	inv.OrderReference.SalesOrderID = agreement.text("SellerOrderReferencedDocument", "IssuerAssignedID")
+	inv.OrderReference.ID = agreement.text("BuyerOrderReferencedDocument", "IssuerAssignedID")
+	inv.ContractDocumentReference.ID = agreement.text("ContractReferencedDocument", "IssuerAssignedID")
+
+	refs := agreement.children("AdditionalReferencedDocument")
+	if len(refs) > 0 {
+		inv.AdditionalDocumentReference.ID = refs[0].text("IssuerAssignedID")
+		inv.AdditionalDocumentReference.TypeCode = refs[0].text("TypeCode")
+		inv.AdditionalDocumentReference.IssueDate = c.date("AdditionalReferencedDocument/FormattedIssueDateTime", refs[0].child("FormattedIssueDateTime"))
+	}
+	if len(refs) > 1 {
+		for _, ref := range refs[1:] {
+			c.warn("ApplicableHeaderTradeAgreement/AdditionalReferencedDocument", "only one additional document reference is supported, dropped \"%s\"", ref.text("IssuerAssignedID"))
+			ref.markUsed()
+		}
+	}
+
+	delivery := tx.child("ApplicableHeaderTradeDelivery")
+	if p := delivery.child("ShipToTradeParty"); p != nil {
+		inv.DeliveryParty = c.party(p).deliveryParty()
+	}
+	inv.Delivery.ActualDeliveryDate = c.date("ActualDeliverySupplyChainEvent/OccurrenceDateTime", delivery.child("ActualDeliverySupplyChainEvent", "OccurrenceDateTime"))
+
+	settlement := tx.child("ApplicableHeaderTradeSettlement")
+	currency := settlement.text("InvoiceCurrencyCode")
+	inv.DocumentCurrencyCode = currency
+	inv.PaymentMeans = c.paymentMeans(settlement)
+	inv.BillingReference.ID = settlement.text("InvoiceReferencedDocument", "IssuerAssignedID")
+
+	for _, n := range settlement.children("SpecifiedTradeAllowanceCharge") {
+		charge := n.text("ChargeIndicator", "Indicator") == "true"
+		code := n.text("ReasonCode")
+		reason := n.text("Reason")
+		amount := n.text("ActualAmount")
+		value := c.decimal("SpecifiedTradeAllowanceCharge/ActualAmount", amount)
+		if !addCharge(&inv.AllowanceCharge, charge, code, value) {
+			c.warn("ApplicableHeaderTradeSettlement/SpecifiedTradeAllowanceCharge", "only freight and handling charges are supported, dropped %s (%s)", reason, amount)
+			n.markUsed()
+		}
+		// the category follows from the tax breakdown
+		n.child("CategoryTradeTax").markUsed()
+	}
+
+	terms := settlement.child("SpecifiedTradePaymentTerms")
+	inv.PaymentTerms.Note = terms.text("Description")
+	if due := c.date("SpecifiedTradePaymentTerms/DueDateDateTime", terms.child("DueDateDateTime")); due != "" {
+		inv.PaymentMeans.PaymentDueDate = due
+	}
+
+	for _, n := range settlement.children("ApplicableTradeTax") {
+		sub := TaxSubTotalItem{
+			CurrencyID:             currency,
+			Amount:                 c.decimal("ApplicableTradeTax/CalculatedAmount", n.text("CalculatedAmount")),
+			TaxableAmount:          c.decimal("ApplicableTradeTax/BasisAmount", n.text("BasisAmount")),
+			Percent:                c.decimal("ApplicableTradeTax/RateApplicablePercent", n.text("RateApplicablePercent")),
+			TaxExemptionReasonCode: n.text("ExemptionReasonCode"),
+			TaxExemptionReason:     n.text("ExemptionReason"),
+			TaxSchemeID:            taxScheme(n.child("TypeCode")),
+		}
+		sub.TaxCategoryID = taxCategory(n.child("CategoryCode"), sub.Percent)
+		inv.TaxTotal.TaxSubTotal = append(inv.TaxTotal.TaxSubTotal, sub)
+	}
+
+	summation := settlement.child("SpecifiedTradeSettlementHeaderMonetarySummation")
+	// the remaining totals are derived from the lines, charges and taxes
+	summation.text("ChargeTotalAmount")
+	summation.text("TaxBasisTotalAmount")
+	summation.text("GrandTotalAmount")
+	inv.LegalMonetaryTotal.LineExtensionAmount = Amount{
+		Amount:     c.decimal("SpecifiedTradeSettlementHeaderMonetarySummation/LineTotalAmount", summation.text("LineTotalAmount")),
+		CurrencyID: currency,
+	}
+	inv.LegalMonetaryTotal.PayableAmount = Amount{
+		Amount:     c.decimal("SpecifiedTradeSettlementHeaderMonetarySummation/DuePayableAmount", summation.text("DuePayableAmount")),
+		CurrencyID: currency,
+	}
+	for _, n := range summation.children("TaxTotalAmount") {
+		// a second tax total is expressed in the tax currency
+		if n.attr("currencyID") != currency && n.attr("currencyID") != "" {
+			continue
+		}
+		inv.TaxTotal.Amount = c.decimal("SpecifiedTradeSettlementHeaderMonetarySummation/TaxTotalAmount", n.text())
+		inv.TaxTotal.CurrencyID = currency
+	}
+
+	inv.InvoiceLine = []InvoiceLine{}
+	for _, n := range tx.children("IncludedSupplyChainTradeLineItem") {
+		inv.InvoiceLine = append(inv.InvoiceLine, c.invoiceLine(n, currency))
+	}
+
+	return docType, inv
+}
+
+func (c *ciiConverter) party(n *xmlNode) party {
+	p := party{}
+	if n == nil {
+		return p
+	}
+
+	for _, id := range n.children("ID") {
+		p.PartyIdentification = append(p.PartyIdentification, PartyIdentificationItem{
+			ID:       id.text(),
+			SchemeID: id.attr("schemeID"),
+		})
+	}
+	for _, id := range n.children("GlobalID") {
+		p.PartyIdentification = append(p.PartyIdentification, PartyIdentificationItem{
+			ID:       id.text(),
+			SchemeID: id.attr("schemeID"),
+		})
+	}
+
+	p.PartyName = n.text("Name")
+
+	contact := n.child("DefinedTradeContact")
+	p.Contact.Name = contact.text("PersonName")
+	p.Contact.Telephone = contact.text("TelephoneUniversalCommunication", "CompleteNumber")
+	p.Contact.Telefax = contact.text("FaxUniversalCommunication", "CompleteNumber")
+	p.Contact.ElectronicMail = contact.text("EmailURIUniversalCommunication", "URIID")
+
+	if a := n.child("PostalTradeAddress"); a != nil {
+		p.PostalAddress = PostalAddress{
+			PostalZone:       a.text("PostcodeCode"),
+			AddressLine:      a.text("LineOne"),
+			AddressLine2:     a.text("LineTwo"),
+			Locality:         a.text("LineThree"),
+			CityName:         a.text("CityName"),
+			CountryID:        a.text("CountryID"),
+			CountrySubentity: a.text("CountrySubDivisionName"),
+		}
+	}
+
+	p.Endpoint.ID = n.text("URIUniversalCommunication", "URIID")
+	p.Endpoint.SchemeID = n.child("URIUniversalCommunication", "URIID").attr("schemeID")
+
+	if le := n.child("SpecifiedLegalOrganization"); le != nil {
+		p.PartyLegalEntities = append(p.PartyLegalEntities, PartyLegalEntity{
+			RegistrationName: le.text("TradingBusinessName"),
+			CompanyID:        le.text("ID"),
+			SchemeID:         le.child("ID").attr("schemeID"),
+		})
+	}
+
+	for i, registration := range n.children("SpecifiedTaxRegistration") {
+		ts := PartyTaxScheme{}
+		ts.Company.ID = registration.text("ID")
+		ts.Company.SchemeID = ciiTaxSchemeID(registration.child("ID").attr("schemeID"))
+		if i == 0 {
+			p.PartyTaxScheme = ts
+		} else {
+			p.AdditionalPartyTaxSchemes = append(p.AdditionalPartyTaxSchemes, ts)
+		}
+	}
+
+	return p
+}
+
+// ciiTaxSchemeID maps the CII tax registration scheme (VA: VAT, FC: fiscal
+// number) to the scheme identifier used in the Basware model
+func ciiTaxSchemeID(schemeID string) string {
+	if schemeID == "VA" {
+		return "VAT"
+	}
+	return schemeID
+}
+
+func (c *ciiConverter) paymentMeans(settlement *xmlNode) PaymentMeans {
+	pm := PaymentMeans{}
+	pm.PaymentIdentifier.ID = settlement.text("PaymentReference")
+
+	for i, n := range settlement.children("SpecifiedTradeSettlementPaymentMeans") {
+		code := n.text("TypeCode")
+		if i == 0 {
+			pm.PaymentMeansCode = code
+		} else if code != pm.PaymentMeansCode {
+			c.warn("ApplicableHeaderTradeSettlement/SpecifiedTradeSettlementPaymentMeans", "only one payment means is supported, dropped payment means %s", code)
+			n.markUsed()
+			continue
+		}
+
+		account := n.child("PayeePartyCreditorFinancialAccount")
+		if account == nil {
+			continue
+		}
+
+		bic := n.text("PayeeSpecifiedCreditorFinancialInstitution", "BICID")
+		if iban := account.text("IBANID"); iban != "" {
+			pm.FinancialAccount = append(pm.FinancialAccount, newFinancialAccount(iban, "IBAN", bic, ""))
+		} else {
+			pm.FinancialAccount = append(pm.FinancialAccount, newFinancialAccount(account.text("ProprietaryID"), "", bic, ""))
+		}
+	}
+
+	return pm
+}
+
+func (c *ciiConverter) invoiceLine(n *xmlNode, currency string) InvoiceLine {
+	l := InvoiceLine{}
+	l.ID = n.text("AssociatedDocumentLineDocument", "LineID")
+
+	product := n.child("SpecifiedTradeProduct")
+	l.Item.SellersItem.ID = product.text("SellerAssignedID")
+	if id := product.child("GlobalID"); id != nil && l.Item.SellersItem.ID == "" {
+		l.Item.SellersItem.ID = id.text()
+		l.Item.SellersItem.SchemeID = id.attr("schemeID")
+	}
+	l.Item.Name = product.text("Name")
+	if d := product.text("Description"); d != "" {
+		l.Item.Description = []DescriptionItem{DescriptionItem(d)}
+	}
+
+	agreement := n.child("SpecifiedLineTradeAgreement")
+	l.OrderLineReference.LineID = agreement.text("BuyerOrderReferencedDocument", "LineID")
+	l.OrderLineReference.OrderReference = agreement.text("BuyerOrderReferencedDocument", "IssuerAssignedID")
+	l.Price.Amount = c.decimal("NetPriceProductTradePrice/ChargeAmount", agreement.text("NetPriceProductTradePrice", "ChargeAmount"))
+	l.Price.CurrencyID = currency
+
+	delivery := n.child("SpecifiedLineTradeDelivery")
+	l.Quantity.Amount = c.decimal("SpecifiedLineTradeDelivery/BilledQuantity", delivery.text("BilledQuantity"))
+	l.Quantity.UnitCode = delivery.child("BilledQuantity").attr("unitCode")
+	l.Delivery.ActualDeliveryDate = c.date("SpecifiedLineTradeDelivery/ActualDeliverySupplyChainEvent", delivery.child("ActualDeliverySupplyChainEvent", "OccurrenceDateTime"))
+
+	settlement := n.child("SpecifiedLineTradeSettlement")
+	tax := settlement.child("ApplicableTradeTax")
+	l.Item.TaxPercent = c.decimal("ApplicableTradeTax/RateApplicablePercent", tax.text("RateApplicablePercent"))
+	l.Item.TaxCategoryID = taxCategory(tax.child("CategoryCode"), l.Item.TaxPercent)
+	l.Item.TaxExemptionReasonCode = tax.text("ExemptionReasonCode")
+	l.Item.TaxExemptionReason = tax.text("ExemptionReason")
+	l.Item.TaxSchemeID = taxScheme(tax.child("TypeCode"))
+	l.LineExtension.Amount = c.decimal("SpecifiedTradeSettlementLineMonetarySummation/LineTotalAmount", settlement.text("SpecifiedTradeSettlementLineMonetarySummation", "LineTotalAmount"))
+	l.LineExtension.CurrencyID = currency
+
+	return l
+}
+
+// ciiDate converts a CCYY-MM-DD date into the CII date time string. Time zones
+// can't be expressed and are dropped.
+func (c *ciiConverter) ciiDate(name string, path string, date string) *xmlNode {
+	if date == "" {
+		return nil
+	}
+
+	if len(date) < 10 {
+		c.warn(path, "invalid date \"%s\"", date)
+		return nil
+	}
+	if len(date) > 10 {
+		c.warn(path, "time zone of \"%s\" can't be expressed in CII and was dropped", date)
+	}
+
+	value := strings.Replace(date[0:10], "-", "", -1)
+	return newXMLNode(name, newXMLText("udt:DateTimeString", value, "format", ciiDateFormat))
+}
+
+func (c *ciiConverter) crossIndustryInvoice(inv Invoice, docType DocumentType) *xmlNode {
+	root := newXMLNode("rsm:CrossIndustryInvoice")
+	root.Attrs = []xml.Attr{
+		{Name: xml.Name{Local: "xmlns:rsm"}, Value: ciiNamespaceRSM},
+		{Name: xml.Name{Local: "xmlns:ram"}, Value: ciiNamespaceRAM},
+		{Name: xml.Name{Local: "xmlns:udt"}, Value: ciiNamespaceUDT},
+		{Name: xml.Name{Local: "xmlns:qdt"}, Value: ciiNamespaceQDT},
+	}
+
+	typeCode := ciiTypeCodeInvoice
+	if docType == DocumentTypeCreditNote {
+		typeCode = ciiTypeCodeCreditNote
+	}
+
+	if inv.IDSchemeID != "" {
+		c.warn("idSchemeId", "CII has no scheme for the document identifier, dropped \"%s\"", inv.IDSchemeID)
+	}
+
+	header := newXMLNode("rsm:ExchangedDocument",
+		newXMLText("ram:ID", inv.ID),
+		newXMLText("ram:TypeCode", typeCode),
+		c.ciiDate("ram:IssueDateTime", "issueDate", inv.IssueDate),
+	)
+	for _, note := range splitNonEmpty(inv.Note) {
+		header.add(newXMLNode("ram:IncludedNote", newXMLText("ram:Content", note)))
+	}
+
+	tx := newXMLNode("rsm:SupplyChainTradeTransaction")
+	for i, l := range inv.InvoiceLine {
+		tx.add(c.lineItem(fmt.Sprintf("invoiceLine[%d]", i), l, inv.DocumentCurrencyCode))
+	}
+	tx.add(
+		c.headerTradeAgreement(inv),
+		c.headerTradeDelivery(inv),
+		c.headerTradeSettlement(inv),
+	)
+
+	root.add(
+		newXMLNode("rsm:ExchangedDocumentContext",
+			newXMLNode("ram:GuidelineSpecifiedDocumentContextParameter",
+				newXMLText("ram:ID", ciiGuidelineEN16931),
+			),
+		),
+		header,
+		tx,
+	)
+	return root
+}
+
+func (c *ciiConverter) tradeParty(name string, path string, p party) *xmlNode {
+	n := newXMLNode(name)
+
+	ids := []*xmlNode{}
+	globalIDs := []*xmlNode{}
+	for _, id := range p.PartyIdentification {
+		if id.SchemeID == "" {
+			ids = append(ids, newXMLText("ram:ID", id.ID))
+		} else {
+			globalIDs = append(globalIDs, newXMLText("ram:GlobalID", id.ID, "schemeID", id.SchemeID))
+		}
+	}
+	n.add(ids...)
+	n.add(globalIDs...)
+	n.add(newXMLText("ram:Name", p.PartyName))
+
+	// CII has a single legal organization
+	for i, le := range p.PartyLegalEntities {
+		if i > 0 {
+			c.warn(fmt.Sprintf("%s.partyLegalEntities[%d]", path, i), "CII has a single legal organization, dropped \"%s\"", le.CompanyID)
+			continue
+		}
+		n.add(newXMLNode("ram:SpecifiedLegalOrganization",
+			newXMLText("ram:ID", le.CompanyID, "schemeID", le.SchemeID),
+			newXMLText("ram:TradingBusinessName", le.RegistrationName),
+		).group())
+	}
+
+	n.add(newXMLNode("ram:DefinedTradeContact",
+		newXMLText("ram:PersonName", p.Contact.Name),
+		newXMLNode("ram:TelephoneUniversalCommunication", newXMLText("ram:CompleteNumber", p.Contact.Telephone)).group(),
+		newXMLNode("ram:FaxUniversalCommunication", newXMLText("ram:CompleteNumber", p.Contact.Telefax)).group(),
+		newXMLNode("ram:EmailURIUniversalCommunication", newXMLText("ram:URIID", p.Contact.ElectronicMail)).group(),
+	).group())
+
+	a := p.PostalAddress
+	n.add(newXMLNode("ram:PostalTradeAddress",
+		newXMLText("ram:PostcodeCode", a.PostalZone),
+		newXMLText("ram:LineOne", a.AddressLine),
+		newXMLText("ram:LineTwo", a.AddressLine2),
+		newXMLText("ram:LineThree", a.Locality),
+		newXMLText("ram:CityName", a.CityName),
+		newXMLText("ram:CountryID", a.CountryID),
+		newXMLText("ram:CountrySubDivisionName", a.CountrySubentity),
+	).group())
+
+	n.add(newXMLNode("ram:URIUniversalCommunication",
+		newXMLText("ram:URIID", p.Endpoint.ID, "schemeID", p.Endpoint.SchemeID),
+	).group())
+
+	schemes := append([]PartyTaxScheme{p.PartyTaxScheme}, p.AdditionalPartyTaxSchemes...)
+	for i, ts := range schemes {
+		if ts.Company.ID == "" {
+			continue
+		}
+		warnPath := path + ".partyTaxScheme.company.schemeId"
+		if i > 0 {
+			warnPath = fmt.Sprintf("%s.additionalPartyTaxSchemes[%d].company.schemeId", path, i-1)
+		}
+
+		company := ts.Company
+		schemeID := "VA"
+		switch company.SchemeID {
+		case "", "VAT", "VA":
+		case "FC":
+			schemeID = "FC"
+		default:
+			c.warn(warnPath, "CII only knows VAT (VA) and fiscal (FC) registrations, \"%s\" was written as FC", company.SchemeID)
+			schemeID = "FC"
+		}
+		n.add(newXMLNode("ram:SpecifiedTaxRegistration", newXMLText("ram:ID", company.ID, "schemeID", schemeID)))
+	}
+
+	return n
+}
+
+func (c *ciiConverter) headerTradeAgreement(inv Invoice) *xmlNode {
+	n := newXMLNode("ram:ApplicableHeaderTradeAgreement",
+		newXMLText("ram:BuyerReference", inv.BuyerReference.ID),
+		c.tradeParty("ram:SellerTradeParty", "accountingSupplierParty", inv.AccountingSupplierParty.party()),
+		c.tradeParty("ram:BuyerTradeParty", "accountingCustomerParty", inv.AccountingCustomerParty.party()),
+		newXMLNode("ram:SellerOrderReferencedDocument", newXMLText("ram:IssuerAssignedID", inv.OrderReference.SalesOrderID)).group(),
+		newXMLNode("ram:BuyerOrderReferencedDocument", newXMLText("ram:IssuerAssignedID", inv.OrderReference.ID)).group(),
+		newXMLNode("ram:ContractReferencedDocument", newXMLText("ram:IssuerAssignedID", inv.ContractDocumentReference.ID)).group(),
+	)
+
+	if inv.OrderReference.CustomerReference != "" {
+		c.warn("orderReference.customerReference", "CII has no customer reference, dropped \"%s\"", inv.OrderReference.CustomerReference)
+	}
+
+	ref := inv.AdditionalDocumentReference
+	if ref.ID != "" {
+		// the formatted date uses the qualified data type
+		issueDate := c.ciiDate("ram:FormattedIssueDateTime", "additionalDocumentReference.issueDate", ref.IssueDate)
+		if issueDate != nil {
+			issueDate.Children[0].XMLName.Local = "qdt:DateTimeString"
+		}
+
+		n.add(newXMLNode("ram:AdditionalReferencedDocument",
+			newXMLText("ram:IssuerAssignedID", ref.ID),
+			newXMLText("ram:TypeCode", ref.TypeCode),
+			issueDate,
+		))
+	}
+
+	return n
+}
+
+func (c *ciiConverter) headerTradeDelivery(inv Invoice) *xmlNode {
+	n := newXMLNode("ram:ApplicableHeaderTradeDelivery")
+	if !inv.DeliveryParty.party().isZero() {
+		n.add(c.tradeParty("ram:ShipToTradeParty", "deliveryParty", inv.DeliveryParty.party()))
+	}
+	n.add(newXMLNode("ram:ActualDeliverySupplyChainEvent",
+		c.ciiDate("ram:OccurrenceDateTime", "delivery.actualDeliveryDate", inv.Delivery.ActualDeliveryDate),
+	).group())
+	return n
+}
+
+func (c *ciiConverter) headerTradeSettlement(inv Invoice) *xmlNode {
+	currency := inv.DocumentCurrencyCode
+	pm := inv.PaymentMeans
+
+	if pm.PaymentIdentifier.SchemeID != "" {
+		c.warn("paymentMeans.paymentIdentifier.schemeId", "CII has no scheme for the payment reference, dropped \"%s\"", pm.PaymentIdentifier.SchemeID)
+	}
+
+	n := newXMLNode("ram:ApplicableHeaderTradeSettlement",
+		newXMLText("ram:PaymentReference", pm.PaymentIdentifier.ID),
+		newXMLText("ram:InvoiceCurrencyCode", currency),
+	)
+
+	for i, fa := range pm.FinancialAccount {
+		n.add(c.settlementPaymentMeans(fmt.Sprintf("paymentMeans.financialAccount[%d]", i), pm.PaymentMeansCode, fa))
+	}
+	if len(pm.FinancialAccount) == 0 && pm.PaymentMeansCode != "" {
+		n.add(newXMLNode("ram:SpecifiedTradeSettlementPaymentMeans", newXMLText("ram:TypeCode", pm.PaymentMeansCode)))
+	}
+
+	for i, sub := range inv.TaxTotal.TaxSubTotal {
+		n.add(newXMLNode("ram:ApplicableTradeTax",
+			newXMLText("ram:CalculatedAmount", formatAmount(sub.Amount)),
+			newXMLText("ram:TypeCode", sub.taxScheme()),
+			newXMLText("ram:ExemptionReason", sub.TaxExemptionReason),
+			newXMLText("ram:BasisAmount", formatAmount(sub.TaxableAmount)),
+			newXMLText("ram:CategoryCode", c.categoryCode(fmt.Sprintf("taxTotal.taxSubTotal[%d]", i), sub.TaxCategoryID, sub.Percent)),
+			newXMLText("ram:ExemptionReasonCode", sub.TaxExemptionReasonCode),
+			newXMLText("ram:RateApplicablePercent", formatDecimal(sub.Percent)),
+		))
+	}
+
+	charges := inv.AllowanceCharge.charges()
+	var chargeTax *xmlNode
+	if len(charges) > 0 {
+		if sub, ok := c.chargeTax("allowanceCharge", inv); ok {
+			chargeTax = newXMLNode("ram:CategoryTradeTax",
+				newXMLText("ram:TypeCode", sub.taxScheme()),
+				newXMLText("ram:CategoryCode", c.categoryCode("allowanceCharge", sub.TaxCategoryID, sub.Percent)),
+				newXMLText("ram:RateApplicablePercent", formatDecimal(sub.Percent)),
+			)
+		}
+	}
+	chargeTotal := 0.0
+	for _, ch := range charges {
+		chargeTotal = chargeTotal + ch.Amount
+		n.add(newXMLNode("ram:SpecifiedTradeAllowanceCharge",
+			newXMLNode("ram:ChargeIndicator", newXMLText("udt:Indicator", "true")),
+			newXMLText("ram:ActualAmount", formatAmount(ch.Amount)),
+			newXMLText("ram:ReasonCode", ch.ReasonCode),
+			newXMLText("ram:Reason", ch.Reason),
+			chargeTax,
+		))
+	}
+
+	terms := inv.PaymentTerms
+	n.add(newXMLNode("ram:SpecifiedTradePaymentTerms",
+		newXMLText("ram:Description", terms.Note),
+		c.ciiDate("ram:DueDateDateTime", "paymentMeans.paymentDueDate", pm.PaymentDueDate),
+	).group())
+	if terms.PenaltySurchargePercent != 0 {
+		c.warn("paymentTerms.penaltySurchargePercent", "CII has no penalty surcharge percent, dropped %s", formatDecimal(terms.PenaltySurchargePercent))
+	}
+	if terms.SettlementPeriod != (SettlementPeriod{}) {
+		c.warn("paymentTerms.settlementPeriod", "CII has no settlement period, dropped %s - %s", terms.SettlementPeriod.StartDate, terms.SettlementPeriod.EndDate)
+	}
+
+	lineTotal := inv.LegalMonetaryTotal.LineExtensionAmount.Amount
+	taxBasis := lineTotal + chargeTotal
+	chargeTotalAmount := ""
+	if len(charges) > 0 {
+		chargeTotalAmount = formatAmount(chargeTotal)
+	}
+	n.add(newXMLNode("ram:SpecifiedTradeSettlementHeaderMonetarySummation",
+		newXMLText("ram:LineTotalAmount", formatAmount(lineTotal)),
+		newXMLText("ram:ChargeTotalAmount", chargeTotalAmount),
+		newXMLText("ram:TaxBasisTotalAmount", formatAmount(taxBasis)),
+		newXMLText("ram:TaxTotalAmount", formatAmount(inv.TaxTotal.Amount), "currencyID", currency),
+		newXMLText("ram:GrandTotalAmount", formatAmount(taxBasis+inv.TaxTotal.Amount)),
+		newXMLText("ram:DuePayableAmount", formatAmount(inv.LegalMonetaryTotal.PayableAmount.Amount)),
+	))
+
+	n.add(newXMLNode("ram:InvoiceReferencedDocument",
+		newXMLText("ram:IssuerAssignedID", inv.BillingReference.ID),
+	).group())
+
+	return n
+}
+
+func (c *ciiConverter) settlementPaymentMeans(path string, code string, fa FinancialAccountItem) *xmlNode {
+	id := fa.accountID()
+	account := newXMLNode("ram:PayeePartyCreditorFinancialAccount")
+	if id.SchemeID == "IBAN" || (id.SchemeID == "" && looksLikeIBAN(id.ID)) {
+		account.add(newXMLText("ram:IBANID", id.ID))
+	} else {
+		account.add(newXMLText("ram:ProprietaryID", id.ID))
+	}
+
+	institution := newXMLNode("ram:PayeeSpecifiedCreditorFinancialInstitution")
+	if fa.FinancialInstitutionIDSchemeID == "" || fa.FinancialInstitutionIDSchemeID == "BIC" {
+		institution.add(newXMLText("ram:BICID", fa.FinancialInstitutionID))
+	} else {
+		c.warn(path+".financialInstitutionId", "CII only identifies institutions by BIC, dropped %s \"%s\"", fa.FinancialInstitutionIDSchemeID, fa.FinancialInstitutionID)
+	}
+
+	if fa.FinancialInstitutionName != "" {
+		c.warn(path+".financialInstitutionName", "CII has no institution name, dropped \"%s\"", fa.FinancialInstitutionName)
+	}
+	if fa.FinancialInstitutionBranchID != "" {
+		c.warn(path+".financialInstitutionBranchId", "CII has no institution branch, dropped \"%s\"", fa.FinancialInstitutionBranchID)
+	}
+	if fa.Accounting.VirtualBankBarcode.VirtualBankBarCode != "" {
+		c.warn(path+".accounting.virtualBankBarcode", "CII has no virtual bank barcode, dropped \"%s\"", fa.Accounting.VirtualBankBarcode.VirtualBankBarCode)
+	}
+
+	return newXMLNode("ram:SpecifiedTradeSettlementPaymentMeans",
+		newXMLText("ram:TypeCode", code),
+		account.group(),
+		institution.group(),
+	)
+}
+
+func (c *ciiConverter) lineItem(path string, l InvoiceLine, currency string) *xmlNode {
+	if l.InternalID != "" {
+		c.warn(path+".internalId", "CII has no internal line identifier, dropped \"%s\"", l.InternalID)
+	}
+	if l.Quantity.AmountUninvoiced != 0 {
+		c.warn(path+".quantity.amountUninvoiced", "CII has no uninvoiced quantity, dropped %s", formatDecimal(l.Quantity.AmountUninvoiced))
+	}
+	if l.ServiceIndicator {
+		c.warn(path+".serviceIndicator", "CII has no service indicator, dropped")
+	}
+	for i, t := range l.TaxTotal {
+		if t.Amount != 0 {
+			c.warn(fmt.Sprintf("%s.taxTotal[%d]", path, i), "CII has no line tax amount, dropped %s", formatAmount(t.Amount))
+		}
+	}
+	if l.AllowanceCharge != nil && *l.AllowanceCharge != (AllowanceCharge{}) {
+		c.warn(path+".allowanceCharge", "line level charges are not converted to CII")
+	}
+
+	product := newXMLNode("ram:SpecifiedTradeProduct")
+	if l.Item.SellersItem.SchemeID != "" {
+		product.add(newXMLText("ram:GlobalID", l.Item.SellersItem.ID, "schemeID", l.Item.SellersItem.SchemeID))
+	} else {
+		product.add(newXMLText("ram:SellerAssignedID", l.Item.SellersItem.ID))
+	}
+	descriptions := []string{}
+	for _, d := range l.Item.Description {
+		descriptions = append(descriptions, string(d))
+	}
+	product.add(
+		newXMLText("ram:Name", l.Item.Name),
+		newXMLText("ram:Description", strings.Join(descriptions, "\n")),
+	)
+
+	return newXMLNode("ram:IncludedSupplyChainTradeLineItem",
+		newXMLNode("ram:AssociatedDocumentLineDocument", newXMLText("ram:LineID", l.ID)),
+		product,
+		newXMLNode("ram:SpecifiedLineTradeAgreement",
+			newXMLNode("ram:BuyerOrderReferencedDocument",
+				newXMLText("ram:IssuerAssignedID", l.OrderLineReference.OrderReference),
+				newXMLText("ram:LineID", l.OrderLineReference.LineID),
+			).group(),
+			newXMLNode("ram:NetPriceProductTradePrice", newXMLText("ram:ChargeAmount", formatDecimal(l.Price.Amount))),
+		),
+		newXMLNode("ram:SpecifiedLineTradeDelivery",
+			newXMLText("ram:BilledQuantity", formatDecimal(l.Quantity.Amount), "unitCode", l.Quantity.UnitCode),
+			newXMLNode("ram:ActualDeliverySupplyChainEvent",
+				c.ciiDate("ram:OccurrenceDateTime", path+".delivery.actualDeliveryDate", l.Delivery.ActualDeliveryDate),
+			).group(),
+		),
+		newXMLNode("ram:SpecifiedLineTradeSettlement",
+			newXMLNode("ram:ApplicableTradeTax",
+				newXMLText("ram:TypeCode", l.Item.taxScheme()),
+				newXMLText("ram:ExemptionReason", l.Item.TaxExemptionReason),
+				newXMLText("ram:CategoryCode", c.categoryCode(path+".item.taxPercent", l.Item.TaxCategoryID, l.Item.TaxPercent)),
+				newXMLText("ram:ExemptionReasonCode", l.Item.TaxExemptionReasonCode),
+				newXMLText("ram:RateApplicablePercent", formatDecimal(l.Item.TaxPercent)),
+			),
+			newXMLNode("ram:SpecifiedTradeSettlementLineMonetarySummation",
+				newXMLText("ram:LineTotalAmount", formatAmount(l.LineExtension.Amount)),
+			),
+		),
+	)
+}
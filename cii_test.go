@@ -0,0 +1,132 @@
+package basware_test
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+func TestCIIRoundTrip(t *testing.T) {
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, warnings, err := basware.MarshalCII(doc.Invoice, doc.Type)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings:\n%s", warnings)
+	}
+	if !bytes.Contains(b, []byte(`<udt:DateTimeString format="102">20170901</udt:DateTimeString>`)) {
+		t.Errorf("expected issue date in CII format:\n%s", b)
+	}
+
+	cii, err := basware.ParseCII(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cii.Warnings) != 0 {
+		t.Errorf("unexpected warnings:\n%s", cii.Warnings)
+	}
+
+	before := doc.Invoice
+	after := cii.Invoice
+	if after.ID != before.ID || after.IssueDate != before.IssueDate || after.Note != before.Note {
+		t.Errorf("header changed: %+v", after)
+	}
+	if after.AccountingSupplierParty.Endpoint != before.AccountingSupplierParty.Endpoint {
+		t.Errorf("supplier endpoint changed: %+v", after.AccountingSupplierParty.Endpoint)
+	}
+	if after.AccountingSupplierParty.PartyTaxScheme != before.AccountingSupplierParty.PartyTaxScheme {
+		t.Errorf("supplier tax scheme changed: %+v", after.AccountingSupplierParty.PartyTaxScheme)
+	}
+	if after.AccountingSupplierParty.PostalAddress != before.AccountingSupplierParty.PostalAddress {
+		t.Errorf("supplier address changed: %+v", after.AccountingSupplierParty.PostalAddress)
+	}
+	if after.PaymentMeans.PaymentIdentifier != before.PaymentMeans.PaymentIdentifier || after.PaymentMeans.PaymentDueDate != before.PaymentMeans.PaymentDueDate {
+		t.Errorf("payment means changed: %+v", after.PaymentMeans)
+	}
+	if after.LegalMonetaryTotal != before.LegalMonetaryTotal {
+		t.Errorf("totals changed: %+v", after.LegalMonetaryTotal)
+	}
+	if len(after.InvoiceLine) != 1 || after.InvoiceLine[0].Price != before.InvoiceLine[0].Price || after.InvoiceLine[0].Quantity != before.InvoiceLine[0].Quantity {
+		t.Errorf("lines changed: %+v", after.InvoiceLine)
+	}
+}
+
+func TestMarshalCIIWarnings(t *testing.T) {
+	inv := basware.Invoice{
+		ID:         "1",
+		IDSchemeID: "internal",
+		InvoiceLine: []basware.InvoiceLine{
+			{ID: "1", InternalID: "a", Item: basware.Item{TaxPercent: 24}},
+		},
+	}
+
+	_, warnings, err := basware.MarshalCII(inv, basware.DocumentTypeInvoice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []string{}
+	for _, w := range warnings {
+		paths = append(paths, w.Path)
+	}
+	expected := "idSchemeId,invoiceLine[0].internalId"
+	if strings.Join(paths, ",") != expected {
+		t.Errorf("expected warnings for %s, got:\n%s", expected, warnings)
+	}
+}
+
+func TestMarshalChargeTaxCategory(t *testing.T) {
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv := doc.Invoice
+	inv.AllowanceCharge.Freight = 10
+	inv.CalculateTotals()
+
+	b, warnings, err := basware.MarshalCII(inv, doc.Type)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "<ram:Reason>Freight</ram:Reason><ram:CategoryTradeTax><ram:TypeCode>VAT</ram:TypeCode><ram:CategoryCode>S</ram:CategoryCode><ram:RateApplicablePercent>24</ram:RateApplicablePercent></ram:CategoryTradeTax>"
+	if len(warnings) != 0 || !bytes.Contains(compactXML(b), []byte(expected)) {
+		t.Errorf("expected the VAT category of the charge, got %s:\n%s", warnings, b)
+	}
+	cii, err := basware.ParseCII(bytes.NewReader(b))
+	if err != nil || len(cii.Warnings) != 0 || cii.Invoice.AllowanceCharge.Freight != 10 {
+		t.Errorf("expected the charge to be read back, got %v %s", err, cii.Warnings)
+	}
+
+	b, warnings, err = basware.MarshalUBL(inv, doc.Type)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `<cbc:Amount currencyID="EUR">10.00</cbc:Amount><cac:TaxCategory><cbc:ID>S</cbc:ID><cbc:Percent>24</cbc:Percent><cac:TaxScheme><cbc:ID>VAT</cbc:ID></cac:TaxScheme></cac:TaxCategory></cac:AllowanceCharge>`
+	if len(warnings) != 0 || !bytes.Contains(compactXML(b), []byte(expected)) {
+		t.Errorf("expected the VAT category of the charge, got %s:\n%s", warnings, b)
+	}
+	ubl, err := basware.ParseUBL(bytes.NewReader(b))
+	if err != nil || len(ubl.Warnings) != 0 || ubl.Invoice.AllowanceCharge.Freight != 10 {
+		t.Errorf("expected the charge to be read back, got %v %s", err, ubl.Warnings)
+	}
+
+	// without breakdown the category is unknown
+	inv.TaxTotal.TaxSubTotal = nil
+	_, warnings, err = basware.MarshalCII(inv, doc.Type)
+	if err != nil || len(warnings) != 1 || warnings[0].Path != "allowanceCharge" {
+		t.Errorf("expected a warning for the charge, got %s", warnings)
+	}
+}
+
+// compactXML removes the indentation between elements
+func compactXML(b []byte) []byte {
+	return regexp.MustCompile(`>\s+<`).ReplaceAll(b, []byte("><"))
+}
@@ -0,0 +1,461 @@
+package basware
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// Core Invoice Usage Specifications (CIUS) restrict EN 16931 for a receiving
+// country or network. Each profile is a RuleSet that's evaluated on top of
+// EN16931RuleSet.
+var (
+	// PeppolBIS3RuleSet holds the Peppol BIS Billing 3.0 rules
+	PeppolBIS3RuleSet = RuleSet{
+		Name: "Peppol BIS 3.0",
+		Rules: []Rule{
+			{
+				ID:       "PEPPOL-EN16931-R003",
+				Severity: RuleSeverityFatal,
+				Message:  "A buyer reference or purchase order reference MUST be provided",
+				Check: func(inv Invoice) []string {
+					if inv.BuyerReference.ID == "" && inv.OrderReference.ID == "" {
+						return []string{"buyerReference.id"}
+					}
+					return nil
+				},
+			},
+			{
+				ID:       "PEPPOL-EN16931-R010",
+				Severity: RuleSeverityFatal,
+				Message:  "Buyer electronic address MUST be provided",
+				Check: func(inv Invoice) []string {
+					return requiredField("accountingCustomerParty.endpoint.id", inv.AccountingCustomerParty.Endpoint.ID)
+				},
+			},
+			{
+				ID:       "PEPPOL-EN16931-R020",
+				Severity: RuleSeverityFatal,
+				Message:  "Seller electronic address MUST be provided",
+				Check: func(inv Invoice) []string {
+					return requiredField("accountingSupplierParty.endpoint.id", inv.AccountingSupplierParty.Endpoint.ID)
+				},
+			},
+			{
+				ID:       "PEPPOL-EN16931-CL008",
+				Severity: RuleSeverityFatal,
+				Message:  "Electronic address identifier scheme identifier MUST be coded using the Peppol EAS code list",
+				Check: func(inv Invoice) []string {
+					paths := []string{}
+					check := func(path string, e Endpoint) {
+						if s, ok := LookupIdentifierScheme(e.SchemeID); e.ID != "" && (!ok || s.Code != e.SchemeID) {
+							paths = append(paths, path)
+						}
+					}
+
+					check("accountingSupplierParty.endpoint.schemeId", inv.AccountingSupplierParty.Endpoint)
+					check("accountingCustomerParty.endpoint.schemeId", inv.AccountingCustomerParty.Endpoint)
+					return paths
+				},
+			},
+			{
+				ID:       "PEPPOL-EN16931-R051",
+				Severity: RuleSeverityFatal,
+				Message:  "All currencyID attributes MUST have the same value as the invoice currency code",
+				Check: func(inv Invoice) []string {
+					paths := []string{}
+					check := func(path string, currencyID string) {
+						if currencyID != "" && currencyID != inv.DocumentCurrencyCode {
+							paths = append(paths, path)
+						}
+					}
+
+					check("legalMonetaryTotal.lineExtensionAmount.currencyId", inv.LegalMonetaryTotal.LineExtensionAmount.CurrencyID)
+					check("legalMonetaryTotal.payableAmount.currencyId", inv.LegalMonetaryTotal.PayableAmount.CurrencyID)
+					check("taxTotal.currencyId", inv.TaxTotal.CurrencyID)
+					for i, sub := range inv.TaxTotal.TaxSubTotal {
+						check(fmt.Sprintf("taxTotal.taxSubTotal[%d].currencyId", i), sub.CurrencyID)
+					}
+					for i, l := range inv.InvoiceLine {
+						check(fmt.Sprintf("invoiceLine[%d].lineExtension.currencyId", i), l.LineExtension.CurrencyID)
+						check(fmt.Sprintf("invoiceLine[%d].price.currencyId", i), l.Price.CurrencyID)
+					}
+					return paths
+				},
+			},
+		},
+	}
+
+	// XRechnungRuleSet holds the German XRechnung rules
+	XRechnungRuleSet = RuleSet{
+		Name: "XRechnung",
+		Rules: []Rule{
+			{
+				ID:       "BR-DE-1",
+				Severity: RuleSeverityFatal,
+				Message:  "An invoice must contain information on PAYMENT INSTRUCTIONS",
+				Check: func(inv Invoice) []string {
+					return requiredField("paymentMeans.paymentMeansCode", inv.PaymentMeans.PaymentMeansCode)
+				},
+			},
+			{
+				ID:       "BR-DE-2",
+				Severity: RuleSeverityFatal,
+				Message:  "The group SELLER CONTACT must be transmitted",
+				Check: func(inv Invoice) []string {
+					if inv.AccountingSupplierParty.Contact == (Contact{}) {
+						return []string{"accountingSupplierParty.contact"}
+					}
+					return nil
+				},
+			},
+			{
+				ID:       "BR-DE-3",
+				Severity: RuleSeverityFatal,
+				Message:  "The element Seller city must be transmitted",
+				Check: func(inv Invoice) []string {
+					return requiredField("accountingSupplierParty.postalAddress.cityName", inv.AccountingSupplierParty.PostalAddress.CityName)
+				},
+			},
+			{
+				ID:       "BR-DE-4",
+				Severity: RuleSeverityFatal,
+				Message:  "The element Seller post code must be transmitted",
+				Check: func(inv Invoice) []string {
+					return requiredField("accountingSupplierParty.postalAddress.postalZone", inv.AccountingSupplierParty.PostalAddress.PostalZone)
+				},
+			},
+			{
+				ID:       "BR-DE-5",
+				Severity: RuleSeverityFatal,
+				Message:  "The element Seller contact point must be transmitted",
+				Check: func(inv Invoice) []string {
+					return requiredField("accountingSupplierParty.contact.name", inv.AccountingSupplierParty.Contact.Name)
+				},
+			},
+			{
+				ID:       "BR-DE-6",
+				Severity: RuleSeverityFatal,
+				Message:  "The element Seller contact telephone number must be transmitted",
+				Check: func(inv Invoice) []string {
+					return requiredField("accountingSupplierParty.contact.telephone", inv.AccountingSupplierParty.Contact.Telephone)
+				},
+			},
+			{
+				ID:       "BR-DE-7",
+				Severity: RuleSeverityFatal,
+				Message:  "The element Seller contact email address must be transmitted",
+				Check: func(inv Invoice) []string {
+					return requiredField("accountingSupplierParty.contact.electronicMail", inv.AccountingSupplierParty.Contact.ElectronicMail)
+				},
+			},
+			{
+				ID:       "BR-DE-8",
+				Severity: RuleSeverityFatal,
+				Message:  "The element Buyer city must be transmitted",
+				Check: func(inv Invoice) []string {
+					return requiredField("accountingCustomerParty.postalAddress.cityName", inv.AccountingCustomerParty.PostalAddress.CityName)
+				},
+			},
+			{
+				ID:       "BR-DE-9",
+				Severity: RuleSeverityFatal,
+				Message:  "The element Buyer post code must be transmitted",
+				Check: func(inv Invoice) []string {
+					return requiredField("accountingCustomerParty.postalAddress.postalZone", inv.AccountingCustomerParty.PostalAddress.PostalZone)
+				},
+			},
+			{
+				ID:       "BR-DE-15",
+				Severity: RuleSeverityFatal,
+				Message:  "The element Buyer reference must be transmitted",
+				Check: func(inv Invoice) []string {
+					return requiredField("buyerReference.id", inv.BuyerReference.ID)
+				},
+			},
+			{
+				// public administrations are addressed with a Leitweg-ID in
+				// the buyer reference, other buyers may use any reference
+				ID:       "BR-DE-15-LEITWEG-ID",
+				Severity: RuleSeverityWarning,
+				Message:  "The Buyer reference should be a valid Leitweg-ID",
+				Check: func(inv Invoice) []string {
+					if id := inv.BuyerReference.ID; id != "" && !IsValidLeitwegID(id) {
+						return []string{"buyerReference.id"}
+					}
+					return nil
+				},
+			},
+			{
+				ID:       "BR-DE-23-a",
+				Severity: RuleSeverityFatal,
+				Message:  "If the Payment means type code means credit transfer, the group CREDIT TRANSFER must be transmitted",
+				Check: func(inv Invoice) []string {
+					if isCreditTransfer(inv.PaymentMeans.PaymentMeansCode) && len(inv.PaymentMeans.FinancialAccount) == 0 {
+						return []string{"paymentMeans.financialAccount"}
+					}
+					return nil
+				},
+			},
+		},
+	}
+
+	// NLCIUSRuleSet holds the rules of the Dutch NLCIUS / SI-UBL 2.0
+	NLCIUSRuleSet = RuleSet{
+		Name: "NLCIUS",
+		Rules: []Rule{
+			{
+				ID:       "BR-NL-1",
+				Severity: RuleSeverityFatal,
+				Message:  "For suppliers in the Netherlands the supplier MUST provide either a KVK or OIN number for its legal entity identifier",
+				Check: func(inv Invoice) []string {
+					seller := inv.AccountingSupplierParty
+					if seller.PostalAddress.CountryID != "NL" || partyIdentifier(seller.party().identifiers(), dutchLegalEntitySchemes...) != "" {
+						return nil
+					}
+					return []string{"accountingSupplierParty.partyIdentification"}
+				},
+			},
+			{
+				ID:       "BR-NL-3",
+				Severity: RuleSeverityFatal,
+				Message:  "For suppliers in the Netherlands the supplier's address MUST contain street name, city and post code",
+				Check: func(inv Invoice) []string {
+					a := inv.AccountingSupplierParty.PostalAddress
+					if a.CountryID != "NL" {
+						return nil
+					}
+					return dutchAddress("accountingSupplierParty.postalAddress", a)
+				},
+			},
+			{
+				ID:       "BR-NL-4",
+				Severity: RuleSeverityFatal,
+				Message:  "For suppliers in the Netherlands, if the customer is in the Netherlands, the customer address MUST contain street name, city and post code",
+				Check: func(inv Invoice) []string {
+					a := inv.AccountingCustomerParty.PostalAddress
+					if inv.AccountingSupplierParty.PostalAddress.CountryID != "NL" || a.CountryID != "NL" {
+						return nil
+					}
+					return dutchAddress("accountingCustomerParty.postalAddress", a)
+				},
+			},
+			{
+				ID:       "BR-NL-5",
+				Severity: RuleSeverityFatal,
+				Message:  "For suppliers in the Netherlands, if the delivery address is in the Netherlands, it MUST contain street name, city and post code",
+				Check: func(inv Invoice) []string {
+					a := inv.DeliveryParty.PostalAddress
+					if inv.AccountingSupplierParty.PostalAddress.CountryID != "NL" || a.CountryID != "NL" {
+						return nil
+					}
+					return dutchAddress("deliveryParty.postalAddress", a)
+				},
+			},
+			{
+				ID:       "BR-NL-10",
+				Severity: RuleSeverityFatal,
+				Message:  "For suppliers in the Netherlands, if the customer is in the Netherlands, the customer MUST provide either a KVK or OIN number for its legal entity identifier",
+				Check: func(inv Invoice) []string {
+					buyer := inv.AccountingCustomerParty
+					if inv.AccountingSupplierParty.PostalAddress.CountryID != "NL" || buyer.PostalAddress.CountryID != "NL" {
+						return nil
+					}
+					if partyIdentifier(buyer.party().identifiers(), dutchLegalEntitySchemes...) != "" {
+						return nil
+					}
+					return []string{"accountingCustomerParty.partyIdentification"}
+				},
+			},
+		},
+	}
+
+	// EHFRuleSet holds the Norwegian EHF Billing 3.0 rules
+	EHFRuleSet = RuleSet{
+		Name: "EHF",
+		Rules: []Rule{
+			{
+				ID:       "NO-R-001",
+				Severity: RuleSeverityFatal,
+				Message:  "For Norwegian suppliers, a VAT number MUST be the country code prefix NO followed by a valid Norwegian organization number (nine numbers) followed by the letters MVA",
+				Check: func(inv Invoice) []string {
+					seller := inv.AccountingSupplierParty
+					id := seller.party().vatIdentifier()
+					if seller.PostalAddress.CountryID != "NO" || id == "" {
+						return nil
+					}
+					if !norwegianVATNumber.MatchString(id) || ValidateCompanyRegistrationNumber(id[2:11], "NO") != nil {
+						return []string{"accountingSupplierParty.partyTaxScheme.company.id"}
+					}
+					return nil
+				},
+			},
+		},
+	}
+
+	// OIOUBLRuleSet holds the Danish rules of OIOUBL and Peppol BIS for
+	// Danish suppliers
+	OIOUBLRuleSet = RuleSet{
+		Name: "OIOUBL",
+		Rules: []Rule{
+			{
+				ID:       "DK-R-002",
+				Severity: RuleSeverityFatal,
+				Message:  "Danish suppliers MUST provide legal entity (CVR-number)",
+				Check: func(inv Invoice) []string {
+					seller := inv.AccountingSupplierParty
+					if seller.PostalAddress.CountryID != "DK" {
+						return nil
+					}
+					ids := seller.party().identifiers()
+					for _, ts := range seller.party().taxSchemes() {
+						ids = append(ids, PartyIdentificationItem{ID: ts.Company.ID, SchemeID: ts.Company.SchemeID})
+					}
+					if partyIdentifier(ids, danishLegalEntitySchemes...) != "" {
+						return nil
+					}
+					return []string{"accountingSupplierParty.partyIdentification"}
+				},
+			},
+			{
+				ID:       "DK-R-005",
+				Severity: RuleSeverityFatal,
+				Message:  "For Danish suppliers the following Payment means codes are allowed: 1, 10, 31, 42, 48, 49, 50, 58, 59, 93 and 97",
+				Check: func(inv Invoice) []string {
+					code := inv.PaymentMeans.PaymentMeansCode
+					if inv.AccountingSupplierParty.PostalAddress.CountryID != "DK" || code == "" {
+						return nil
+					}
+					for _, allowed := range []string{"1", "10", "31", "42", "48", "49", "50", "58", "59", "93", "97"} {
+						if code == allowed {
+							return nil
+						}
+					}
+					return []string{"paymentMeans.paymentMeansCode"}
+				},
+			},
+			{
+				ID:       "DK-R-006",
+				Severity: RuleSeverityFatal,
+				Message:  "For Danish suppliers bank account and registration account is mandatory if payment means is 31 or 42",
+				Check: func(inv Invoice) []string {
+					pm := inv.PaymentMeans
+					if inv.AccountingSupplierParty.PostalAddress.CountryID != "DK" || (pm.PaymentMeansCode != "31" && pm.PaymentMeansCode != "42") {
+						return nil
+					}
+					for _, fa := range pm.FinancialAccount {
+						if fa.accountID().ID != "" && (fa.FinancialInstitutionID != "" || fa.FinancialInstitutionBranchID != "") {
+							return nil
+						}
+					}
+					return []string{"paymentMeans.financialAccount"}
+				},
+			},
+		},
+	}
+)
+
+// CountryProfiles maps ISO 3166-1 alpha-2 country codes to the CIUS profiles
+// invoices to buyers in that country have to satisfy. Countries without an
+// entry use the Peppol BIS 3.0 profile.
+var CountryProfiles = map[string][]RuleSet{
+	"DE": {XRechnungRuleSet},
+	"DK": {PeppolBIS3RuleSet, OIOUBLRuleSet},
+	"NL": {NLCIUSRuleSet},
+	"NO": {PeppolBIS3RuleSet, EHFRuleSet},
+}
+
+// CountryProfile returns the CIUS profiles of a country
+func CountryProfile(countryID string) []RuleSet {
+	if ruleSets, ok := CountryProfiles[strings.ToUpper(countryID)]; ok {
+		return ruleSets
+	}
+	return []RuleSet{PeppolBIS3RuleSet}
+}
+
+// ValidateInvoiceForBuyer checks the invoice against EN 16931, the CIUS
+// profiles of the buyer's country and the given extra rule sets
+func ValidateInvoiceForBuyer(inv Invoice, ruleSets ...RuleSet) RuleViolations {
+	profiles := CountryProfile(inv.AccountingCustomerParty.PostalAddress.CountryID)
+	return ValidateInvoice(inv, append(profiles, ruleSets...)...)
+}
+
+var (
+	// ISO 6523 ICD and Basware scheme identifiers of the Dutch chamber of
+	// commerce (KVK) and government organisation (OIN) numbers
+	dutchLegalEntitySchemes = []string{"0106", "0190", "NL:KVK", "NL:OIN"}
+
+	// ISO 6523 ICD and Basware scheme identifiers of the Danish CVR number
+	danishLegalEntitySchemes = []string{"0184", "DK:CVR"}
+
+	norwegianVATNumber = regexp.MustCompile(`^NO[0-9]{9}MVA$`)
+
+	leitwegID = regexp.MustCompile(`^[0-9]{2,12}(-[0-9A-Z]{1,30})?-[0-9]{2}$`)
+)
+
+// partyIdentifier returns the first identifier with one of the schemes
+func partyIdentifier(ids []PartyIdentificationItem, schemes ...string) string {
+	for _, id := range ids {
+		for _, scheme := range schemes {
+			if id.ID != "" && strings.EqualFold(id.SchemeID, scheme) {
+				return id.ID
+			}
+		}
+	}
+	return ""
+}
+
+func dutchAddress(path string, a PostalAddress) []string {
+	paths := []string{}
+	paths = append(paths, requiredField(path+".addressLine", a.AddressLine)...)
+	paths = append(paths, requiredField(path+".cityName", a.CityName)...)
+	paths = append(paths, requiredField(path+".postalZone", a.PostalZone)...)
+	return paths
+}
+
+// IsValidLeitwegID checks the format and ISO 7064 MOD 97-10 check digits of a
+// German Leitweg-ID, e.g. 04011000-1234512345-06
+func IsValidLeitwegID(id string) bool {
+	if !leitwegID.MatchString(id) {
+		return false
+	}
+
+	// letters count as two digits (A=10, ..., Z=35) like in an IBAN
+	digits := ""
+	for _, r := range strings.Replace(id, "-", "", -1) {
+		if r >= 'A' && r <= 'Z' {
+			digits += fmt.Sprint(int(r-'A') + 10)
+		} else {
+			digits += string(r)
+		}
+	}
+
+	n, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return false
+	}
+	return new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// isValidMod11 checks the trailing modulus 11 check digit of a number with the
+// given weights
+func isValidMod11(number string, weights []int) bool {
+	if len(number) != len(weights)+1 {
+		return false
+	}
+
+	sum := 0
+	for i, w := range weights {
+		if number[i] < '0' || number[i] > '9' {
+			return false
+		}
+		sum += int(number[i]-'0') * w
+	}
+
+	check := 11 - sum%11
+	if check == 11 {
+		check = 0
+	}
+	return check != 10 && int(number[len(number)-1]-'0') == check
+}
@@ -0,0 +1,112 @@
+package basware_test
+
+import (
+	"strings"
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+func TestValidateInvoiceForBuyer(t *testing.T) {
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kvk := func(inv *basware.Invoice) {
+		inv.AccountingSupplierParty.PartyIdentification = []basware.PartyIdentificationItem{{ID: "12345678", SchemeID: "0106"}}
+		inv.AccountingCustomerParty.PartyIdentification = []basware.PartyIdentificationItem{{ID: "87654321", SchemeID: "NL:KVK"}}
+	}
+	cvr := func(inv *basware.Invoice) {
+		inv.AccountingSupplierParty.PartyIdentification = []basware.PartyIdentificationItem{{ID: "12345674", SchemeID: "0184"}}
+	}
+
+	tests := []struct {
+		supplierCountryID string
+		countryID         string
+		buyerRef          string
+		modify            func(inv *basware.Invoice)
+		expected          []string
+	}{
+		{"FI", "SE", "REF-7", nil, []string{"PEPPOL-EN16931-R010"}},
+		{"FI", "DE", "REF-7", nil, []string{"BR-DE-2", "BR-DE-5", "BR-DE-6", "BR-DE-7", "BR-DE-15-LEITWEG-ID"}},
+		{"FI", "DE", "04011000-1234512345-06", nil, []string{"BR-DE-2", "BR-DE-5", "BR-DE-6", "BR-DE-7"}},
+
+		// the Dutch rules apply to Dutch suppliers
+		{"FI", "NL", "REF-7", nil, []string{}},
+		{"NL", "NL", "REF-7", nil, []string{"BR-NL-1", "BR-NL-10"}},
+		{"NL", "NL", "REF-7", func(inv *basware.Invoice) {
+			kvk(inv)
+			inv.AccountingSupplierParty.PostalAddress.AddressLine = "Damrak 1"
+			inv.AccountingCustomerParty.PostalAddress.AddressLine = "Coolsingel 2"
+			inv.AccountingSupplierParty.PostalAddress.CityName = ""
+			inv.DeliveryParty.PostalAddress = basware.PostalAddress{CountryID: "NL", CityName: "Utrecht"}
+		}, []string{"BR-NL-3", "BR-NL-5", "BR-NL-5"}},
+		{"NL", "NL", "REF-7", func(inv *basware.Invoice) {
+			kvk(inv)
+			inv.AccountingSupplierParty.PostalAddress.AddressLine = "Damrak 1"
+			inv.AccountingCustomerParty.PostalAddress = basware.PostalAddress{CountryID: "NL"}
+			inv.AccountingCustomerParty.PartyIdentification = nil
+		}, []string{"BR-NL-4", "BR-NL-4", "BR-NL-4", "BR-NL-10"}},
+
+		// the Norwegian VAT number is checked for Norwegian suppliers
+		{"FI", "NO", "REF-7", nil, []string{"PEPPOL-EN16931-R010"}},
+		{"NO", "NO", "REF-7", nil, []string{"PEPPOL-EN16931-R010", "NO-R-001"}},
+		{"NO", "NO", "REF-7", func(inv *basware.Invoice) {
+			inv.AccountingSupplierParty.PartyTaxScheme.Company.ID = "NO923609016MVA"
+		}, []string{"PEPPOL-EN16931-R010"}},
+		{"NO", "NO", "REF-7", func(inv *basware.Invoice) {
+			inv.AccountingSupplierParty.PartyTaxScheme.Company.ID = "NO923609017MVA"
+		}, []string{"ID-01", "PEPPOL-EN16931-R010", "NO-R-001"}},
+
+		// the Danish rules apply to Danish suppliers
+		{"FI", "DK", "REF-7", nil, []string{"PEPPOL-EN16931-R010"}},
+		{"DK", "DK", "REF-7", nil, []string{"PEPPOL-EN16931-R010", "DK-R-002"}},
+		{"DK", "DK", "REF-7", func(inv *basware.Invoice) {
+			cvr(inv)
+			inv.PaymentMeans.PaymentMeansCode = "30"
+		}, []string{"PEPPOL-EN16931-R010", "DK-R-005"}},
+		{"DK", "DK", "REF-7", func(inv *basware.Invoice) {
+			cvr(inv)
+			inv.PaymentMeans.PaymentMeansCode = "31"
+		}, []string{"PEPPOL-EN16931-R010"}},
+		{"DK", "DK", "REF-7", func(inv *basware.Invoice) {
+			cvr(inv)
+			inv.PaymentMeans.PaymentMeansCode = "31"
+			inv.PaymentMeans.FinancialAccount = []basware.FinancialAccountItem{{Ids: []basware.ID{{ID: "DK5000400440116243"}}}}
+		}, []string{"PEPPOL-EN16931-R010", "DK-R-006"}},
+	}
+
+	for _, test := range tests {
+		inv := doc.Invoice
+		inv.AccountingSupplierParty.PostalAddress.CountryID = test.supplierCountryID
+		inv.AccountingCustomerParty.PostalAddress.CountryID = test.countryID
+		inv.BuyerReference.ID = test.buyerRef
+		if test.modify != nil {
+			test.modify(&inv)
+		}
+
+		ids := []string{}
+		for _, v := range basware.ValidateInvoiceForBuyer(inv) {
+			ids = append(ids, v.RuleID)
+		}
+		if strings.Join(ids, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("%s to %s: expected violations %v, got %v", test.supplierCountryID, test.countryID, test.expected, ids)
+		}
+	}
+}
+
+func TestIsValidLeitwegID(t *testing.T) {
+	tests := map[string]bool{
+		"04011000-1234512345-06": true,
+		"991-33333TEST-33":       true,
+		"04011000-1234512345-07": false,
+		"0401100012345":          false,
+	}
+
+	for id, valid := range tests {
+		if basware.IsValidLeitwegID(id) != valid {
+			t.Errorf("expected IsValidLeitwegID(%s) to be %v", id, valid)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"strings"
+	"sync"
 
 	multierror "github.com/hashicorp/go-multierror"
 	uuid "github.com/satori/go.uuid"
@@ -74,9 +75,15 @@ type Client struct {
 	debug   bool
 	baseURL url.URL
 
-	// credentials
-	username string
-	password string
+	// credentials, guarded by mu as they can be rotated during requests
+	mu          sync.RWMutex
+	username    string
+	password    string
+	credentials CredentialsProvider
+
+	// authenticator of the requests; basic authentication with username and
+	// password when nil
+	authenticator Authenticator
 
 	// User agent for client
 	userAgent string
@@ -106,21 +113,67 @@ func (c *Client) SetDebug(debug bool) {
 }
 
 func (c *Client) Username() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.username
 }
 
 func (c *Client) SetUsername(username string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.username = username
 }
 
 func (c *Client) Password() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.password
 }
 
 func (c *Client) SetPassword(password string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.password = password
 }
 
+// CredentialsProvider returns the provider of the credentials; the username
+// and password when none is set
+func (c *Client) CredentialsProvider() CredentialsProvider {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.credentials == nil {
+		return StaticCredentials{Username: c.username, Password: c.password}
+	}
+	return c.credentials
+}
+
+// SetCredentialsProvider makes the client get the credentials from the
+// provider for every request, e.g. NewFileCredentials to rotate them
+func (c *Client) SetCredentialsProvider(provider CredentialsProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.credentials = provider
+}
+
+// Authenticator returns the authenticator of the requests
+func (c *Client) Authenticator() Authenticator {
+	c.mu.RLock()
+	authenticator := c.authenticator
+	c.mu.RUnlock()
+	if authenticator == nil {
+		return BasicAuth{Credentials: c.CredentialsProvider()}
+	}
+	return authenticator
+}
+
+// SetAuthenticator replaces the basic authentication, e.g. with
+// OAuth2ClientCredentials
+func (c *Client) SetAuthenticator(authenticator Authenticator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.authenticator = authenticator
+}
+
 func (c *Client) BaseURL() url.URL {
 	return c.baseURL
 }
@@ -183,18 +236,21 @@ func (c *Client) NewRequest(ctx context.Context, method string, URL url.URL, bod
 	}
 
 	// create new http request
-	req, err := http.NewRequest("POST", URL.String(), buf)
+	req, err := http.NewRequest(method, URL.String(), buf)
 	if err != nil {
 		return nil, err
 	}
 
-	req.SetBasicAuth(c.Username(), c.Password())
-
 	// optionally pass along context
 	if ctx != nil {
 		req = req.WithContext(ctx)
 	}
 
+	err = c.Authenticator().Authenticate(req)
+	if err != nil {
+		return nil, err
+	}
+
 	// send uuid as header for request/response identification
 	uuid := uuid.NewV4()
 	req.Header.Add("X-BW-REQUEST-ID", uuid.String())
@@ -221,6 +277,13 @@ func (c *Client) Do(req *http.Request, responseBody interface{}) (*http.Response
 		return nil, err
 	}
 
+	if httpResp.StatusCode == http.StatusUnauthorized {
+		httpResp, err = c.reauthenticate(req, httpResp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if c.onRequestCompleted != nil {
 		c.onRequestCompleted(req, httpResp)
 	}
@@ -266,6 +329,36 @@ func (c *Client) Do(req *http.Request, responseBody interface{}) (*http.Response
 	return httpResp, nil
 }
 
+// reauthenticate sends the request once more with renewed credentials when
+// the authenticator supports it, e.g. after a revoked token
+func (c *Client) reauthenticate(req *http.Request, httpResp *http.Response) (*http.Response, error) {
+	authenticator, ok := c.Authenticator().(Reauthenticator)
+	if !ok || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return httpResp, nil
+	}
+	if !authenticator.Invalidate(req) {
+		return httpResp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return httpResp, nil
+		}
+		retry.Body = body
+	}
+	err := authenticator.Authenticate(retry)
+	if err != nil {
+		httpResp.Body.Close()
+		return nil, err
+	}
+
+	io.Copy(ioutil.Discard, httpResp.Body)
+	httpResp.Body.Close()
+	return c.http.Do(retry)
+}
+
 // CheckResponse checks the API response for errors, and returns them if
 // present. A response is considered an error if it has a status code outside
 // the 200 range. API error responses are expected to have either no response
@@ -5,7 +5,6 @@ import (
 	"os"
 	"testing"
 
-	uuid "github.com/satori/go.uuid"
 	basware "github.com/tim-online/go-basware"
 )
 
@@ -22,7 +21,7 @@ func TestDing(t *testing.T) {
 	client.SetTestMode()
 
 	params := client.Invoices.NewPostPathParams()
-	params.BumID = uuid.NewV4().String()
+	params.BumID = basware.NewBumID()
 	requestBody := client.Invoices.NewPostRequestBody()
 	_, err := client.Invoices.Post(nil, params, requestBody)
 	log.Println(err)
@@ -0,0 +1,183 @@
+// Command basware-mock runs a local stand-in of the Basware API for
+// development. It serves the invoices, credit notes, files and notifications
+// endpoints of the baswaretest fake and keeps its data in a fixtures
+// directory between runs.
+//
+// Usage:
+//
+//	basware-mock -port 8080 -fixtures ./fixtures
+//
+// Point a client at it with SetBaseURL, e.g. http://localhost:8080/.
+//
+// The admin endpoints script the mock:
+//
+//	POST   /admin/notifications  queue a notification: {"notificationType": "DOCUMENT_DELIVERED", "bumId": "..."}
+//	POST   /admin/failures       fail requests: {"method": "POST", "path": "v1/invoices", "status": 429, "delay": "2s", "count": 1}
+//	GET    /admin/state          dump the documents, files and notifications
+//	DELETE /admin/state          remove all data
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	basware "github.com/tim-online/go-basware"
+	"github.com/tim-online/go-basware/baswaretest"
+)
+
+func main() {
+	port := flag.Int("port", 8080, "port to listen on")
+	fixtures := flag.String("fixtures", "fixtures", "directory the state is loaded from and saved to")
+	username := flag.String("username", "", "username of the basic authentication; not checked when empty")
+	password := flag.String("password", "", "password of the basic authentication")
+	flag.Parse()
+
+	m, err := newMock(*fixtures)
+	if err != nil {
+		log.Fatal(err)
+	}
+	m.fake.Username = *username
+	m.fake.Password = *password
+
+	addr := fmt.Sprintf(":%d", *port)
+	log.Printf("Basware mock listening on http://localhost%s/ with fixtures in %s", addr, m.statePath)
+	log.Fatal(http.ListenAndServe(addr, m))
+}
+
+type mock struct {
+	fake      *baswaretest.Fake
+	statePath string
+
+	// serializes saving the state
+	mu sync.Mutex
+}
+
+// newMock returns a mock with the state saved in the fixtures directory
+func newMock(fixtures string) (*mock, error) {
+	m := &mock{
+		fake:      baswaretest.NewFake(),
+		statePath: filepath.Join(fixtures, "state.json"),
+	}
+
+	data, err := ioutil.ReadFile(m.statePath)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := baswaretest.State{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("Invalid state %s: %s", m.statePath, err)
+	}
+	return m, m.fake.Restore(state)
+}
+
+func (m *mock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	if strings.HasPrefix(r.URL.Path, "/admin/") {
+		m.admin(rec, r)
+	} else {
+		m.fake.ServeHTTP(rec, r)
+	}
+
+	if r.Method != http.MethodGet && rec.status < 300 {
+		if err := m.save(); err != nil {
+			log.Printf("Saving state failed: %s", err)
+		}
+	}
+	log.Printf("%s %s %d %s", r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Millisecond))
+}
+
+func (m *mock) admin(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/admin/notifications" && r.Method == http.MethodPost:
+		n := basware.Notification{}
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if n.NotificationType == "" {
+			http.Error(w, "Expected a notificationType", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusCreated, m.fake.AddNotification(n))
+	case r.URL.Path == "/admin/failures" && r.Method == http.MethodPost:
+		f := struct {
+			Method string `json:"method"`
+			Path   string `json:"path"`
+			Status int    `json:"status"`
+			Delay  string `json:"delay"`
+			Count  int    `json:"count"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		failure := baswaretest.Failure{Method: f.Method, Path: strings.TrimPrefix(f.Path, "/"), Status: f.Status, Count: f.Count}
+		if f.Delay != "" {
+			delay, err := time.ParseDuration(f.Delay)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			failure.Delay = delay
+		}
+		m.fake.Fail(failure)
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/admin/state" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, m.fake.State())
+	case r.URL.Path == "/admin/state" && r.Method == http.MethodDelete:
+		m.fake.Restore(baswaretest.State{})
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// save writes the state of the fake to the fixtures directory
+func (m *mock) save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, err := json.MarshalIndent(m.fake.State(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.statePath), 0755); err != nil {
+		return err
+	}
+	// write a complete file or none at all
+	tmp := m.statePath + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.statePath)
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
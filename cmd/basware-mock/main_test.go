@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMockPersistsState(t *testing.T) {
+	dir := t.TempDir()
+	m, err := newMock(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/notifications", strings.NewReader(`{"notificationType": "DOCUMENT_DELIVERED", "bumId": "bum-1"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/failures", strings.NewReader(`{"path": "/v1/notifications", "status": 503}`)))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/notifications", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected the injected failure, got %d", w.Code)
+	}
+
+	restarted, err := newMock(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := restarted.fake.Notifications()
+	if len(n) != 1 || n[0].BumID != "bum-1" {
+		t.Errorf("Expected the notification to be persisted, got %+v", n)
+	}
+}
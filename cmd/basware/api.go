@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	uuid "github.com/satori/go.uuid"
+	basware "github.com/tim-online/go-basware"
+)
+
+func init() {
+	register(command{name: "invoice send", usage: "invoice send [-bum-id id] [-new-token] <file.json>", run: invoiceSend})
+	register(command{name: "invoice get", usage: "invoice get [-save file.json] <bumId>", run: invoiceGet})
+	register(command{name: "file upload", usage: "file upload [-type INVOICE_IMAGE|ATTACHMENT] [-mime-type type] <file>", run: fileUpload})
+	register(command{name: "notifications list", usage: "notifications list", run: notificationsList})
+	register(command{name: "notifications ack", usage: "notifications ack <notificationId>...", run: notificationsAck})
+}
+
+// invoiceSend posts an invoice request body. A missing client token is
+// generated; resending the same file with its token is processed once by the
+// API.
+func invoiceSend(ctx context.Context, e *env, args []string) error {
+	flags := newFlagSet("invoice send", e)
+	bumID := flags.String("bum-id", "", "bumId of the invoice, generated when empty")
+	newToken := flags.Bool("new-token", false, "replace the client token to send the invoice again as a new document")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Expected a file, got %d arguments", flags.NArg())
+	}
+
+	data, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	body := &basware.InvoicesPostRequestBody{}
+	if err := json.Unmarshal(data, body); err != nil {
+		return fmt.Errorf("Invalid invoice %s: %s", flags.Arg(0), err)
+	}
+	if body.ClientToken == "" || *newToken {
+		body.ClientToken = uuid.NewV4().String()
+	}
+	id := basware.BumID(*bumID)
+	if id == "" {
+		id = basware.NewBumID()
+	}
+	if err := id.Validate(); err != nil {
+		return err
+	}
+
+	client, err := e.Client()
+	if err != nil {
+		return err
+	}
+	params := client.Invoices.NewPostPathParams()
+	params.BumID = id
+	if _, err := client.Invoices.Post(ctx, params, body); err != nil {
+		return err
+	}
+
+	result := struct {
+		BumID       string `json:"bumId"`
+		ClientToken string `json:"clientToken"`
+		Status      string `json:"status"`
+	}{id.String(), body.ClientToken, "accepted"}
+	return e.out.print(result, []string{"BUMID", "CLIENTTOKEN", "STATUS"}, [][]string{{result.BumID, result.ClientToken, result.Status}})
+}
+
+// invoiceGet fetches an invoice. With -save it's written as request body that
+// invoice send accepts.
+func invoiceGet(ctx context.Context, e *env, args []string) error {
+	flags := newFlagSet("invoice get", e)
+	save := flags.String("save", "", "write the invoice as request body to this file, to resend it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Expected a bumId, got %d arguments", flags.NArg())
+	}
+
+	client, err := e.Client()
+	if err != nil {
+		return err
+	}
+	params := client.Invoices.NewGetPathParams()
+	params.BumID = basware.BumID(flags.Arg(0))
+	resp, err := client.Invoices.Get(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	if *save != "" {
+		body := basware.InvoicesPostRequestBody{Data: resp.Data, FileRefs: resp.FileRefs}
+		data, err := json.MarshalIndent(body, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*save, append(data, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+
+	inv := resp.Data
+	fields := [][2]string{
+		{"bumId", params.BumID.String()},
+		{"id", inv.ID},
+		{"issueDate", inv.IssueDate},
+		{"supplier", inv.AccountingSupplierParty.PartyName},
+		{"customer", inv.AccountingCustomerParty.PartyName},
+		{"payableAmount", formatAmount(inv.LegalMonetaryTotal.PayableAmount)},
+	}
+	for _, ref := range resp.FileRefs {
+		fields = append(fields, [2]string{"fileRef", ref.RefID + " " + ref.FileType})
+	}
+	for _, link := range resp.Links {
+		fields = append(fields, [2]string{"link " + link.Rel, link.Method + " " + link.Href})
+	}
+	return e.out.printFields(resp, fields)
+}
+
+func fileUpload(ctx context.Context, e *env, args []string) error {
+	flags := newFlagSet("file upload", e)
+	fileType := flags.String("type", basware.FileTypeAttachment, "file type: INVOICE_IMAGE or ATTACHMENT")
+	mimeType := flags.String("mime-type", "", "media type, guessed from the extension when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Expected a file, got %d arguments", flags.NArg())
+	}
+	if *fileType != basware.FileTypeAttachment && *fileType != basware.FileTypeInvoiceImage {
+		return fmt.Errorf("Invalid file type \"%s\"", *fileType)
+	}
+
+	path := flags.Arg(0)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if *mimeType == "" {
+		*mimeType = mime.TypeByExtension(filepath.Ext(path))
+	}
+	if *mimeType == "" {
+		*mimeType = http.DetectContentType(content)
+	}
+
+	client, err := e.Client()
+	if err != nil {
+		return err
+	}
+	body := client.Files.NewPostRequestBody()
+	body.FileName = filepath.Base(path)
+	body.MimeType = *mimeType
+	body.FileType = *fileType
+	body.Content = content
+	resp, err := client.Files.Post(ctx, body)
+	if err != nil {
+		return err
+	}
+	return e.out.print(resp, []string{"REFID", "FILENAME", "FILETYPE"}, [][]string{{resp.RefID, body.FileName, body.FileType}})
+}
+
+func notificationsList(ctx context.Context, e *env, args []string) error {
+	flags := newFlagSet("notifications list", e)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := e.Client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Notifications.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := [][]string{}
+	for _, n := range resp.Notifications {
+		rows = append(rows, []string{n.NotificationID.String(), n.NotificationType, n.BumID.String(), n.DocumentType, n.Created, n.Message})
+	}
+	return e.out.print(resp, []string{"ID", "TYPE", "BUMID", "DOCUMENT", "CREATED", "MESSAGE"}, rows)
+}
+
+func notificationsAck(ctx context.Context, e *env, args []string) error {
+	flags := newFlagSet("notifications ack", e)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("Expected notification ids")
+	}
+
+	client, err := e.Client()
+	if err != nil {
+		return err
+	}
+	acknowledged := []string{}
+	rows := [][]string{}
+	for _, id := range flags.Args() {
+		params := client.Notifications.NewAcknowledgePathParams()
+		params.NotificationID = basware.NotificationID(id)
+		if err := client.Notifications.Acknowledge(ctx, params); err != nil {
+			return err
+		}
+		acknowledged = append(acknowledged, id)
+		rows = append(rows, []string{id, "acknowledged"})
+	}
+	return e.out.print(map[string][]string{"acknowledged": acknowledged}, []string{"ID", "STATUS"}, rows)
+}
+
+func formatAmount(a basware.Amount) string {
+	if a.CurrencyID == "" && a.Amount == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(a.Amount, 'f', 2, 64) + " " + a.CurrencyID
+}
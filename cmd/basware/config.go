@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config holds the settings of the CLI
+type Config struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	// Use the test environment
+	Test bool `json:"test"`
+
+	// Base URL overriding the environment, e.g. of basware-mock
+	BaseURL string `json:"baseUrl"`
+
+	Debug bool `json:"-"`
+}
+
+// defaultConfigPath returns basware/config.json in the user's config directory
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "basware", "config.json")
+}
+
+// LoadConfig reads the config file and overrides the credentials with
+// BASWARE_USERNAME and BASWARE_PASSWORD. Without an explicit path a missing
+// default config file is ignored.
+func LoadConfig(path string) (Config, error) {
+	config := Config{}
+
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigPath()
+	}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		switch {
+		case os.IsNotExist(err) && !explicit:
+		case err != nil:
+			return config, err
+		default:
+			if err := json.Unmarshal(data, &config); err != nil {
+				return config, fmt.Errorf("Invalid config file %s: %s", path, err)
+			}
+		}
+	}
+
+	if username := os.Getenv("BASWARE_USERNAME"); username != "" {
+		config.Username = username
+	}
+	if password := os.Getenv("BASWARE_PASSWORD"); password != "" {
+		config.Password = password
+	}
+	return config, nil
+}
+
+// parseBaseURL parses a base URL, which has to end with a slash for the
+// endpoint paths to be appended
+func parseBaseURL(s string) (*url.URL, error) {
+	if !strings.HasSuffix(s, "/") {
+		s += "/"
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("Invalid base URL \"%s\"", s)
+	}
+	return u, nil
+}
@@ -0,0 +1,199 @@
+// Command basware sends and fetches documents with the Basware API, and
+// validates, converts and renders invoice files offline.
+//
+// Usage:
+//
+//	basware [-test] [-config file] [-output json|table] <command> [arguments]
+//
+// Commands:
+//
+//	invoice send [-bum-id id] [-new-token] <file.json>
+//	invoice get [-save file.json] <bumId>
+//	file upload [-type INVOICE_IMAGE|ATTACHMENT] [-mime-type type] <file>
+//	notifications list
+//	notifications ack <notificationId>...
+//
+// Offline commands, which accept Basware JSON, UBL 2.1 and CII files:
+//
+//	validate [-rules buyer|peppol,xrechnung,nlcius,ehf,oioubl,print] <file>
+//	totals [-fix] [-o file] <file.json>
+//	convert -to json|ubl|cii [-strict] [-o file] <file>
+//	render [-lang en] [-facturx] -o file.pdf <file>
+//
+// validate prints its findings, as JSON with -output json, and exits with
+// status 1 when the invoice has schema errors or fatal rule violations.
+//
+// The credentials of the API commands are read from BASWARE_USERNAME and
+// BASWARE_PASSWORD or from the config file, by default basware/config.json in
+// the user's config directory:
+//
+//	{"username": "...", "password": "...", "test": true}
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+// command is a subcommand of the CLI, named by one or two words
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, e *env, args []string) error
+}
+
+var commands = []command{}
+
+func register(c command) {
+	commands = append(commands, c)
+}
+
+// env is what commands run with
+type env struct {
+	config Config
+	out    *output
+	stderr io.Writer
+
+	client *basware.Client
+}
+
+// Client returns the API client, built on first use so offline commands
+// don't need credentials
+func (e *env) Client() (*basware.Client, error) {
+	if e.client != nil {
+		return e.client, nil
+	}
+	if e.config.Username == "" || e.config.Password == "" {
+		return nil, fmt.Errorf("Expected credentials in BASWARE_USERNAME and BASWARE_PASSWORD or the config file")
+	}
+
+	e.client = basware.NewClient(nil, e.config.Username, e.config.Password)
+	if e.config.Test {
+		e.client.SetTestMode()
+	}
+	if e.config.BaseURL != "" {
+		u, err := parseBaseURL(e.config.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		e.client.SetBaseURL(*u)
+	}
+	e.client.SetDebug(e.config.Debug)
+	return e.client, nil
+}
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line and returns the exit code
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("basware", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configPath := flags.String("config", os.Getenv("BASWARE_CONFIG"), "config file with the credentials")
+	test := flags.Bool("test", false, "use the test environment")
+	baseURL := flags.String("base-url", "", "base URL of the API, e.g. of basware-mock")
+	format := flags.String("output", "table", "output format: json or table")
+	debug := flags.Bool("debug", false, "log requests and responses")
+	flags.Usage = func() { usage(flags, stderr) }
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	config, err := LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if *test {
+		config.Test = true
+	}
+	if *baseURL != "" {
+		config.BaseURL = *baseURL
+	}
+	config.Debug = *debug
+
+	if *format != formatJSON && *format != formatTable {
+		fmt.Fprintf(stderr, "Invalid output format \"%s\"\n", *format)
+		return 2
+	}
+
+	cmd, cmdArgs, ok := findCommand(flags.Args())
+	if !ok {
+		usage(flags, stderr)
+		return 2
+	}
+
+	e := &env{config: config, out: &output{format: *format, w: stdout}, stderr: stderr}
+	if err := cmd.run(ctx, e, cmdArgs); err != nil {
+		if err == flag.ErrHelp {
+			return 2
+		}
+		printError(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// findCommand returns the command named by the first words of the arguments
+func findCommand(args []string) (command, []string, bool) {
+	for _, c := range commands {
+		words := strings.Fields(c.name)
+		if len(args) < len(words) {
+			continue
+		}
+		if strings.Join(args[:len(words)], " ") == c.name {
+			return c, args[len(words):], true
+		}
+	}
+	return command{}, nil, false
+}
+
+func usage(flags *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "Usage: basware [flags] <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	usages := []string{}
+	for _, c := range commands {
+		usages = append(usages, "  "+c.usage)
+	}
+	sort.Strings(usages)
+	fmt.Fprintln(w, strings.Join(usages, "\n"))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Flags:")
+	flags.PrintDefaults()
+}
+
+// newFlagSet returns the flags of a command
+func newFlagSet(c string, e *env) *flag.FlagSet {
+	flags := flag.NewFlagSet(c, flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	return flags
+}
+
+// printError prints an error with the validation errors of an API error
+// response on their own lines
+func printError(w io.Writer, err error) {
+	if errResp, ok := err.(*basware.ErrorResponse); ok {
+		status := 0
+		if errResp.Response != nil {
+			status = errResp.Response.StatusCode
+		}
+		fmt.Fprintf(w, "API error %d %s %s: %s\n", status, errResp.Errors.Type, errResp.Errors.Code, errResp.Errors.Message)
+		for _, v := range errResp.Errors.ValidationErrors {
+			fmt.Fprintf(w, "  %s: %s\n", v.FieldID, v.FieldMessage)
+		}
+		if errResp.Errors.ID != "" {
+			fmt.Fprintf(w, "Error id: %s\n", errResp.Errors.ID)
+		}
+		return
+	}
+	fmt.Fprintln(w, err)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tim-online/go-basware/baswaretest"
+)
+
+const invoiceJSON = `{
+	"data": {
+		"id": "INV-1",
+		"issueDate": "2024-03-01",
+		"accountingSupplierParty": {"partyName": "Seller Oy"},
+		"accountingCustomerParty": {"partyName": "Buyer Oy"},
+		"legalMonetaryTotal": {
+			"lineExtensionAmount": {"currencyId": "EUR", "amount": 100},
+			"payableAmount": {"currencyId": "EUR", "amount": 124}
+		},
+		"invoiceLine": [{
+			"id": "1",
+			"lineExtension": {"currencyId": "EUR", "amount": 100},
+			"item": {"name": "Widget"}
+		}]
+	}
+}`
+
+func runCLI(t *testing.T, args ...string) (int, string, string) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(context.Background(), args, stdout, stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestInvoiceCommands(t *testing.T) {
+	server := baswaretest.NewServer()
+	defer server.Close()
+	server.Username, server.Password = "user", "secret"
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.json")
+	ioutil.WriteFile(config, []byte(`{"username": "user", "password": "secret", "baseUrl": "`+server.URL+`"}`), 0644)
+	invoice := filepath.Join(dir, "invoice.json")
+	ioutil.WriteFile(invoice, []byte(invoiceJSON), 0644)
+
+	code, stdout, stderr := runCLI(t, "-config", config, "invoice", "send", "-bum-id", "bum-1", invoice)
+	if code != 0 || !strings.Contains(stdout, "accepted") {
+		t.Fatalf("Expected the invoice to be sent, got %d: %s%s", code, stdout, stderr)
+	}
+
+	saved := filepath.Join(dir, "saved.json")
+	code, stdout, stderr = runCLI(t, "-config", config, "invoice", "get", "-save", saved, "bum-1")
+	if code != 0 || !strings.Contains(stdout, "124.00 EUR") {
+		t.Fatalf("Expected the invoice, got %d: %s%s", code, stdout, stderr)
+	}
+	// the saved invoice can be resent as a new document
+	code, _, stderr = runCLI(t, "-config", config, "invoice", "send", "-new-token", saved)
+	if code != 0 {
+		t.Fatalf("Expected the saved invoice to be sent, got %d: %s", code, stderr)
+	}
+
+	code, stdout, _ = runCLI(t, "-config", config, "-output", "json", "notifications", "list")
+	resp := struct {
+		Notifications []struct {
+			NotificationID string `json:"notificationId"`
+		} `json:"notifications"`
+	}{}
+	if err := json.Unmarshal([]byte(stdout), &resp); code != 0 || err != nil || len(resp.Notifications) != 2 {
+		t.Fatalf("Expected 2 notifications, got %d: %s", code, stdout)
+	}
+	code, _, stderr = runCLI(t, "-config", config, "notifications", "ack", resp.Notifications[0].NotificationID, resp.Notifications[1].NotificationID)
+	if code != 0 || len(server.Notifications()) != 0 {
+		t.Fatalf("Expected the notifications to be acknowledged, got %d: %s", code, stderr)
+	}
+
+	code, _, stderr = runCLI(t, "-config", config, "invoice", "get", "unknown")
+	if code != 1 || !strings.Contains(stderr, "API error 404 NOT_FOUND") {
+		t.Errorf("Expected a not found error, got %d: %s", code, stderr)
+	}
+}
+
+func TestOfflineCommands(t *testing.T) {
+	dir := t.TempDir()
+	invoice := filepath.Join(dir, "invoice.json")
+	ioutil.WriteFile(invoice, []byte(invoiceJSON), 0644)
+
+	// the request body has no client token
+	code, stdout, _ := runCLI(t, "-output", "json", "validate", "-rules", "none", invoice)
+	report := Report{}
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatal(err)
+	}
+	if code != 1 || report.Valid || len(report.Findings) == 0 || report.Findings[0].Source != findingSchema {
+		t.Errorf("Expected a schema finding, got %d: %+v", code, report)
+	}
+
+	// the line has no tax, so the payable amount is 100
+	code, stdout, stderr := runCLI(t, "totals", "-fix", invoice)
+	if code != 0 || !strings.Contains(stdout, "legalMonetaryTotal.payableAmount        124      100         true") {
+		t.Errorf("Expected the payable amount to be fixed, got %d: %s%s", code, stdout, stderr)
+	}
+	doc, err := readDocument(invoice, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.body.Data.LegalMonetaryTotal.PayableAmount.Amount != 100 {
+		t.Errorf("Expected the fixed invoice to be written, got %+v", doc.body.Data.LegalMonetaryTotal)
+	}
+
+	// a bare invoice is written back without request body
+	bare := filepath.Join(dir, "bare.json")
+	data, _ := json.Marshal(doc.body.Data)
+	ioutil.WriteFile(bare, data, 0644)
+	if code, _, stderr := runCLI(t, "totals", "-fix", bare); code != 0 {
+		t.Fatalf("Expected the bare invoice to be fixed, got %d: %s", code, stderr)
+	}
+	fields := map[string]json.RawMessage{}
+	data, _ = ioutil.ReadFile(bare)
+	if err := json.Unmarshal(data, &fields); err != nil || fields["data"] != nil || fields["clientToken"] != nil || fields["id"] == nil {
+		t.Errorf("Expected a bare invoice, got %s", data)
+	}
+
+	ubl := filepath.Join(dir, "invoice.xml")
+	if code, _, stderr := runCLI(t, "convert", "-to", "ubl", "-o", ubl, invoice); code != 0 {
+		t.Fatalf("Expected the invoice to be converted, got %d: %s", code, stderr)
+	}
+	code, stdout, stderr = runCLI(t, "convert", "-to", "json", ubl)
+	if code != 0 || !strings.Contains(stdout, `"id": "INV-1"`) {
+		t.Errorf("Expected the UBL to be converted back, got %d: %s%s", code, stdout, stderr)
+	}
+
+	// the tax categories of JSON invoices are kept
+	reverseCharge := filepath.Join(dir, "reverse-charge.json")
+	ioutil.WriteFile(reverseCharge, []byte(strings.Replace(invoiceJSON, `"item": {"name": "Widget"}`,
+		`"item": {"name": "Widget", "taxCategoryId": "AE", "taxExemptionReasonCode": "VATEX-EU-AE"}`, 1)), 0644)
+	code, stdout, stderr = runCLI(t, "convert", "-to", "json", reverseCharge)
+	if code != 0 || !strings.Contains(stdout, `"taxCategoryId": "AE"`) || !strings.Contains(stdout, `"taxExemptionReasonCode": "VATEX-EU-AE"`) {
+		t.Errorf("Expected the tax category to be kept, got %d: %s%s", code, stdout, stderr)
+	}
+	code, stdout, stderr = runCLI(t, "convert", "-to", "ubl", reverseCharge)
+	if code != 0 || !strings.Contains(stdout, "<cbc:ID>AE</cbc:ID>") {
+		t.Errorf("Expected a reverse charge UBL invoice, got %d: %s%s", code, stdout, stderr)
+	}
+	code, stdout, _ = runCLI(t, "-output", "json", "validate", "-rules", "none", reverseCharge)
+	report = Report{}
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range report.Findings {
+		if f.Source == findingSchema && strings.Contains(f.Message, "taxCategoryId") {
+			t.Errorf("Expected the tax category fields to pass the schema, got %+v", f)
+		}
+	}
+
+	pdf := filepath.Join(dir, "invoice.pdf")
+	if code, _, stderr := runCLI(t, "render", "-o", pdf, ubl); code != 0 {
+		t.Fatalf("Expected the invoice to be rendered, got %d: %s", code, stderr)
+	}
+	if data, _ := ioutil.ReadFile(pdf); !bytes.HasPrefix(data, []byte("%PDF-")) {
+		t.Error("Expected a PDF")
+	}
+}
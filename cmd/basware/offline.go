@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+// The offline commands don't call the API and need no credentials. Their input
+// is a Basware JSON request body or invoice, an UBL 2.1 Invoice or CreditNote
+// or a Cross Industry Invoice.
+func init() {
+	register(command{name: "validate", usage: "validate [-rules buyer|peppol,xrechnung,nlcius,ehf,oioubl,print] [-type invoice|creditNote] <file>", run: validate})
+	register(command{name: "totals", usage: "totals [-fix] [-o file] <file.json>", run: totals})
+	register(command{name: "convert", usage: "convert -to json|ubl|cii [-type invoice|creditNote] [-o file] <file>", run: convert})
+	register(command{name: "render", usage: "render [-lang en|fi|sv|de|nl] [-facturx] [-type invoice|creditNote] -o file.pdf <file>", run: render})
+}
+
+// ruleSets are the rule sets validate can check on top of EN 16931 and the
+// identifiers
+var ruleSets = map[string]basware.RuleSet{
+	"peppol":    basware.PeppolBIS3RuleSet,
+	"xrechnung": basware.XRechnungRuleSet,
+	"nlcius":    basware.NLCIUSRuleSet,
+	"ehf":       basware.EHFRuleSet,
+	"oioubl":    basware.OIOUBLRuleSet,
+	"print":     basware.PrintRuleSet,
+}
+
+// Sources of findings
+const (
+	findingSchema     = "schema"
+	findingRule       = "rule"
+	findingConversion = "conversion"
+)
+
+// Finding is a problem of an invoice file reported by the offline commands
+type Finding struct {
+	Source   string `json:"source"`
+	RuleID   string `json:"ruleId,omitempty"`
+	Severity string `json:"severity"`
+	Path     string `json:"path"`
+	Message  string `json:"message"`
+}
+
+// Report is the machine readable result of validate
+type Report struct {
+	File     string    `json:"file"`
+	Valid    bool      `json:"valid"`
+	Findings []Finding `json:"findings"`
+}
+
+// document is an invoice file read by the offline commands
+type document struct {
+	body     *basware.InvoicesPostRequestBody
+	docType  basware.DocumentType
+	format   string
+	warnings basware.ConversionWarnings
+
+	// the JSON input without the tax category fields, to check against the
+	// schema
+	json []byte
+
+	// the JSON input is an invoice without request body
+	bare bool
+}
+
+// readDocument reads an invoice file of any supported format. The document
+// type of JSON files can't be detected and is taken from docType.
+func readDocument(path string, docType string) (*document, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	doc := &document{docType: basware.DocumentTypeInvoice}
+	switch docType {
+	case "", "invoice":
+	case "creditNote":
+		doc.docType = basware.DocumentTypeCreditNote
+	default:
+		return nil, fmt.Errorf("Invalid document type \"%s\"", docType)
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		doc.format = "json"
+		doc.body, doc.json, doc.bare, err = decodeBody(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid invoice %s: %s", path, err)
+		}
+		return doc, nil
+	}
+
+	var imported *basware.ImportedDocument
+	switch rootName(trimmed) {
+	case "Invoice", "CreditNote":
+		doc.format = "ubl"
+		imported, err = basware.ParseUBL(bytes.NewReader(trimmed))
+	case "CrossIndustryInvoice":
+		doc.format = "cii"
+		imported, err = basware.ParseCII(bytes.NewReader(trimmed))
+	default:
+		return nil, fmt.Errorf("Expected a Basware JSON, UBL or CII invoice in %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc.body = imported.InvoicesPostRequestBody()
+	doc.docType = imported.Type
+	doc.warnings = imported.Warnings
+	doc.json, err = json.Marshal(doc.body)
+	return doc, err
+}
+
+// decodeBody decodes a request body or a bare invoice, which is wrapped in a
+// request body and reported as bare. The invoice may have the tax category
+// fields of basware.MarshalInvoiceJSON.
+func decodeBody(data []byte) (*basware.InvoicesPostRequestBody, []byte, bool, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, nil, false, err
+	}
+
+	body := &basware.InvoicesPostRequestBody{}
+	if _, ok := fields["data"]; !ok {
+		if err := basware.UnmarshalInvoiceJSON(data, &body.Data); err != nil {
+			return nil, nil, true, err
+		}
+		stripped, err := basware.StripInvoiceJSON(data)
+		if err != nil {
+			return nil, nil, true, err
+		}
+		wrapped, err := json.Marshal(map[string]json.RawMessage{"clientToken": json.RawMessage(`""`), "data": stripped})
+		return body, wrapped, true, err
+	}
+
+	if err := json.Unmarshal(data, body); err != nil {
+		return nil, nil, false, err
+	}
+	if err := basware.UnmarshalInvoiceJSON(fields["data"], &body.Data); err != nil {
+		return nil, nil, false, err
+	}
+	stripped, err := basware.StripInvoiceJSON(fields["data"])
+	if err != nil {
+		return nil, nil, false, err
+	}
+	fields["data"] = stripped
+	data, err = json.Marshal(fields)
+	return body, data, false, err
+}
+
+// encodeBody encodes the request body, or only the invoice when bare, as
+// indented JSON with the tax category fields
+func encodeBody(body *basware.InvoicesPostRequestBody, bare bool) ([]byte, error) {
+	data, err := basware.MarshalInvoiceJSON(body.Data)
+	if err != nil {
+		return nil, err
+	}
+	if !bare {
+		wrapped, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(wrapped, &fields); err != nil {
+			return nil, err
+		}
+		fields["data"] = data
+		if data, err = json.Marshal(fields); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := json.Indent(buf, data, "", "  "); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// rootName returns the local name of the root element of an XML document
+func rootName(data []byte) string {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local
+		}
+	}
+}
+
+func conversionFindings(warnings basware.ConversionWarnings) []Finding {
+	findings := []Finding{}
+	for _, w := range warnings {
+		findings = append(findings, Finding{Source: findingConversion, Severity: string(basware.RuleSeverityWarning), Path: w.Path, Message: w.Message})
+	}
+	return findings
+}
+
+// validate checks an invoice against the schema of the API, EN 16931, the
+// identifier rules and the CIUS rule sets. It fails when there are schema
+// errors or fatal rule violations.
+func validate(ctx context.Context, e *env, args []string) error {
+	flags := newFlagSet("validate", e)
+	rules := flags.String("rules", "buyer", "extra rule sets, comma separated; buyer uses the profiles of the buyer's country")
+	docType := flags.String("type", "invoice", "document type of JSON files: invoice or creditNote")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Expected a file, got %d arguments", flags.NArg())
+	}
+
+	doc, err := readDocument(flags.Arg(0), *docType)
+	if err != nil {
+		return err
+	}
+
+	extra := []basware.RuleSet{}
+	for _, name := range strings.Split(*rules, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "", "none":
+		case "buyer":
+			extra = append(extra, basware.CountryProfile(doc.body.Data.AccountingCustomerParty.PostalAddress.CountryID)...)
+		default:
+			rs, ok := ruleSets[name]
+			if !ok {
+				return fmt.Errorf("Unknown rule set \"%s\"", name)
+			}
+			extra = append(extra, rs)
+		}
+	}
+
+	report := Report{File: flags.Arg(0), Valid: true, Findings: conversionFindings(doc.warnings)}
+	for _, v := range basware.ValidateInvoicesPostRequestJSON(doc.json) {
+		report.Valid = false
+		report.Findings = append(report.Findings, Finding{Source: findingSchema, Severity: string(basware.RuleSeverityFatal), Path: v.FieldID, Message: v.FieldMessage})
+	}
+	for _, v := range doc.body.Validate(extra...) {
+		if v.Severity == basware.RuleSeverityFatal {
+			report.Valid = false
+		}
+		report.Findings = append(report.Findings, Finding{Source: findingRule, RuleID: v.RuleID, Severity: string(v.Severity), Path: v.Path, Message: v.Message})
+	}
+
+	rows := [][]string{}
+	for _, f := range report.Findings {
+		rows = append(rows, []string{f.Severity, f.Source, f.RuleID, f.Path, f.Message})
+	}
+	if err := e.out.print(report, []string{"SEVERITY", "SOURCE", "RULE", "PATH", "MESSAGE"}, rows); err != nil {
+		return err
+	}
+	if !report.Valid {
+		return fmt.Errorf("%s is invalid", flags.Arg(0))
+	}
+	return nil
+}
+
+// totals calculates the tax breakdown and totals from the lines. With -fix the
+// corrected request body is written.
+func totals(ctx context.Context, e *env, args []string) error {
+	flags := newFlagSet("totals", e)
+	fix := flags.Bool("fix", false, "write the invoice with the calculated totals")
+	out := flags.String("o", "", "file to write the fixed invoice to, defaults to the input file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Expected a file, got %d arguments", flags.NArg())
+	}
+
+	doc, err := readDocument(flags.Arg(0), "")
+	if err != nil {
+		return err
+	}
+	if doc.format != "json" {
+		return fmt.Errorf("Expected a Basware JSON invoice, got %s; convert it first", doc.format)
+	}
+
+	before := doc.body.Data
+	after := before
+	after.CalculateTotals()
+
+	type total struct {
+		Field      string  `json:"field"`
+		Invoice    float64 `json:"invoice"`
+		Calculated float64 `json:"calculated"`
+		Changed    bool    `json:"changed"`
+	}
+	result := []total{
+		{Field: "legalMonetaryTotal.lineExtensionAmount", Invoice: before.LegalMonetaryTotal.LineExtensionAmount.Amount, Calculated: after.LegalMonetaryTotal.LineExtensionAmount.Amount},
+		{Field: "taxTotal.amount", Invoice: before.TaxTotal.Amount, Calculated: after.TaxTotal.Amount},
+		{Field: "legalMonetaryTotal.payableAmount", Invoice: before.LegalMonetaryTotal.PayableAmount.Amount, Calculated: after.LegalMonetaryTotal.PayableAmount.Amount},
+	}
+	rows := [][]string{}
+	for i, t := range result {
+		result[i].Changed = t.Invoice != t.Calculated
+		rows = append(rows, []string{t.Field, formatDecimal(t.Invoice), formatDecimal(t.Calculated), fmt.Sprint(result[i].Changed)})
+	}
+
+	if *fix {
+		// the fixed invoice keeps the shape of the input
+		doc.body.Data = after
+		data, err := encodeBody(doc.body, doc.bare)
+		if err != nil {
+			return err
+		}
+		path := *out
+		if path == "" {
+			path = flags.Arg(0)
+		}
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+	}
+	return e.out.print(result, []string{"FIELD", "INVOICE", "CALCULATED", "CHANGED"}, rows)
+}
+
+// convert writes an invoice in another format. Information that's lost is
+// reported on stderr.
+func convert(ctx context.Context, e *env, args []string) error {
+	flags := newFlagSet("convert", e)
+	to := flags.String("to", "", "output format: json, ubl or cii")
+	docType := flags.String("type", "invoice", "document type of JSON files: invoice or creditNote")
+	out := flags.String("o", "", "output file, defaults to stdout")
+	strict := flags.Bool("strict", false, "fail when information is lost")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Expected a file, got %d arguments", flags.NArg())
+	}
+
+	doc, err := readDocument(flags.Arg(0), *docType)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	warnings := doc.warnings
+	switch *to {
+	case "json":
+		data, err = encodeBody(doc.body, false)
+	case "ubl":
+		var w basware.ConversionWarnings
+		data, w, err = basware.MarshalUBL(doc.body.Data, doc.docType)
+		warnings = append(warnings, w...)
+	case "cii":
+		var w basware.ConversionWarnings
+		data, w, err = basware.MarshalCII(doc.body.Data, doc.docType)
+		warnings = append(warnings, w...)
+	default:
+		return fmt.Errorf("Invalid output format \"%s\", expected json, ubl or cii", *to)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := writeFindings(e, conversionFindings(warnings)); err != nil {
+		return err
+	}
+	if *strict && len(warnings) > 0 {
+		return fmt.Errorf("%d conversion warnings", len(warnings))
+	}
+	return writeOutput(e, *out, data)
+}
+
+// render writes the invoice as PDF, optionally as Factur-X with the CII
+// embedded
+func render(ctx context.Context, e *env, args []string) error {
+	flags := newFlagSet("render", e)
+	lang := flags.String("lang", "en", "language: en, fi, sv, de or nl")
+	facturX := flags.Bool("facturx", false, "embed the invoice as Factur-X")
+	docType := flags.String("type", "invoice", "document type of JSON files: invoice or creditNote")
+	out := flags.String("o", "", "PDF file to write")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Expected a file, got %d arguments", flags.NArg())
+	}
+	if *out == "" {
+		return fmt.Errorf("Expected an output file")
+	}
+
+	doc, err := readDocument(flags.Arg(0), *docType)
+	if err != nil {
+		return err
+	}
+	pdf, err := basware.RenderPDF(doc.body.Data, doc.docType, basware.RenderOptions{Language: *lang})
+	if err != nil {
+		return err
+	}
+
+	warnings := doc.warnings
+	if *facturX {
+		var w basware.ConversionWarnings
+		pdf, w, err = basware.NewFacturX(pdf, doc.body.Data, doc.docType)
+		if err != nil {
+			return err
+		}
+		warnings = append(warnings, w...)
+	}
+	if err := writeFindings(e, conversionFindings(warnings)); err != nil {
+		return err
+	}
+	return writeOutput(e, *out, pdf)
+}
+
+// writeFindings reports findings on stderr so stdout can carry the output
+// document; as JSON lines with -output json
+func writeFindings(e *env, findings []Finding) error {
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Path < findings[j].Path })
+	for _, f := range findings {
+		if e.out.format == formatJSON {
+			data, err := json.Marshal(f)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(e.stderr, "%s\n", data)
+			continue
+		}
+		fmt.Fprintf(e.stderr, "%s: %s: %s\n", f.Severity, f.Path, f.Message)
+	}
+	return nil
+}
+
+func writeOutput(e *env, path string, data []byte) error {
+	if path == "" || path == "-" {
+		_, err := e.out.w.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// formatDecimal formats an amount without trailing zeros
+func formatDecimal(f float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", f), "0"), ".")
+}
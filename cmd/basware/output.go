@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output formats
+const (
+	formatJSON  = "json"
+	formatTable = "table"
+)
+
+type output struct {
+	format string
+	w      io.Writer
+}
+
+// print writes v as indented JSON or the rows as table with a header
+func (o *output) print(v interface{}, header []string, rows [][]string) error {
+	if o.format == formatJSON {
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// printFields writes v as indented JSON or the fields as name value table
+func (o *output) printFields(v interface{}, fields [][2]string) error {
+	rows := [][]string{}
+	for _, f := range fields {
+		if f[1] != "" {
+			rows = append(rows, []string{f[0], f[1]})
+		}
+	}
+	return o.print(v, []string{"FIELD", "VALUE"}, rows)
+}
@@ -0,0 +1,390 @@
+package basware
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DocumentType is the kind of business document a structured e-invoice
+// represents.
+type DocumentType string
+
+const (
+	DocumentTypeInvoice    DocumentType = "Invoice"
+	DocumentTypeCreditNote DocumentType = "CreditNote"
+)
+
+// ImportedDocument is the result of converting a structured e-invoice (UBL,
+// CII) into the Basware invoice model.
+type ImportedDocument struct {
+	// Invoice or CreditNote
+	Type DocumentType
+
+	// The converted business content
+	Invoice Invoice
+
+	// Information from the source document that could not be converted
+	Warnings ConversionWarnings
+}
+
+// IsCreditNote reports whether the source document was a credit note
+func (d *ImportedDocument) IsCreditNote() bool {
+	return d.Type == DocumentTypeCreditNote
+}
+
+// InvoicesPostRequestBody wraps the converted invoice in a request body that
+// can be sent with InvoicesService.Post
+func (d *ImportedDocument) InvoicesPostRequestBody() *InvoicesPostRequestBody {
+	return &InvoicesPostRequestBody{
+		Data: d.Invoice,
+	}
+}
+
+// ConversionWarning describes information that was lost or changed while
+// converting between document formats.
+type ConversionWarning struct {
+	// Location in the source document, e.g. Invoice/Delivery/DeliveryLocation/ID
+	Path string
+
+	Message string
+}
+
+func (w ConversionWarning) String() string {
+	return fmt.Sprintf("%s: %s", w.Path, w.Message)
+}
+
+type ConversionWarnings []ConversionWarning
+
+func (ws ConversionWarnings) String() string {
+	lines := make([]string, len(ws))
+	for i, w := range ws {
+		lines[i] = w.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// party is the common denominator of AccountingSupplierParty,
+// AccountingCustomerParty and DeliveryParty. The converters map parties
+// through this type so all formats share the same party mapping.
+type party struct {
+	Endpoint            Endpoint
+	PartyIdentification []PartyIdentificationItem
+	PartyName           string
+	PostalAddress       PostalAddress
+	Contact             Contact
+	PartyTaxScheme      PartyTaxScheme
+
+	AdditionalPartyTaxSchemes []PartyTaxScheme
+	PartyLegalEntities        []PartyLegalEntity
+}
+
+func (p AccountingSupplierParty) party() party {
+	return party{
+		Endpoint:            p.Endpoint,
+		PartyIdentification: p.PartyIdentification,
+		PartyName:           p.PartyName,
+		PostalAddress:       p.PostalAddress,
+		Contact:             p.Contact,
+		PartyTaxScheme:      p.PartyTaxScheme,
+
+		AdditionalPartyTaxSchemes: p.AdditionalPartyTaxSchemes,
+		PartyLegalEntities:        p.PartyLegalEntities,
+	}
+}
+
+func (p party) accountingSupplierParty() AccountingSupplierParty {
+	return AccountingSupplierParty{
+		Endpoint:            p.Endpoint,
+		PartyIdentification: p.PartyIdentification,
+		PartyName:           p.PartyName,
+		PostalAddress:       p.PostalAddress,
+		Contact:             p.Contact,
+		PartyTaxScheme:      p.PartyTaxScheme,
+
+		AdditionalPartyTaxSchemes: p.AdditionalPartyTaxSchemes,
+		PartyLegalEntities:        p.PartyLegalEntities,
+	}
+}
+
+func (p AccountingCustomerParty) party() party {
+	return party{
+		Endpoint:            p.Endpoint,
+		PartyIdentification: p.PartyIdentification,
+		PartyName:           p.PartyName,
+		PostalAddress:       p.PostalAddress,
+		Contact:             p.Contact,
+		PartyTaxScheme:      p.PartyTaxScheme,
+
+		AdditionalPartyTaxSchemes: p.AdditionalPartyTaxSchemes,
+		PartyLegalEntities:        p.PartyLegalEntities,
+	}
+}
+
+func (p party) accountingCustomerParty() AccountingCustomerParty {
+	return AccountingCustomerParty{
+		Endpoint:            p.Endpoint,
+		PartyIdentification: p.PartyIdentification,
+		PartyName:           p.PartyName,
+		PostalAddress:       p.PostalAddress,
+		Contact:             p.Contact,
+		PartyTaxScheme:      p.PartyTaxScheme,
+
+		AdditionalPartyTaxSchemes: p.AdditionalPartyTaxSchemes,
+		PartyLegalEntities:        p.PartyLegalEntities,
+	}
+}
+
+func (p DeliveryParty) party() party {
+	return party{
+		Endpoint:            p.Endpoint,
+		PartyIdentification: p.PartyIdentification,
+		PartyName:           p.PartyName,
+		PostalAddress:       p.PostalAddress,
+		Contact:             p.Contact,
+		PartyTaxScheme:      p.PartyTaxScheme,
+
+		AdditionalPartyTaxSchemes: p.AdditionalPartyTaxSchemes,
+		PartyLegalEntities:        p.PartyLegalEntities,
+	}
+}
+
+func (p party) deliveryParty() DeliveryParty {
+	return DeliveryParty{
+		Endpoint:            p.Endpoint,
+		PartyIdentification: p.PartyIdentification,
+		PartyName:           p.PartyName,
+		PostalAddress:       p.PostalAddress,
+		Contact:             p.Contact,
+		PartyTaxScheme:      p.PartyTaxScheme,
+
+		AdditionalPartyTaxSchemes: p.AdditionalPartyTaxSchemes,
+		PartyLegalEntities:        p.PartyLegalEntities,
+	}
+}
+
+const (
+	// UNCL 7161 reason codes of the document level charges Basware supports
+	chargeReasonFreight  = "FC"
+	chargeReasonHandling = "HD"
+)
+
+// addCharge adds a document level allowance or charge to ac. It returns false
+// for the allowances and charges Basware has no field for.
+func addCharge(ac *AllowanceCharge, charge bool, reasonCode string, amount float64) bool {
+	if !charge {
+		return false
+	}
+
+	switch reasonCode {
+	case chargeReasonFreight:
+		ac.Freight = ac.Freight + amount
+	case chargeReasonHandling:
+		ac.Handling = ac.Handling + amount
+	default:
+		return false
+	}
+	return true
+}
+
+// charge is a document level charge in the form the structured formats use
+type charge struct {
+	ReasonCode string
+	Reason     string
+	Amount     float64
+}
+
+// charges returns the Basware freight and handling charges as separate
+// document level charges
+func (ac AllowanceCharge) charges() []charge {
+	charges := []charge{}
+	if ac.Freight != 0 {
+		charges = append(charges, charge{ReasonCode: chargeReasonFreight, Reason: "Freight", Amount: ac.Freight})
+	}
+	if ac.Handling != 0 {
+		charges = append(charges, charge{ReasonCode: chargeReasonHandling, Reason: "Handling", Amount: ac.Handling})
+	}
+	return charges
+}
+
+// newFinancialAccount creates a payee account from the account identifier and
+// the optional BIC and bank name every format carries
+func newFinancialAccount(id string, idSchemeID string, bic string, bankName string) FinancialAccountItem {
+	fa := FinancialAccountItem{
+		Ids: []ID{{
+			ID:       id,
+			SchemeID: idSchemeID,
+		}},
+		FinancialInstitutionName: bankName,
+	}
+	if bic != "" {
+		fa.FinancialInstitutionID = bic
+		fa.FinancialInstitutionIDSchemeID = "BIC"
+	}
+	return fa
+}
+
+// accountID returns the first account identifier of the account
+func (fa FinancialAccountItem) accountID() ID {
+	if len(fa.Ids) == 0 {
+		return ID{}
+	}
+	return fa.Ids[0]
+}
+
+// lineTaxTotal converts a tax total read from a line into the Basware line tax
+// total
+func lineTaxTotal(t TaxTotal) TaxTotalItem {
+	return TaxTotalItem{
+		Amount:      t.Amount,
+		CurrencyID:  t.CurrencyID,
+		TaxSubTotal: t.TaxSubTotal,
+	}
+}
+
+// impliedTaxCategory returns the UNCL 5305 tax category the Basware model
+// implies for a rate: standard rated (S) when a rate is given, zero rated (Z)
+// otherwise
+func impliedTaxCategory(percent float64) string {
+	if percent != 0 {
+		return "S"
+	}
+	return "Z"
+}
+
+// taxCategory reads the tax category of a source document. Categories that
+// follow from the rate are left out as the Basware model implies them.
+func taxCategory(n *xmlNode, percent float64) string {
+	category := n.text()
+	if category == impliedTaxCategory(percent) {
+		return ""
+	}
+	return category
+}
+
+// taxScheme reads the tax scheme of a source document, VAT is implied
+func taxScheme(n *xmlNode) string {
+	scheme := n.text()
+	if scheme == TaxSchemeVAT {
+		return ""
+	}
+	return scheme
+}
+
+func (p party) isZero() bool {
+	return reflect.DeepEqual(p, party{})
+}
+
+// formatAmount formats a monetary amount with two decimals
+func formatAmount(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+// formatDecimal formats quantities, prices and percentages without trailing
+// zeros
+func formatDecimal(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// looksLikeIBAN reports whether an account identifier without a scheme starts
+// with a country code and check digits
+func looksLikeIBAN(s string) bool {
+	s = strings.Replace(s, " ", "", -1)
+	if len(s) < 15 {
+		return false
+	}
+	for i, r := range s[0:4] {
+		if i < 2 && (r < 'A' || r > 'Z') {
+			return false
+		}
+		if i >= 2 && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// splitNonEmpty splits free text into its non empty lines
+func splitNonEmpty(s string) []string {
+	lines := []string{}
+	for _, l := range strings.Split(s, "\n") {
+		if strings.TrimSpace(l) != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// converter holds the state shared by the document converters: the warnings
+// collected so far and the first hard error.
+type converter struct {
+	warnings ConversionWarnings
+	err      error
+}
+
+func (c *converter) warn(path string, format string, args ...interface{}) {
+	c.warnings = append(c.warnings, ConversionWarning{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// decimal parses a decimal value from the source document. Parse errors are
+// kept and reported once the conversion is done.
+func (c *converter) decimal(path string, value string) float64 {
+	if value == "" {
+		return 0
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil && c.err == nil {
+		c.err = fmt.Errorf("%s: invalid decimal value \"%s\"", path, value)
+	}
+	return f
+}
+
+// reportUnused adds a warning for every element of the source document that
+// wasn't mapped
+func (c *converter) reportUnused(root *xmlNode) {
+	for _, path := range root.unused() {
+		c.warn(path, "element has no Basware equivalent and was dropped")
+	}
+}
+
+// categoryCode writes the tax category implied by the rate
+func (c *converter) categoryCode(path string, category string, percent float64) string {
+	if category != "" {
+		return category
+	}
+
+	category = impliedTaxCategory(percent)
+	if percent == 0 {
+		c.warn(path, "tax category is not part of the invoice, zero rated (%s) was assumed", category)
+	}
+	return category
+}
+
+// chargeTax returns the breakdown the document level charges are taxed in,
+// whose VAT category and rate the charges need (BR-37). Without a breakdown
+// the category is unknown and a warning is added.
+func (c *converter) chargeTax(path string, inv Invoice) (TaxSubTotalItem, bool) {
+	i := chargeBreakdown(inv.TaxTotal.TaxSubTotal)
+	if i < 0 {
+		c.warn(path, "no tax breakdown to take the VAT category of the charges from, category left out")
+		return TaxSubTotalItem{}, false
+	}
+	return inv.TaxTotal.TaxSubTotal[i], true
+}
+
+// chargeBreakdown returns the index of the breakdown the document level
+// charges are taxed in: the first one with the highest rate
+func chargeBreakdown(subs []TaxSubTotalItem) int {
+	index := -1
+	for i, sub := range subs {
+		if index < 0 || sub.Percent > subs[index].Percent {
+			index = i
+		}
+	}
+	return index
+}
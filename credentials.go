@@ -0,0 +1,126 @@
+package basware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// Credentials of the API: username and password of the basic authentication,
+// or the client id and secret of OAuth2
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// CredentialsProvider supplies the credentials. It's consulted for every
+// request, so rotated credentials are used without rebuilding the client.
+// Implementations must be safe for concurrent use.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// StaticCredentials provides fixed credentials
+type StaticCredentials Credentials
+
+func (p StaticCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	return Credentials(p), nil
+}
+
+// EnvCredentials reads the credentials from environment variables
+type EnvCredentials struct {
+	UsernameVar string
+	PasswordVar string
+}
+
+// NewEnvCredentials reads the credentials from BASWARE_USERNAME and
+// BASWARE_PASSWORD
+func NewEnvCredentials() EnvCredentials {
+	return EnvCredentials{UsernameVar: "BASWARE_USERNAME", PasswordVar: "BASWARE_PASSWORD"}
+}
+
+func (p EnvCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	credentials := Credentials{
+		Username: os.Getenv(p.UsernameVar),
+		Password: os.Getenv(p.PasswordVar),
+	}
+	if credentials.Username == "" || credentials.Password == "" {
+		return Credentials{}, fmt.Errorf("Expected credentials in %s and %s", p.UsernameVar, p.PasswordVar)
+	}
+	return credentials, nil
+}
+
+// FileCredentials reads the credentials from a JSON file:
+//
+//	{"username": "...", "password": "..."}
+//
+// The file is read again when it changed, so it can be replaced to rotate
+// the credentials.
+type FileCredentials struct {
+	Path string
+
+	mu          sync.Mutex
+	modTime     time.Time
+	size        int64
+	credentials *Credentials
+}
+
+// NewFileCredentials returns a provider reading the file
+func NewFileCredentials(path string) *FileCredentials {
+	return &FileCredentials{Path: path}
+}
+
+func (p *FileCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.credentials != nil && info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return *p.credentials, nil
+	}
+
+	data, err := ioutil.ReadFile(p.Path)
+	if err != nil {
+		return Credentials{}, err
+	}
+	credentials := Credentials{}
+	if err := json.Unmarshal(data, &credentials); err != nil {
+		return Credentials{}, fmt.Errorf("Invalid credentials file %s: %s", p.Path, err)
+	}
+	if credentials.Username == "" || credentials.Password == "" {
+		return Credentials{}, fmt.Errorf("Expected a username and password in %s", p.Path)
+	}
+
+	p.credentials = &credentials
+	p.modTime = info.ModTime()
+	p.size = info.Size()
+	return credentials, nil
+}
+
+// ChainCredentials returns the credentials of the first provider that has
+// them
+type ChainCredentials []CredentialsProvider
+
+func (providers ChainCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	var errs error
+	for _, p := range providers {
+		credentials, err := p.Credentials(ctx)
+		if err == nil {
+			return credentials, nil
+		}
+		errs = multierror.Append(errs, err)
+	}
+	if errs == nil {
+		return Credentials{}, fmt.Errorf("Expected a credentials provider")
+	}
+	return Credentials{}, errs
+}
@@ -0,0 +1,119 @@
+package basware_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	basware "github.com/tim-online/go-basware"
+	"github.com/tim-online/go-basware/baswaretest"
+)
+
+func TestFileCredentialsRotation(t *testing.T) {
+	server := baswaretest.NewServer()
+	defer server.Close()
+	server.Username, server.Password = "user", "old"
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	ioutil.WriteFile(path, []byte(`{"username": "user", "password": "old"}`), 0600)
+	client := server.NewClient()
+	client.SetCredentialsProvider(basware.NewFileCredentials(path))
+	ctx := context.Background()
+
+	if _, err := client.Notifications.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// rotate the secret at the server and in the file
+	server.Password = "new"
+	ioutil.WriteFile(path, []byte(`{"username": "user", "password": "new"}`), 0600)
+	later := time.Now().Add(time.Second)
+	os.Chtimes(path, later, later)
+
+	// requests during the rotation use either the old or new credentials
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Notifications.List(ctx)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	os.Remove(path)
+	if _, err := client.Notifications.List(ctx); err == nil {
+		t.Error("Expected an error without credentials file")
+	}
+}
+
+func TestChainCredentials(t *testing.T) {
+	t.Setenv("BASWARE_TEST_USERNAME", "")
+	chain := basware.ChainCredentials{
+		basware.EnvCredentials{UsernameVar: "BASWARE_TEST_USERNAME", PasswordVar: "BASWARE_TEST_PASSWORD"},
+		basware.NewFileCredentials(filepath.Join(t.TempDir(), "missing.json")),
+		basware.StaticCredentials{Username: "user", Password: "secret"},
+	}
+	credentials, err := chain.Credentials(context.Background())
+	if err != nil || credentials.Username != "user" || credentials.Password != "secret" {
+		t.Errorf("Expected the static credentials, got %+v (%v)", credentials, err)
+	}
+
+	t.Setenv("BASWARE_TEST_USERNAME", "env-user")
+	t.Setenv("BASWARE_TEST_PASSWORD", "env-secret")
+	credentials, err = chain.Credentials(context.Background())
+	if err != nil || credentials.Username != "env-user" {
+		t.Errorf("Expected the environment credentials, got %+v (%v)", credentials, err)
+	}
+
+	_, err = chain[1:2].Credentials(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "missing.json") {
+		t.Errorf("Expected the file error, got %v", err)
+	}
+}
+
+// rotatingCredentials provides the old password once and the new one after
+type rotatingCredentials struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *rotatingCredentials) Credentials(ctx context.Context) (basware.Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.calls == 1 {
+		return basware.Credentials{Username: "user", Password: "old"}, nil
+	}
+	return basware.Credentials{Username: "user", Password: "new"}, nil
+}
+
+func TestBasicAuthReauthenticate(t *testing.T) {
+	server := baswaretest.NewServer()
+	defer server.Close()
+	server.Username, server.Password = "user", "new"
+	client := server.NewClient()
+	provider := &rotatingCredentials{}
+	client.SetCredentialsProvider(provider)
+
+	// the request rejected with the old password is sent with the new one
+	if _, err := client.Notifications.List(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if provider.calls != 3 {
+		t.Errorf("Expected the credentials to be read again, got %d reads", provider.calls)
+	}
+}
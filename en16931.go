@@ -0,0 +1,614 @@
+package basware
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// EN16931RuleSet holds the EN 16931 business rules that can be evaluated on
+// the Basware invoice model: the core rules (BR-xx), the calculation rules
+// (BR-CO-xx) and the rules of the VAT categories (BR-S/Z/E/AE/IC/G/O-xx).
+var EN16931RuleSet = RuleSet{
+	Name: "EN16931",
+	Rules: append([]Rule{
+		{
+			ID:       "BR-02",
+			Severity: RuleSeverityFatal,
+			Message:  "An Invoice shall have an Invoice number",
+			Check: func(inv Invoice) []string {
+				return requiredField("id", inv.ID)
+			},
+		},
+		{
+			ID:       "BR-03",
+			Severity: RuleSeverityFatal,
+			Message:  "An Invoice shall have an Invoice issue date",
+			Check: func(inv Invoice) []string {
+				return requiredField("issueDate", inv.IssueDate)
+			},
+		},
+		{
+			ID:       "BR-05",
+			Severity: RuleSeverityFatal,
+			Message:  "An Invoice shall have an Invoice currency code",
+			Check: func(inv Invoice) []string {
+				return requiredField("documentCurrencyCode", inv.DocumentCurrencyCode)
+			},
+		},
+		{
+			ID:       "BR-06",
+			Severity: RuleSeverityFatal,
+			Message:  "An Invoice shall contain the Seller name",
+			Check: func(inv Invoice) []string {
+				return requiredField("accountingSupplierParty.partyName", inv.AccountingSupplierParty.PartyName)
+			},
+		},
+		{
+			ID:       "BR-07",
+			Severity: RuleSeverityFatal,
+			Message:  "An Invoice shall contain the Buyer name",
+			Check: func(inv Invoice) []string {
+				return requiredField("accountingCustomerParty.partyName", inv.AccountingCustomerParty.PartyName)
+			},
+		},
+		{
+			ID:       "BR-08",
+			Severity: RuleSeverityFatal,
+			Message:  "An Invoice shall contain the Seller postal address",
+			Check: func(inv Invoice) []string {
+				if inv.AccountingSupplierParty.PostalAddress == (PostalAddress{}) {
+					return []string{"accountingSupplierParty.postalAddress"}
+				}
+				return nil
+			},
+		},
+		{
+			ID:       "BR-09",
+			Severity: RuleSeverityFatal,
+			Message:  "The Seller postal address shall contain a Seller country code",
+			Check: func(inv Invoice) []string {
+				return requiredField("accountingSupplierParty.postalAddress.countryId", inv.AccountingSupplierParty.PostalAddress.CountryID)
+			},
+		},
+		{
+			ID:       "BR-10",
+			Severity: RuleSeverityFatal,
+			Message:  "An Invoice shall contain the Buyer postal address",
+			Check: func(inv Invoice) []string {
+				if inv.AccountingCustomerParty.PostalAddress == (PostalAddress{}) {
+					return []string{"accountingCustomerParty.postalAddress"}
+				}
+				return nil
+			},
+		},
+		{
+			ID:       "BR-11",
+			Severity: RuleSeverityFatal,
+			Message:  "The Buyer postal address shall contain a Buyer country code",
+			Check: func(inv Invoice) []string {
+				return requiredField("accountingCustomerParty.postalAddress.countryId", inv.AccountingCustomerParty.PostalAddress.CountryID)
+			},
+		},
+		{
+			ID:       "BR-15",
+			Severity: RuleSeverityFatal,
+			Message:  "An Invoice shall have the Amount due for payment",
+			Check: func(inv Invoice) []string {
+				return requiredField("legalMonetaryTotal.payableAmount.currencyId", inv.LegalMonetaryTotal.PayableAmount.CurrencyID)
+			},
+		},
+		{
+			ID:       "BR-16",
+			Severity: RuleSeverityFatal,
+			Message:  "An Invoice shall have at least one Invoice line",
+			Check: func(inv Invoice) []string {
+				if len(inv.InvoiceLine) == 0 {
+					return []string{"invoiceLine"}
+				}
+				return nil
+			},
+		},
+		{
+			ID:       "BR-21",
+			Severity: RuleSeverityFatal,
+			Message:  "Each Invoice line shall have an Invoice line identifier",
+			Check: eachLine(func(path string, l InvoiceLine) []string {
+				return requiredField(path+".id", l.ID)
+			}),
+		},
+		{
+			ID:       "BR-22",
+			Severity: RuleSeverityFatal,
+			Message:  "Each Invoice line shall have an Invoiced quantity",
+			Check: eachLine(func(path string, l InvoiceLine) []string {
+				if l.Quantity.Amount == 0 {
+					return []string{path + ".quantity.amount"}
+				}
+				return nil
+			}),
+		},
+		{
+			ID:       "BR-23",
+			Severity: RuleSeverityFatal,
+			Message:  "An Invoice line shall have an Invoiced quantity unit of measure code",
+			Check: eachLine(func(path string, l InvoiceLine) []string {
+				return requiredField(path+".quantity.unitCode", l.Quantity.UnitCode)
+			}),
+		},
+		{
+			ID:       "BR-25",
+			Severity: RuleSeverityFatal,
+			Message:  "Each Invoice line shall contain the Item name",
+			Check: eachLine(func(path string, l InvoiceLine) []string {
+				return requiredField(path+".item.name", l.Item.Name)
+			}),
+		},
+		{
+			ID:       "BR-27",
+			Severity: RuleSeverityFatal,
+			Message:  "The Item net price shall NOT be negative",
+			Check: eachLine(func(path string, l InvoiceLine) []string {
+				if l.Price.Amount < 0 {
+					return []string{path + ".price.amount"}
+				}
+				return nil
+			}),
+		},
+		{
+			ID:       "BR-49",
+			Severity: RuleSeverityFatal,
+			Message:  "A Payment instruction shall specify the Payment means type code",
+			Check: func(inv Invoice) []string {
+				pm := inv.PaymentMeans
+				if pm.PaymentMeansCode == "" && (len(pm.FinancialAccount) > 0 || pm.PaymentIdentifier.ID != "") {
+					return []string{"paymentMeans.paymentMeansCode"}
+				}
+				return nil
+			},
+		},
+		{
+			ID:       "BR-61",
+			Severity: RuleSeverityFatal,
+			Message:  "If the Payment means type code means SEPA credit transfer, Local credit transfer or Non-SEPA international credit transfer, the Payment account identifier shall be present",
+			Check: func(inv Invoice) []string {
+				pm := inv.PaymentMeans
+				if !isCreditTransfer(pm.PaymentMeansCode) {
+					return nil
+				}
+				for _, fa := range pm.FinancialAccount {
+					if fa.accountID().ID != "" {
+						return nil
+					}
+				}
+				return []string{"paymentMeans.financialAccount"}
+			},
+		},
+		{
+			ID:       "BR-CO-9",
+			Severity: RuleSeverityFatal,
+			Message:  "The Seller VAT identifier and the Buyer VAT identifier shall have a prefix in accordance with ISO code ISO 3166-1 alpha-2 by which the country of issue may be identified",
+			Check: func(inv Invoice) []string {
+				paths := []string{}
+				parties := []struct {
+					path string
+					id   string
+				}{
+					{"accountingSupplierParty.partyTaxScheme.company.id", inv.AccountingSupplierParty.party().vatIdentifier()},
+					{"accountingCustomerParty.partyTaxScheme.company.id", inv.AccountingCustomerParty.party().vatIdentifier()},
+				}
+				for _, p := range parties {
+					if p.id != "" && !vatIdentifierPrefix.MatchString(p.id) {
+						paths = append(paths, p.path)
+					}
+				}
+				return paths
+			},
+		},
+		{
+			ID:       "BR-CO-10",
+			Severity: RuleSeverityFatal,
+			Message:  "Sum of Invoice line net amount = Σ Invoice line net amount",
+			Check: func(inv Invoice) []string {
+				sum := 0.0
+				for _, l := range inv.InvoiceLine {
+					sum += l.LineExtension.Amount
+				}
+				if !amountsEqual(sum, inv.LegalMonetaryTotal.LineExtensionAmount.Amount) {
+					return []string{"legalMonetaryTotal.lineExtensionAmount.amount"}
+				}
+				return nil
+			},
+		},
+		{
+			ID:       "BR-CO-14",
+			Severity: RuleSeverityFatal,
+			Message:  "Invoice total VAT amount = Σ VAT category tax amount",
+			Check: func(inv Invoice) []string {
+				sum := 0.0
+				for _, sub := range inv.TaxTotal.TaxSubTotal {
+					sum += sub.Amount
+				}
+				if !amountsEqual(sum, inv.TaxTotal.Amount) {
+					return []string{"taxTotal.amount"}
+				}
+				return nil
+			},
+		},
+		{
+			// Basware has no prepaid or rounding amounts so the amount due
+			// is the invoice total with VAT (BR-CO-15)
+			ID:       "BR-CO-16",
+			Severity: RuleSeverityFatal,
+			Message:  "Amount due for payment = Sum of Invoice line net amount + Sum of charges on document level + Invoice total VAT amount",
+			Check: func(inv Invoice) []string {
+				total := inv.LegalMonetaryTotal.LineExtensionAmount.Amount + documentCharges(inv) + inv.TaxTotal.Amount
+				if !amountsEqual(total, inv.LegalMonetaryTotal.PayableAmount.Amount) {
+					return []string{"legalMonetaryTotal.payableAmount.amount"}
+				}
+				return nil
+			},
+		},
+		{
+			ID:       "BR-CO-17",
+			Severity: RuleSeverityFatal,
+			Message:  "VAT category tax amount = VAT category taxable amount x (VAT category rate / 100), rounded to two decimals",
+			Check: eachTaxSubTotal(func(path string, sub TaxSubTotalItem) []string {
+				if !amountsEqual(sub.TaxableAmount*sub.Percent/100, sub.Amount) {
+					return []string{path + ".amount"}
+				}
+				return nil
+			}),
+		},
+		{
+			ID:       "BR-CO-18",
+			Severity: RuleSeverityFatal,
+			Message:  "An Invoice shall at least have one VAT breakdown group",
+			Check: func(inv Invoice) []string {
+				if len(inv.TaxTotal.TaxSubTotal) == 0 {
+					return []string{"taxTotal.taxSubTotal"}
+				}
+				return nil
+			},
+		},
+		{
+			ID:       "BR-CO-25",
+			Severity: RuleSeverityFatal,
+			Message:  "In case the Amount due for payment is positive, either the Payment due date or the Payment terms shall be present",
+			Check: func(inv Invoice) []string {
+				if inv.LegalMonetaryTotal.PayableAmount.Amount > 0 && inv.PaymentMeans.PaymentDueDate == "" && inv.PaymentTerms.Note == "" {
+					return []string{"paymentMeans.paymentDueDate"}
+				}
+				return nil
+			},
+		},
+		{
+			ID:       "BR-CO-26",
+			Severity: RuleSeverityFatal,
+			Message:  "In order for the buyer to automatically identify a supplier, the Seller identifier, the Seller legal registration identifier and/or the Seller VAT identifier shall be present",
+			Check: func(inv Invoice) []string {
+				seller := inv.AccountingSupplierParty.party()
+				if seller.vatIdentifier() != "" {
+					return nil
+				}
+				for _, id := range seller.identifiers() {
+					if id.ID != "" {
+						return nil
+					}
+				}
+				return []string{"accountingSupplierParty.partyIdentification"}
+			},
+		},
+	},
+		vatCategoryRules...,
+	),
+}
+
+// vatCategory describes what EN 16931 requires of a VAT category
+type vatCategory struct {
+	code string
+
+	// prefix of the rule identifiers, e.g. BR-IC for intra-community supply
+	prefix string
+
+	name string
+
+	// the rate of the category is greater than zero, it's zero otherwise
+	positiveRate bool
+
+	// the seller must have a VAT identifier; it must not have one otherwise
+	sellerVATID bool
+
+	// the buyer must have a VAT identifier
+	buyerVATID bool
+
+	// the breakdown must state an exemption reason; it must not have one
+	// otherwise
+	exemptionReason bool
+}
+
+var vatCategories = []vatCategory{
+	{code: VATCategoryStandard, prefix: "BR-S", name: "Standard rated", positiveRate: true, sellerVATID: true},
+	{code: VATCategoryZero, prefix: "BR-Z", name: "Zero rated", sellerVATID: true},
+	{code: VATCategoryExempt, prefix: "BR-E", name: "Exempt from VAT", sellerVATID: true, exemptionReason: true},
+	{code: VATCategoryReverseCharge, prefix: "BR-AE", name: "Reverse charge", sellerVATID: true, buyerVATID: true, exemptionReason: true},
+	{code: VATCategoryIntraCommunity, prefix: "BR-IC", name: "Intra-community supply", sellerVATID: true, buyerVATID: true, exemptionReason: true},
+	{code: VATCategoryExport, prefix: "BR-G", name: "Export outside the EU", sellerVATID: true, exemptionReason: true},
+	{code: VATCategoryOutsideScope, prefix: "BR-O", name: "Not subject to VAT", exemptionReason: true},
+}
+
+var vatCategoryRules = func() []Rule {
+	rules := []Rule{}
+	for _, c := range vatCategories {
+		rules = append(rules, newVATCategoryRules(c)...)
+	}
+	return rules
+}()
+
+var vatIdentifierPrefix = regexp.MustCompile(`^[A-Z]{2}`)
+
+// newVATCategoryRules returns the rules every VAT category has in EN 16931:
+// the category needs a VAT breakdown (01) and VAT identifiers of the parties
+// (02), fixes the rate of the lines (05), determines the taxable and tax amount
+// of the breakdown (08, 09) and whether an exemption reason is given (10)
+func newVATCategoryRules(c vatCategory) []Rule {
+	id := func(n int) string {
+		return fmt.Sprintf("%s-%02d", c.prefix, n)
+	}
+
+	rate, tax, reason := "0 (zero)", "0 (zero)", "shall not have a VAT exemption reason code or VAT exemption reason text"
+	if c.positiveRate {
+		rate = "greater than zero"
+		tax = "the VAT category taxable amount multiplied by the VAT category rate"
+	}
+	if c.exemptionReason {
+		reason = "shall have a VAT exemption reason code or a VAT exemption reason text"
+	}
+
+	parties := "shall contain the Seller VAT Identifier"
+	switch {
+	case !c.sellerVATID:
+		parties = "shall not contain the Seller VAT identifier nor the Buyer VAT identifier"
+	case c.buyerVATID:
+		parties = "shall contain the Seller VAT Identifier and the Buyer VAT identifier"
+	}
+
+	rules := []Rule{
+		{
+			ID:       id(1),
+			Severity: RuleSeverityFatal,
+			Message:  fmt.Sprintf("An Invoice that contains an Invoice line where the Invoiced item VAT category code is \"%s\" shall contain at least one VAT breakdown group with the VAT category code \"%s\"", c.name, c.name),
+			Check: func(inv Invoice) []string {
+				if !hasLineTaxCategory(inv, c.code) {
+					return nil
+				}
+				for _, sub := range inv.TaxTotal.TaxSubTotal {
+					if sub.taxCategory() == c.code {
+						return nil
+					}
+				}
+				return []string{"taxTotal.taxSubTotal"}
+			},
+		},
+		{
+			ID:       id(2),
+			Severity: RuleSeverityFatal,
+			Message:  fmt.Sprintf("An Invoice that contains an Invoice line where the Invoiced item VAT category code is \"%s\" %s", c.name, parties),
+			Check: func(inv Invoice) []string {
+				if !hasLineTaxCategory(inv, c.code) {
+					return nil
+				}
+
+				seller := "accountingSupplierParty.partyTaxScheme.company.id"
+				buyer := "accountingCustomerParty.partyTaxScheme.company.id"
+				sellerID := inv.AccountingSupplierParty.party().vatIdentifier()
+				buyerID := inv.AccountingCustomerParty.party().vatIdentifier()
+				if !c.sellerVATID {
+					paths := []string{}
+					if sellerID != "" {
+						paths = append(paths, seller)
+					}
+					if buyerID != "" {
+						paths = append(paths, buyer)
+					}
+					return paths
+				}
+
+				paths := requiredField(seller, sellerID)
+				if c.buyerVATID {
+					paths = append(paths, requiredField(buyer, buyerID)...)
+				}
+				return paths
+			},
+		},
+		{
+			ID:       id(5),
+			Severity: RuleSeverityFatal,
+			Message:  fmt.Sprintf("In an Invoice line where the Invoiced item VAT category code is \"%s\" the Invoiced item VAT rate shall be %s", c.name, rate),
+			Check: eachLine(func(path string, l InvoiceLine) []string {
+				if l.taxCategory() != c.code {
+					return nil
+				}
+				if c.positiveRate != (l.Item.TaxPercent > 0) {
+					return []string{path + ".item.taxPercent"}
+				}
+				return nil
+			}),
+		},
+		{
+			ID:       id(8),
+			Severity: RuleSeverityFatal,
+			Message:  fmt.Sprintf("In a VAT breakdown where the VAT category code is \"%s\" the VAT category taxable amount shall equal the sum of Invoice line net amounts plus the charges on document level for that category", c.name),
+			Check: func(inv Invoice) []string {
+				paths := []string{}
+				checked := map[taxGroup]bool{}
+				for i, sub := range inv.TaxTotal.TaxSubTotal {
+					group := breakdownGroup(sub)
+					if group.category != c.code || checked[group] {
+						continue
+					}
+					checked[group] = true
+
+					expected, actual := taxableAmounts(inv, group)
+					if !amountsEqual(expected, actual) {
+						paths = append(paths, fmt.Sprintf("taxTotal.taxSubTotal[%d].taxableAmount", i))
+					}
+				}
+				return paths
+			},
+		},
+		{
+			ID:       id(9),
+			Severity: RuleSeverityFatal,
+			Message:  fmt.Sprintf("The VAT category tax amount in a VAT breakdown where the VAT category code is \"%s\" shall equal %s", c.name, tax),
+			Check: eachTaxSubTotal(func(path string, sub TaxSubTotalItem) []string {
+				if sub.taxCategory() != c.code {
+					return nil
+				}
+				expected := 0.0
+				if c.positiveRate {
+					expected = sub.TaxableAmount * sub.Percent / 100
+				}
+				if !amountsEqual(expected, sub.Amount) {
+					return []string{path + ".amount"}
+				}
+				return nil
+			}),
+		},
+		{
+			ID:       id(10),
+			Severity: RuleSeverityFatal,
+			Message:  fmt.Sprintf("A VAT breakdown with VAT category code \"%s\" %s", c.name, reason),
+			Check: eachTaxSubTotal(func(path string, sub TaxSubTotalItem) []string {
+				if sub.taxCategory() != c.code {
+					return nil
+				}
+				hasReason := sub.TaxExemptionReasonCode != "" || sub.TaxExemptionReason != ""
+				if hasReason != c.exemptionReason {
+					return []string{path + ".taxExemptionReason"}
+				}
+				return nil
+			}),
+		},
+	}
+
+	if c.code == VATCategoryIntraCommunity {
+		rules = append(rules,
+			Rule{
+				ID:       id(11),
+				Severity: RuleSeverityFatal,
+				Message:  "In an Invoice with a VAT breakdown where the VAT category code is \"Intra-community supply\" the Actual delivery date or the Invoicing period shall not be blank",
+				Check: func(inv Invoice) []string {
+					if !hasLineTaxCategory(inv, c.code) {
+						return nil
+					}
+					return requiredField("delivery.actualDeliveryDate", inv.Delivery.ActualDeliveryDate)
+				},
+			},
+			Rule{
+				ID:       id(12),
+				Severity: RuleSeverityFatal,
+				Message:  "In an Invoice with a VAT breakdown where the VAT category code is \"Intra-community supply\" the Deliver to country code shall not be blank",
+				Check: func(inv Invoice) []string {
+					if !hasLineTaxCategory(inv, c.code) {
+						return nil
+					}
+					return requiredField("deliveryParty.postalAddress.countryId", inv.DeliveryParty.PostalAddress.CountryID)
+				},
+			},
+		)
+	}
+	return rules
+}
+
+// taxableAmounts returns the taxable amount the lines and charges of a VAT
+// breakdown group add up to and the taxable amount of the breakdowns in the
+// group. Standard rated breakdowns are grouped per rate, the others per
+// category.
+func taxableAmounts(inv Invoice, group taxGroup) (float64, float64) {
+	expected := 0.0
+	for _, l := range inv.InvoiceLine {
+		if lineGroup(l.Item) == group {
+			expected += l.LineExtension.Amount
+		}
+	}
+
+	actual := 0.0
+	for i, sub := range inv.TaxTotal.TaxSubTotal {
+		if breakdownGroup(sub) != group {
+			continue
+		}
+		actual += sub.TaxableAmount
+		if i == chargeBreakdown(inv.TaxTotal.TaxSubTotal) {
+			expected += documentCharges(inv)
+		}
+	}
+	return expected, actual
+}
+
+// breakdownGroup returns the group of a VAT breakdown for BR-xx-08
+func breakdownGroup(sub TaxSubTotalItem) taxGroup {
+	group := taxGroup{scheme: sub.taxScheme(), category: sub.taxCategory()}
+	if group.category == VATCategoryStandard {
+		group.percent = sub.Percent
+	}
+	return group
+}
+
+// lineGroup returns the group of an invoice line for BR-xx-08
+func lineGroup(item Item) taxGroup {
+	group := taxGroup{scheme: item.taxScheme(), category: item.taxCategory()}
+	if group.category == VATCategoryStandard {
+		group.percent = item.TaxPercent
+	}
+	return group
+}
+
+func hasLineTaxCategory(inv Invoice, code string) bool {
+	for _, l := range inv.InvoiceLine {
+		if l.taxCategory() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// documentCharges returns the sum of the charges on document level
+func documentCharges(inv Invoice) float64 {
+	return inv.AllowanceCharge.Freight + inv.AllowanceCharge.Handling
+}
+
+// isCreditTransfer reports whether a UNCL 4461 payment means code is a credit
+// transfer
+func isCreditTransfer(code string) bool {
+	return code == "30" || code == "58"
+}
+
+func requiredField(path string, value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return []string{path}
+	}
+	return nil
+}
+
+// eachLine applies a check to every invoice line
+func eachLine(check func(path string, l InvoiceLine) []string) func(inv Invoice) []string {
+	return func(inv Invoice) []string {
+		paths := []string{}
+		for i, l := range inv.InvoiceLine {
+			paths = append(paths, check(fmt.Sprintf("invoiceLine[%d]", i), l)...)
+		}
+		return paths
+	}
+}
+
+// eachTaxSubTotal applies a check to every VAT breakdown
+func eachTaxSubTotal(check func(path string, sub TaxSubTotalItem) []string) func(inv Invoice) []string {
+	return func(inv Invoice) []string {
+		paths := []string{}
+		for i, sub := range inv.TaxTotal.TaxSubTotal {
+			paths = append(paths, check(fmt.Sprintf("taxTotal.taxSubTotal[%d]", i), sub)...)
+		}
+		return paths
+	}
+}
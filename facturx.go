@@ -0,0 +1,362 @@
+package basware
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// Name of the embedded invoice required by Factur-X and ZUGFeRD 2
+	FacturXFileName = "factur-x.xml"
+
+	// Factur-X profile of the XML written by MarshalCII
+	FacturXConformanceLevel = "EN 16931"
+
+	facturXNamespace = "urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#"
+)
+
+// NewFacturX turns a PDF rendering of the invoice into a Factur-X/ZUGFeRD
+// hybrid invoice: a PDF/A-3 with the CII XML embedded as factur-x.xml and the
+// matching XMP metadata. The original document is kept as is and extended
+// with an incremental update, so it must not be encrypted and its content
+// (fonts, colors) must already be suitable for PDF/A archiving. Fonts that
+// aren't embedded, like the standard fonts of RenderPDF, are reported as
+// warnings: the result isn't PDF/A conformant then.
+//
+// The result can be uploaded as the invoice image with FilesService.Post.
+func NewFacturX(pdf []byte, inv Invoice, docType DocumentType) ([]byte, ConversionWarnings, error) {
+	invoiceXML, warnings, err := MarshalCII(inv, docType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := readPDF(pdf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.trailer.get("Encrypt") != nil {
+		return nil, nil, errors.New("Encrypted PDF documents can't be converted to PDF/A")
+	}
+
+	rootRef, ok := r.trailer.get("Root").(pdfRef)
+	if !ok {
+		return nil, nil, errors.New("pdf: document catalog not found")
+	}
+	obj, err := r.object(rootRef)
+	if err != nil {
+		return nil, nil, err
+	}
+	catalog, ok := obj.(*pdfDict)
+	if !ok {
+		return nil, nil, errors.New("pdf: invalid document catalog")
+	}
+
+	for _, font := range unembeddedFonts(r, catalog) {
+		warnings = append(warnings, ConversionWarning{
+			Path:    "Font/" + font,
+			Message: "The font isn't embedded, the document doesn't conform to PDF/A",
+		})
+	}
+
+	t := time.Now().UTC()
+	title := fmt.Sprintf("Invoice %s", inv.ID)
+	if docType == DocumentTypeCreditNote {
+		title = fmt.Sprintf("Credit note %s", inv.ID)
+	}
+
+	u := newPDFUpdate(r)
+
+	// embedded invoice
+	compressed := new(bytes.Buffer)
+	zw := zlib.NewWriter(compressed)
+	zw.Write(invoiceXML)
+	zw.Close()
+	checksum := md5.Sum(invoiceXML)
+
+	fileRef := u.add(&pdfStream{
+		Dict: newPDFDict().
+			set("Type", pdfName("EmbeddedFile")).
+			set("Subtype", pdfName("text/xml")).
+			set("Filter", pdfName("FlateDecode")).
+			set("Params", newPDFDict().
+				set("ModDate", pdfString(pdfDate(t))).
+				set("Size", pdfInt(len(invoiceXML))).
+				set("CheckSum", pdfRaw("<"+hex.EncodeToString(checksum[:])+">"))),
+		Data: compressed.Bytes(),
+	})
+
+	fileSpecRef := u.add(newPDFDict().
+		set("Type", pdfName("Filespec")).
+		set("F", pdfString(FacturXFileName)).
+		set("UF", pdfString(FacturXFileName)).
+		set("Desc", pdfString("Factur-X invoice")).
+		set("AFRelationship", pdfName("Data")).
+		set("EF", newPDFDict().
+			set("F", fileRef).
+			set("UF", fileRef)))
+
+	// document information has to match the XMP metadata, so only the keys
+	// written to both are kept
+	original := newPDFDict()
+	switch i := r.trailer.get("Info").(type) {
+	case *pdfDict:
+		original = i
+	case pdfRef:
+		if resolved, err := r.object(i); err == nil {
+			if d, ok := resolved.(*pdfDict); ok {
+				original = d
+			}
+		}
+	}
+	meta := pdfInfo{Title: title, Date: t}
+	info := newPDFDict()
+	for _, key := range []pdfName{"Author", "Subject", "Keywords", "Creator"} {
+		value, ok := decodePDFString(r.raw(original.get(key)))
+		if !ok || value == "" {
+			continue
+		}
+		info.set(key, pdfString(value))
+		switch key {
+		case "Author":
+			meta.Author = value
+		case "Subject":
+			meta.Subject = value
+		case "Keywords":
+			meta.Keywords = value
+		case "Creator":
+			meta.Creator = value
+		}
+	}
+	info.set("Title", pdfString(title))
+	info.set("Producer", pdfString(userAgent))
+	info.set("CreationDate", pdfString(pdfDate(t)))
+	info.set("ModDate", pdfString(pdfDate(t)))
+	infoRef := u.add(info)
+
+	metadataRef := u.add(&pdfStream{
+		Dict: newPDFDict().
+			set("Type", pdfName("Metadata")).
+			set("Subtype", pdfName("XML")),
+		Data: facturXMetadata(meta),
+	})
+
+	// document catalog
+	newCatalog := catalog.copy()
+	newCatalog.set("Metadata", metadataRef)
+	newCatalog.set("AF", pdfArray{fileSpecRef})
+
+	names := newPDFDict()
+	switch n := catalog.get("Names").(type) {
+	case *pdfDict:
+		names = n.copy()
+	case pdfRef:
+		if resolved, err := r.object(n); err == nil {
+			if d, ok := resolved.(*pdfDict); ok {
+				names = d.copy()
+			}
+		}
+	}
+	names.set("EmbeddedFiles", newPDFDict().
+		set("Names", pdfArray{pdfString(FacturXFileName), fileSpecRef}))
+	newCatalog.set("Names", names)
+
+	if catalog.get("OutputIntents") == nil {
+		iccRef := u.add(&pdfStream{
+			Dict: newPDFDict().set("N", pdfInt(3)),
+			Data: srgbICCProfile(),
+		})
+		newCatalog.set("OutputIntents", pdfArray{newPDFDict().
+			set("Type", pdfName("OutputIntent")).
+			set("S", pdfName("GTS_PDFA1")).
+			set("OutputConditionIdentifier", pdfString("sRGB")).
+			set("Info", pdfString("sRGB")).
+			set("DestOutputProfile", iccRef)})
+	}
+	u.replace(rootRef, newCatalog)
+
+	trailer := newPDFDict()
+	trailer.set("Root", rootRef)
+	trailer.set("Info", infoRef)
+
+	return u.bytes(trailer), warnings, nil
+}
+
+// pdfDate formats a date as PDF date string
+func pdfDate(t time.Time) string {
+	return t.Format("D:20060102150405") + "+00'00'"
+}
+
+// pdfInfo is the document information written to the XMP metadata
+type pdfInfo struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords string
+	Creator  string
+	Date     time.Time
+}
+
+// unembeddedFonts returns the names of the fonts used by the pages without
+// font program. Type 3 fonts are defined in the document itself.
+func unembeddedFonts(r *pdfReader, catalog *pdfDict) []string {
+	resolve := func(obj pdfObject) pdfObject {
+		if ref, ok := obj.(pdfRef); ok {
+			resolved, err := r.object(ref)
+			if err != nil {
+				return nil
+			}
+			return resolved
+		}
+		return obj
+	}
+	dict := func(obj pdfObject) *pdfDict {
+		d, _ := resolve(obj).(*pdfDict)
+		return d
+	}
+
+	embedded := func(font *pdfDict) bool {
+		if font.get("Subtype") == pdfName("Type3") {
+			return true
+		}
+		if font.get("Subtype") == pdfName("Type0") {
+			descendants, _ := resolve(font.get("DescendantFonts")).(pdfArray)
+			if len(descendants) == 0 {
+				return false
+			}
+			font = dict(descendants[0])
+		}
+		descriptor := dict(font.get("FontDescriptor"))
+		return descriptor.get("FontFile") != nil || descriptor.get("FontFile2") != nil || descriptor.get("FontFile3") != nil
+	}
+
+	names := []string{}
+	seen := map[string]bool{}
+	visited := map[pdfRef]bool{}
+	var walk func(obj pdfObject, resources *pdfDict)
+	walk = func(obj pdfObject, resources *pdfDict) {
+		if ref, ok := obj.(pdfRef); ok {
+			if visited[ref] {
+				return
+			}
+			visited[ref] = true
+		}
+		node := dict(obj)
+		if node == nil {
+			return
+		}
+		// resources are inherited from the page tree
+		if res := dict(node.get("Resources")); res != nil {
+			resources = res
+		}
+		if kids, ok := resolve(node.get("Kids")).(pdfArray); ok {
+			for _, kid := range kids {
+				walk(kid, resources)
+			}
+			return
+		}
+
+		fonts := dict(resources.get("Font"))
+		if fonts == nil {
+			return
+		}
+		for _, key := range fonts.keys {
+			font := dict(fonts.get(key))
+			if font == nil || embedded(font) {
+				continue
+			}
+			name := string(key)
+			if base, ok := font.get("BaseFont").(pdfName); ok {
+				name = string(base)
+			}
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	walk(catalog.get("Pages"), nil)
+	return names
+}
+
+// facturXMetadata creates the XMP packet with the PDF/A-3 identification,
+// the document information and the Factur-X extension schema
+func facturXMetadata(info pdfInfo) []byte {
+	escape := func(s string) string {
+		buf := new(bytes.Buffer)
+		xml.EscapeText(buf, []byte(s))
+		return buf.String()
+	}
+
+	property := func(name string, description string) string {
+		return `<rdf:li rdf:parseType="Resource">` +
+			`<pdfaProperty:name>` + name + `</pdfaProperty:name>` +
+			`<pdfaProperty:valueType>Text</pdfaProperty:valueType>` +
+			`<pdfaProperty:category>external</pdfaProperty:category>` +
+			`<pdfaProperty:description>` + description + `</pdfaProperty:description>` +
+			`</rdf:li>`
+	}
+
+	optional := ""
+	if info.Author != "" {
+		optional += `<dc:creator><rdf:Seq><rdf:li>` + escape(info.Author) + `</rdf:li></rdf:Seq></dc:creator>` + "\n"
+	}
+	if info.Subject != "" {
+		optional += `<dc:description><rdf:Alt><rdf:li xml:lang="x-default">` + escape(info.Subject) + `</rdf:li></rdf:Alt></dc:description>` + "\n"
+	}
+	pdfProperties := ""
+	if info.Keywords != "" {
+		pdfProperties = `<pdf:Keywords>` + escape(info.Keywords) + `</pdf:Keywords>` + "\n"
+	}
+	xmpProperties := ""
+	if info.Creator != "" {
+		xmpProperties = `<xmp:CreatorTool>` + escape(info.Creator) + `</xmp:CreatorTool>` + "\n"
+	}
+
+	date := info.Date.Format("2006-01-02T15:04:05Z07:00")
+	return []byte(`<?xpacket begin="` + "\xef\xbb\xbf" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/">
+<pdfaid:part>3</pdfaid:part>
+<pdfaid:conformance>B</pdfaid:conformance>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:title><rdf:Alt><rdf:li xml:lang="x-default">` + escape(info.Title) + `</rdf:li></rdf:Alt></dc:title>
+` + optional + `</rdf:Description>
+<rdf:Description rdf:about="" xmlns:pdf="http://ns.adobe.com/pdf/1.3/">
+` + pdfProperties + `<pdf:Producer>` + userAgent + `</pdf:Producer>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/">
+` + xmpProperties + `<xmp:CreateDate>` + date + `</xmp:CreateDate>
+<xmp:ModifyDate>` + date + `</xmp:ModifyDate>
+<xmp:MetadataDate>` + date + `</xmp:MetadataDate>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:fx="` + facturXNamespace + `">
+<fx:DocumentType>INVOICE</fx:DocumentType>
+<fx:DocumentFileName>` + FacturXFileName + `</fx:DocumentFileName>
+<fx:Version>1.0</fx:Version>
+<fx:ConformanceLevel>` + FacturXConformanceLevel + `</fx:ConformanceLevel>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:pdfaExtension="http://www.aiim.org/pdfa/ns/extension/" xmlns:pdfaSchema="http://www.aiim.org/pdfa/ns/schema#" xmlns:pdfaProperty="http://www.aiim.org/pdfa/ns/property#">
+<pdfaExtension:schemas><rdf:Bag><rdf:li rdf:parseType="Resource">
+<pdfaSchema:schema>Factur-X PDFA Extension Schema</pdfaSchema:schema>
+<pdfaSchema:namespaceURI>` + facturXNamespace + `</pdfaSchema:namespaceURI>
+<pdfaSchema:prefix>fx</pdfaSchema:prefix>
+<pdfaSchema:property><rdf:Seq>` +
+		property("DocumentFileName", "name of the embedded XML invoice file") +
+		property("DocumentType", "INVOICE") +
+		property("Version", "The actual version of the Factur-X XML schema") +
+		property("ConformanceLevel", "The conformance level of the embedded Factur-X data") + `
+</rdf:Seq></pdfaSchema:property>
+</rdf:li></rdf:Bag></pdfaExtension:schemas>
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`)
+}
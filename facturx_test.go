@@ -0,0 +1,226 @@
+package basware_test
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+// minimalPDF builds a one page document with a classic cross reference table
+func minimalPDF() []byte {
+	return classicPDF(
+		"<</Type /Catalog /Pages 2 0 R>>",
+		"<</Type /Pages /Kids [3 0 R] /Count 1>>",
+		"<</Type /Page /Parent 2 0 R /MediaBox [0 0 595 842]>>",
+	)
+}
+
+// classicPDF builds a document of the objects with a classic cross reference
+// table
+func classicPDF(objects ...string) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString("%PDF-1.7\n")
+	offsets := []int{}
+	for i, o := range objects {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(buf, "trailer\n<</Size %d /Root 1 0 R>>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestNewFacturX(t *testing.T) {
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := minimalPDF()
+	pdf, _, err := basware.NewFacturX(original, doc.Invoice, doc.Type)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(pdf, original) {
+		t.Error("expected the original document to be kept as is")
+	}
+
+	update := string(pdf[len(original):])
+	for _, s := range []string{
+		"/Type /EmbeddedFile",
+		"/AFRelationship /Data",
+		"(factur-x.xml)",
+		"<pdfaid:part>3</pdfaid:part>",
+		"<fx:ConformanceLevel>EN 16931</fx:ConformanceLevel>",
+		"/S /GTS_PDFA1",
+		"/Prev ",
+	} {
+		if !strings.Contains(update, s) {
+			t.Errorf("expected update to contain %s", s)
+		}
+	}
+
+	// the result must be readable again
+	_, _, err = basware.NewFacturX(pdf, doc.Invoice, doc.Type)
+	if err != nil {
+		t.Errorf("couldn't read generated document: %s", err)
+	}
+}
+
+// streamPDF builds a one page document with a cross reference stream
+func streamPDF() []byte {
+	objects := []string{
+		"<</Type /Catalog /Pages 2 0 R>>",
+		"<</Type /Pages /Kids [3 0 R] /Count 1>>",
+		"<</Type /Page /Parent 2 0 R /MediaBox [0 0 595 842]>>",
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("%PDF-1.7\n")
+	rows := []byte{0, 0, 0, 0, 0, 0}
+	for i, o := range objects {
+		offset := buf.Len()
+		rows = append(rows, 1, byte(offset>>24), byte(offset>>16), byte(offset>>8), byte(offset), 0)
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := buf.Len()
+	rows = append(rows, 1, byte(xref>>24), byte(xref>>16), byte(xref>>8), byte(xref), 0)
+	fmt.Fprintf(buf, "4 0 obj\n<</Type /XRef /Size 5 /W [1 4 1] /Root 1 0 R /ID [<01> <01>] /Length %d>>\nstream\n", len(rows))
+	buf.Write(rows)
+	fmt.Fprintf(buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
+
+func TestNewFacturXXrefStream(t *testing.T) {
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := streamPDF()
+	pdf, _, err := basware.NewFacturX(original, doc.Invoice, doc.Type)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update := string(pdf[len(original):])
+	if !strings.Contains(update, "/Type /XRef") || strings.Contains(update, "\nxref\n") || strings.Contains(update, "trailer") {
+		t.Errorf("expected a cross reference stream, got %s", update)
+	}
+	id := regexp.MustCompile(`/ID \[<([0-9a-f]*)> <([0-9a-f]*)>\]`).FindStringSubmatch(update)
+	if id == nil || id[1] != "01" || len(id[2]) != 32 {
+		t.Errorf("expected the original and a new file identifier, got %v", id)
+	}
+
+	// the result must be readable again and gets a new identifier
+	again, _, err := basware.NewFacturX(pdf, doc.Invoice, doc.Type)
+	if err != nil {
+		t.Fatalf("couldn't read generated document: %s", err)
+	}
+	if strings.Contains(string(again[len(pdf):]), id[2]) {
+		t.Error("expected a new identifier for the update")
+	}
+}
+
+func TestNewFacturXInfo(t *testing.T) {
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := []byte(strings.Replace(string(classicPDF(
+		"<</Type /Catalog /Pages 2 0 R>>",
+		"<</Type /Pages /Kids [3 0 R] /Count 1 /Resources <</Font <</F1 4 0 R>>>>>>",
+		"<</Type /Page /Parent 2 0 R /MediaBox [0 0 595 842]>>",
+		"<</Type /Font /Subtype /Type1 /BaseFont /Helvetica>>",
+		`<</Author (M\374ller & S\366hne) /Trapped /False /Custom (value)>>`,
+	)), "/Root 1 0 R>>", "/Root 1 0 R /Info 5 0 R>>", 1))
+	pdf, warnings, err := basware.NewFacturX(original, doc.Invoice, doc.Type)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the font inherited by the page isn't embedded
+	fonts := []string{}
+	for _, w := range warnings {
+		if strings.HasPrefix(w.Path, "Font/") {
+			fonts = append(fonts, w.Path)
+		}
+	}
+	if strings.Join(fonts, ",") != "Font/Helvetica" {
+		t.Errorf("expected a warning for the font, got %v", fonts)
+	}
+
+	// the author is written as UTF-16 and to the XMP metadata, the keys
+	// without XMP equivalent are dropped
+	update := string(pdf[len(original):])
+	for _, s := range []string{
+		"/Author <FEFF004D00FC006C006C00650072002000260020005300F60068006E0065>",
+		"<dc:creator><rdf:Seq><rdf:li>Müller &amp; Söhne</rdf:li></rdf:Seq></dc:creator>",
+	} {
+		if !strings.Contains(update, s) {
+			t.Errorf("expected update to contain %s", s)
+		}
+	}
+	if strings.Contains(update, "/Custom") || strings.Contains(update, "/Trapped") {
+		t.Error("expected the keys without XMP equivalent to be dropped")
+	}
+
+	// a document without fonts has no font warnings
+	_, warnings, err = basware.NewFacturX(minimalPDF(), doc.Invoice, doc.Type)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range warnings {
+		if strings.HasPrefix(w.Path, "Font/") {
+			t.Errorf("expected no font warnings, got %s", w)
+		}
+	}
+}
+
+// xrefStreamPDF builds a document whose cross reference stream has the widths
+func xrefStreamPDF(widths string) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString("%PDF-1.7\n")
+	xref := buf.Len()
+	fmt.Fprintf(buf, "1 0 obj\n<</Type /XRef /Size 2 /W %s /Root 1 0 R /Length 8>>\nstream\n\x01\x00\x09\x00\x01\x00\x09\x00\nendstream\nendobj\n", widths)
+	fmt.Fprintf(buf, "startxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
+
+func TestNewFacturXMalformed(t *testing.T) {
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := string(minimalPDF())
+	tests := map[string][]byte{
+		"nested width":     xrefStreamPDF("[1 [2] 1]"),
+		"negative width":   xrefStreamPDF("[1 -2 1]"),
+		"zero widths":      xrefStreamPDF("[0 0 0]"),
+		"negative XRefStm": []byte(strings.Replace(original, "/Root 1 0 R>>", "/Root 1 0 R /XRefStm -5>>", 1)),
+		"negative object":  []byte(strings.Replace(original, "0000000009 00000 n", "-000000005 00000 n", 1)),
+
+		// references to the object itself
+		"self length": classicPDF("<</Type /Catalog /Pages 2 0 R /Length 1 0 R>>\nstream\nabc\nendstream"),
+		"self object stream": []byte("%PDF-1.7\n1 0 obj\n<</Type /XRef /Size 2 /W [1 1 1] /Root 1 0 R /Length 6>>\nstream\n" +
+			"\x01\x09\x00\x02\x01\x00\nendstream\nendobj\nstartxref\n9\n%%EOF\n"),
+		"nested arrays": classicPDF("<</Type /Catalog /Pages " + strings.Repeat("[", 100000) + ">>"),
+	}
+	for name, pdf := range tests {
+		if _, _, err := basware.NewFacturX(pdf, doc.Invoice, doc.Type); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -1,5 +1,20 @@
 package basware
 
+import (
+	"context"
+	"net/http"
+)
+
+var (
+	endpointFiles = "v1/files"
+)
+
+const (
+	// File types used in FileRef and when uploading files
+	FileTypeInvoiceImage = "INVOICE_IMAGE"
+	FileTypeAttachment   = "ATTACHMENT"
+)
+
 type FilesService struct {
 	client *Client
 }
@@ -7,3 +22,66 @@ type FilesService struct {
 func NewFilesService(client *Client) *FilesService {
 	return &FilesService{client: client}
 }
+
+// Post stores a file into Basware Network. The returned refId can be used in
+// the fileRefs of a business document.
+func (s *FilesService) Post(ctx context.Context, requestBody *FilesPostRequestBody) (*FilesPostResponseBody, error) {
+	responseBody := s.NewPostResponseBody()
+	_, err := s.client.Call(ctx, http.MethodPost, endpointFiles, nil, nil, requestBody, responseBody)
+	return responseBody, err
+}
+
+// Download fetches a file through a file link of a business document
+func (s *FilesService) Download(ctx context.Context, link Link) (*FilesGetResponse, error) {
+	responseBody := &FilesGetResponse{}
+	_, err := s.client.FollowLink(ctx, link, nil, responseBody)
+	return responseBody, err
+}
+
+func (s *FilesService) NewPostRequestBody() *FilesPostRequestBody {
+	return &FilesPostRequestBody{}
+}
+
+// NewPostRequestBodyFromPDF creates a request body for uploading a PDF as the
+// invoice image
+func (s *FilesService) NewPostRequestBodyFromPDF(fileName string, pdf []byte) *FilesPostRequestBody {
+	return &FilesPostRequestBody{
+		FileName: fileName,
+		MimeType: "application/pdf",
+		FileType: FileTypeInvoiceImage,
+		Content:  pdf,
+	}
+}
+
+func (s *FilesService) NewPostResponseBody() *FilesPostResponseBody {
+	return &FilesPostResponseBody{}
+}
+
+// File to be stored into Basware Network
+type FilesPostRequestBody struct {
+	// Name of the file including the extension.
+	FileName string `json:"fileName"`
+
+	// Media type of the file, for example application/pdf.
+	MimeType string `json:"mimeType"`
+
+	// Type of the file: INVOICE_IMAGE or ATTACHMENT.
+	FileType string `json:"fileType,omitempty"`
+
+	// Content of the file, base64 encoded when serialized.
+	Content []byte `json:"content"`
+}
+
+type FilesPostResponseBody struct {
+	// Unique file identifier to be used in the fileRefs of a business
+	// document.
+	RefID string `json:"refId"`
+}
+
+// File stored in Basware Network
+type FilesGetResponse struct {
+	FileName string `json:"fileName"`
+	MimeType string `json:"mimeType"`
+	FileType string `json:"fileType,omitempty"`
+	Content  []byte `json:"content"`
+}
@@ -0,0 +1,361 @@
+package basware
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// IdentifierScheme is a scheme of the Peppol electronic address scheme (EAS)
+// code list, which holds the ISO 6523 ICD codes used in Peppol and a range of
+// national VAT schemes
+type IdentifierScheme struct {
+	// Code of the scheme, e.g. 0088
+	Code string
+
+	// Short name of the scheme, e.g. GLN
+	Name string
+
+	// Country of the scheme; empty for international schemes
+	CountryID string
+
+	// Other names the scheme goes by, e.g. the Basware scheme identifiers
+	Aliases []string
+
+	validate func(id string) error
+}
+
+// Validate checks the syntax and check digits of an identifier of the scheme
+func (s IdentifierScheme) Validate(id string) error {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("Expected a %s identifier, got none", s.Name)
+	}
+	if s.validate == nil {
+		return nil
+	}
+	return s.validate(strings.TrimSpace(id))
+}
+
+// ParticipantIDScheme is the Peppol scheme of participant identifiers with an
+// ISO 6523 or EAS code, e.g. iso6523-actorid-upis::0192:923609016
+const ParticipantIDScheme = "iso6523-actorid-upis"
+
+var (
+	ovtFormat = regexp.MustCompile(`^0037[0-9]{8}[0-9A-Z]{0,5}$`)
+	uidFormat = regexp.MustCompile(`^CHE[0-9]{9}$`)
+	leiFormat = regexp.MustCompile(`^[0-9A-Z]{18}[0-9]{2}$`)
+)
+
+var identifierSchemes = []IdentifierScheme{
+	{Code: "0002", Name: "FR:SIRENE", CountryID: "FR", validate: digitsWithLuhn("SIREN", 9)},
+	{Code: "0007", Name: "SE:ORGNR", CountryID: "SE", validate: digitsWithLuhn("Swedish organisation number", 10)},
+	{Code: "0009", Name: "FR:SIRET", CountryID: "FR", validate: digitsWithLuhn("SIRET", 14)},
+	{Code: "0037", Name: "FI:OVT", CountryID: "FI", validate: validateOVT},
+	{Code: "0060", Name: "DUNS", validate: matching("DUNS number", `^[0-9]{9}$`)},
+	{Code: "0088", Name: "GLN", Aliases: []string{"EAN"}, validate: validateGLN},
+	{Code: "0096", Name: "DK:P", CountryID: "DK", validate: matching("P number", `^[0-9]{10}$`)},
+	{Code: "0106", Name: "NL:KVK", CountryID: "NL", validate: companyRegistration("NL")},
+	{Code: "0130", Name: "EU:DIRECTORATES"},
+	{Code: "0135", Name: "IT:SIA", CountryID: "IT"},
+	{Code: "0142", Name: "IT:SECETI", CountryID: "IT"},
+	{Code: "0151", Name: "AU:ABN", CountryID: "AU", validate: validateABN},
+	{Code: "0183", Name: "CH:UIDB", CountryID: "CH", validate: validateUID},
+	{Code: "0184", Name: "DK:CVR", CountryID: "DK", validate: companyRegistration("DK")},
+	{Code: "0188", Name: "JP:SST", CountryID: "JP", validate: matching("corporate number", `^[0-9]{13}$`)},
+	{Code: "0190", Name: "NL:OINO", CountryID: "NL", Aliases: []string{"NL:OIN"}, validate: matching("OIN", `^[0-9]{20}$`)},
+	{Code: "0191", Name: "EE:CC", CountryID: "EE", validate: matching("Estonian company code", `^[0-9]{8}$`)},
+	{Code: "0192", Name: "NO:ORG", CountryID: "NO", Aliases: []string{"NO:ORGNR"}, validate: companyRegistration("NO")},
+	{Code: "0193", Name: "UBLBE", CountryID: "BE"},
+	{Code: "0195", Name: "SG:UEN", CountryID: "SG", validate: matching("UEN", `^[0-9A-Z]{9,10}$`)},
+	{Code: "0196", Name: "IS:KTNR", CountryID: "IS", Aliases: []string{"IS:KT"}, validate: matching("kennitala", `^[0-9]{10}$`)},
+	{Code: "0198", Name: "DK:ERST", CountryID: "DK", Aliases: []string{"DK:SE"}, validate: companyRegistration("DK")},
+	{Code: "0199", Name: "LEI", validate: validateLEI},
+	{Code: "0200", Name: "LT:LEC", CountryID: "LT", validate: matching("legal entity code", `^[0-9]{9}$`)},
+	{Code: "0201", Name: "IT:CUUO", CountryID: "IT", Aliases: []string{"IT:IPA"}, validate: matching("IPA code", `^[0-9A-Z]{6}$`)},
+	{Code: "0204", Name: "DE:LWID", CountryID: "DE", validate: validateLeitwegID},
+	{Code: "0208", Name: "BE:EN", CountryID: "BE", Aliases: []string{"BE:KBO", "BE:CBE"}, validate: companyRegistration("BE")},
+	{Code: "0209", Name: "GS1", validate: matching("GS1 key", `^[0-9]{8,30}$`)},
+	{Code: "0210", Name: "IT:CFI", CountryID: "IT", Aliases: []string{"IT:CF"}, validate: matching("codice fiscale", `^([0-9A-Z]{16}|[0-9]{11})$`)},
+	{Code: "0211", Name: "IT:IVA", CountryID: "IT", validate: vatNumberOf("IT")},
+	{Code: "0212", Name: "FI:ORG", CountryID: "FI", Aliases: []string{"FI:YT"}, validate: companyRegistration("FI")},
+	{Code: "0213", Name: "FI:VAT", CountryID: "FI", validate: vatNumberOf("FI")},
+	{Code: "0215", Name: "FI:NSI", CountryID: "FI"},
+	{Code: "0216", Name: "FI:OVT2", CountryID: "FI", validate: validateOVT},
+	{Code: "0218", Name: "LV:URN", CountryID: "LV"},
+	{Code: "0221", Name: "JP:IIN", CountryID: "JP", validate: matching("invoice registration number", `^T[0-9]{13}$`)},
+	{Code: "0230", Name: "MY:EIF", CountryID: "MY"},
+	{Code: "9910", Name: "HU:VAT", CountryID: "HU", validate: vatNumberOf("HU")},
+	{Code: "9913", Name: "EU:REID"},
+	{Code: "9914", Name: "AT:VAT", CountryID: "AT", validate: vatNumberOf("AT")},
+	{Code: "9915", Name: "AT:GOV", CountryID: "AT"},
+	{Code: "9918", Name: "IBAN", validate: ValidateIBAN},
+	{Code: "9919", Name: "AT:KUR", CountryID: "AT"},
+	{Code: "9920", Name: "ES:VAT", CountryID: "ES", validate: vatNumberOf("ES")},
+	{Code: "9922", Name: "AD:VAT", CountryID: "AD"},
+	{Code: "9923", Name: "AL:VAT", CountryID: "AL"},
+	{Code: "9924", Name: "BA:VAT", CountryID: "BA"},
+	{Code: "9925", Name: "BE:VAT", CountryID: "BE", validate: vatNumberOf("BE")},
+	{Code: "9926", Name: "BG:VAT", CountryID: "BG", validate: vatNumberOf("BG")},
+	{Code: "9927", Name: "CH:VAT", CountryID: "CH"},
+	{Code: "9928", Name: "CY:VAT", CountryID: "CY", validate: vatNumberOf("CY")},
+	{Code: "9929", Name: "CZ:VAT", CountryID: "CZ", validate: vatNumberOf("CZ")},
+	{Code: "9930", Name: "DE:VAT", CountryID: "DE", validate: vatNumberOf("DE")},
+	{Code: "9931", Name: "EE:VAT", CountryID: "EE", validate: vatNumberOf("EE")},
+	{Code: "9932", Name: "GB:VAT", CountryID: "GB", validate: vatNumberOf("GB")},
+	{Code: "9933", Name: "GR:VAT", CountryID: "GR", validate: vatNumberOf("EL")},
+	{Code: "9934", Name: "HR:VAT", CountryID: "HR", validate: vatNumberOf("HR")},
+	{Code: "9935", Name: "IE:VAT", CountryID: "IE", validate: vatNumberOf("IE")},
+	{Code: "9936", Name: "LI:VAT", CountryID: "LI"},
+	{Code: "9937", Name: "LT:VAT", CountryID: "LT", validate: vatNumberOf("LT")},
+	{Code: "9938", Name: "LU:VAT", CountryID: "LU", validate: vatNumberOf("LU")},
+	{Code: "9939", Name: "LV:VAT", CountryID: "LV", validate: vatNumberOf("LV")},
+	{Code: "9940", Name: "MC:VAT", CountryID: "MC"},
+	{Code: "9941", Name: "ME:VAT", CountryID: "ME"},
+	{Code: "9942", Name: "MK:VAT", CountryID: "MK"},
+	{Code: "9943", Name: "MT:VAT", CountryID: "MT", validate: vatNumberOf("MT")},
+	{Code: "9944", Name: "NL:VAT", CountryID: "NL", validate: vatNumberOf("NL")},
+	{Code: "9945", Name: "PL:VAT", CountryID: "PL", validate: vatNumberOf("PL")},
+	{Code: "9946", Name: "PT:VAT", CountryID: "PT", validate: vatNumberOf("PT")},
+	{Code: "9947", Name: "RO:VAT", CountryID: "RO", validate: vatNumberOf("RO")},
+	{Code: "9948", Name: "RS:VAT", CountryID: "RS"},
+	{Code: "9949", Name: "SI:VAT", CountryID: "SI", validate: vatNumberOf("SI")},
+	{Code: "9950", Name: "SK:VAT", CountryID: "SK", validate: vatNumberOf("SK")},
+	{Code: "9951", Name: "SM:VAT", CountryID: "SM"},
+	{Code: "9952", Name: "TR:VAT", CountryID: "TR"},
+	{Code: "9953", Name: "VA:VAT", CountryID: "VA"},
+	{Code: "9955", Name: "SE:VAT", CountryID: "SE", validate: vatNumberOf("SE")},
+	{Code: "9957", Name: "FR:VAT", CountryID: "FR", validate: vatNumberOf("FR")},
+	{Code: "9959", Name: "US:EIN", CountryID: "US", validate: matching("EIN", `^[0-9]{2}-?[0-9]{7}$`)},
+	{Code: "EM", Name: "EMAIL", validate: matching("email address", `^[^@\s]+@[^@\s]+$`)},
+}
+
+// identifierSchemeIndex finds the schemes by upper cased code, name and alias
+var identifierSchemeIndex = map[string]IdentifierScheme{}
+
+func init() {
+	for _, s := range identifierSchemes {
+		identifierSchemeIndex[s.Code] = s
+		identifierSchemeIndex[strings.ToUpper(s.Name)] = s
+		for _, a := range s.Aliases {
+			identifierSchemeIndex[strings.ToUpper(a)] = s
+		}
+	}
+}
+
+// IdentifierSchemes returns the schemes of the registry
+func IdentifierSchemes() []IdentifierScheme {
+	return append([]IdentifierScheme{}, identifierSchemes...)
+}
+
+// LookupIdentifierScheme finds a scheme by its code (0192), name (NO:ORG) or
+// alias (NO:ORGNR)
+func LookupIdentifierScheme(scheme string) (IdentifierScheme, bool) {
+	s, ok := identifierSchemeIndex[strings.ToUpper(strings.TrimSpace(scheme))]
+	return s, ok
+}
+
+// ParticipantID is a Peppol participant identifier: an identifier with the
+// code of its scheme
+type ParticipantID struct {
+	SchemeID string
+	ID       string
+}
+
+// ParseParticipantID parses an identifier prefixed with its scheme, with or
+// without the Peppol participant identifier scheme:
+// iso6523-actorid-upis::0192:923609016, 0192:923609016 or NO:ORG:923609016.
+// The scheme is normalized to its code.
+func ParseParticipantID(s string) (ParticipantID, error) {
+	value := strings.TrimSpace(s)
+	if i := strings.Index(value, "::"); i >= 0 {
+		if !strings.EqualFold(value[:i], ParticipantIDScheme) {
+			return ParticipantID{}, fmt.Errorf("Expected participant identifier scheme %s, got \"%s\"", ParticipantIDScheme, value[:i])
+		}
+		value = value[i+2:]
+	}
+
+	// scheme names contain colons themselves, so try every split
+	for i := range value {
+		if value[i] != ':' {
+			continue
+		}
+		if scheme, ok := LookupIdentifierScheme(value[:i]); ok {
+			return ParticipantID{SchemeID: scheme.Code, ID: strings.TrimSpace(value[i+1:])}, nil
+		}
+	}
+	return ParticipantID{}, fmt.Errorf("Expected a participant identifier with a known scheme, got \"%s\"", s)
+}
+
+// String returns the identifier as scheme:id, e.g. 0192:923609016
+func (p ParticipantID) String() string {
+	return p.SchemeID + ":" + p.ID
+}
+
+// URI returns the identifier with the Peppol participant identifier scheme,
+// e.g. iso6523-actorid-upis::0192:923609016
+func (p ParticipantID) URI() string {
+	return ParticipantIDScheme + "::" + p.String()
+}
+
+// Validate checks the identifier against its scheme
+func (p ParticipantID) Validate() error {
+	s, ok := LookupIdentifierScheme(p.SchemeID)
+	if !ok {
+		return fmt.Errorf("Unknown identifier scheme \"%s\"", p.SchemeID)
+	}
+	return s.Validate(p.ID)
+}
+
+// ParticipantID returns the endpoint as participant identifier. The scheme is
+// normalized to its code; an endpoint without scheme may hold a prefixed
+// identifier like 0192:923609016.
+func (e Endpoint) ParticipantID() (ParticipantID, error) {
+	if e.SchemeID == "" {
+		return ParseParticipantID(e.ID)
+	}
+
+	s, ok := LookupIdentifierScheme(e.SchemeID)
+	if !ok {
+		return ParticipantID{}, fmt.Errorf("Unknown endpoint scheme \"%s\"", e.SchemeID)
+	}
+	id := strings.TrimSpace(e.ID)
+	// the scheme may be repeated in the identifier
+	if p, err := ParseParticipantID(id); err == nil && p.SchemeID == s.Code {
+		id = p.ID
+	}
+	return ParticipantID{SchemeID: s.Code, ID: id}, nil
+}
+
+// Normalize returns the endpoint with the code of its scheme and the
+// identifier without scheme prefix
+func (e Endpoint) Normalize() (Endpoint, error) {
+	p, err := e.ParticipantID()
+	if err != nil {
+		return e, err
+	}
+	return Endpoint{ID: p.ID, SchemeID: p.SchemeID}, nil
+}
+
+// Validate checks that the endpoint has a scheme of the registry and a valid
+// identifier of that scheme
+func (e Endpoint) Validate() error {
+	p, err := e.ParticipantID()
+	if err != nil {
+		return err
+	}
+	return p.Validate()
+}
+
+func matching(name string, pattern string) func(id string) error {
+	re := regexp.MustCompile(pattern)
+	return func(id string) error {
+		if !re.MatchString(strings.ToUpper(id)) {
+			return fmt.Errorf("Invalid %s \"%s\"", name, id)
+		}
+		return nil
+	}
+}
+
+func digitsWithLuhn(name string, length int) func(id string) error {
+	return func(id string) error {
+		n := normalizeRegistrationNumber(id)
+		if len(n) != length || !numericReference.MatchString(n) || !isValidLuhn(n) {
+			return fmt.Errorf("Invalid %s \"%s\"", name, id)
+		}
+		return nil
+	}
+}
+
+func companyRegistration(countryID string) func(id string) error {
+	return func(id string) error {
+		return ValidateCompanyRegistrationNumber(id, countryID)
+	}
+}
+
+// vatNumberOf checks VAT numbers with or without their country prefix
+func vatNumberOf(countryID string) func(id string) error {
+	return func(id string) error {
+		n := normalizeRegistrationNumber(id)
+		if !strings.HasPrefix(n, countryID) && !(countryID == "EL" && strings.HasPrefix(n, "GR")) {
+			n = countryID + n
+		}
+		return ValidateVATNumber(n)
+	}
+}
+
+// validateGLN checks the GS1 check digit of a 13 digit global location number
+func validateGLN(id string) error {
+	if len(id) != 13 || !numericReference.MatchString(id) || gs1Check(id[:12]) != digit(id, 12) {
+		return fmt.Errorf("Invalid GLN \"%s\"", id)
+	}
+	return nil
+}
+
+// gs1Check uses the weights 3, 1 from the right
+func gs1Check(n string) int {
+	sum := 0
+	for i := range n {
+		d := int(n[len(n)-1-i] - '0')
+		if i%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+// validateOVT checks a Finnish OVT code: 0037, the Y-tunnus and an optional
+// suffix of up to five characters
+func validateOVT(id string) error {
+	n := strings.ToUpper(strings.Replace(id, " ", "", -1))
+	if !ovtFormat.MatchString(n) || !isValidYTunnus(n[4:12]) {
+		return fmt.Errorf("Invalid OVT code \"%s\"", id)
+	}
+	return nil
+}
+
+// validateABN checks an Australian business number
+func validateABN(id string) error {
+	n := normalizeRegistrationNumber(id)
+	if len(n) != 11 || !numericReference.MatchString(n) || n[0] == '0' {
+		return fmt.Errorf("Invalid ABN \"%s\"", id)
+	}
+	n = string(n[0]-1) + n[1:]
+	if weightedSum(n, 10, 1, 3, 5, 7, 9, 11, 13, 15, 17, 19)%89 != 0 {
+		return fmt.Errorf("Invalid ABN \"%s\"", id)
+	}
+	return nil
+}
+
+// validateUID checks a Swiss enterprise identification number, e.g.
+// CHE-109.322.551
+func validateUID(id string) error {
+	n := normalizeRegistrationNumber(id)
+	if !uidFormat.MatchString(n) {
+		return fmt.Errorf("Invalid UID \"%s\"", id)
+	}
+	check := 11 - weightedSum(n[3:11], 5, 4, 3, 2, 7, 6, 5, 4)%11
+	if check == 11 {
+		check = 0
+	}
+	if check != digit(n, 11) {
+		return fmt.Errorf("Invalid UID \"%s\"", id)
+	}
+	return nil
+}
+
+// validateLEI checks the ISO 17442 check digits of a legal entity identifier
+func validateLEI(id string) error {
+	n := strings.ToUpper(id)
+	if !leiFormat.MatchString(n) || !isValidMod9710(n) {
+		return fmt.Errorf("Invalid LEI \"%s\"", id)
+	}
+	return nil
+}
+
+func validateLeitwegID(id string) error {
+	if !IsValidLeitwegID(id) {
+		return fmt.Errorf("Invalid Leitweg-ID \"%s\"", id)
+	}
+	return nil
+}
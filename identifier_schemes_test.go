@@ -0,0 +1,68 @@
+package basware_test
+
+import (
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+func TestParseParticipantID(t *testing.T) {
+	tests := map[string]string{
+		"iso6523-actorid-upis::0192:923609016": "0192:923609016",
+		"0088:7300010000001":                   "0088:7300010000001",
+		"NO:ORGNR:923609016":                   "0192:923609016",
+		"fi:ovt:003720774740":                  "0037:003720774740",
+		"9930:DE136695976":                     "9930:DE136695976",
+	}
+	for s, expected := range tests {
+		p, err := basware.ParseParticipantID(s)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if p.String() != expected {
+			t.Errorf("%s: expected %s, got %s", s, expected, p)
+		}
+		if err := p.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if p, _ := basware.ParseParticipantID("0192:923609016"); p.URI() != "iso6523-actorid-upis::0192:923609016" {
+		t.Errorf("unexpected URI %s", p.URI())
+	}
+	for _, s := range []string{"busdox-actorid-upis::0192:923609016", "1234:5678", "923609016"} {
+		if _, err := basware.ParseParticipantID(s); err == nil {
+			t.Errorf("expected an error parsing %s", s)
+		}
+	}
+}
+
+func TestEndpointValidate(t *testing.T) {
+	tests := []struct {
+		endpoint basware.Endpoint
+		valid    bool
+	}{
+		{basware.Endpoint{ID: "7300010000001", SchemeID: "0088"}, true},
+		{basware.Endpoint{ID: "7300010000002", SchemeID: "GLN"}, false},
+		{basware.Endpoint{ID: "0192:923609016"}, true},
+		{basware.Endpoint{ID: "923609017", SchemeID: "0192"}, false},
+		{basware.Endpoint{ID: "003720774740ABC", SchemeID: "0037"}, true},
+		{basware.Endpoint{ID: "5493001KJTIIGC8Y1R12", SchemeID: "0199"}, true},
+		{basware.Endpoint{ID: "CHE-109.322.551", SchemeID: "0183"}, true},
+		{basware.Endpoint{ID: "51824753556", SchemeID: "0151"}, true},
+		{basware.Endpoint{ID: "04011000-1234512345-06", SchemeID: "0204"}, true},
+		{basware.Endpoint{ID: "136695976", SchemeID: "9930"}, true},
+		{basware.Endpoint{ID: "7300010000001", SchemeID: "X400"}, false},
+	}
+	for _, test := range tests {
+		if err := test.endpoint.Validate(); (err == nil) != test.valid {
+			t.Errorf("%+v: expected valid to be %v, got %v", test.endpoint, test.valid, err)
+		}
+	}
+
+	e, err := basware.Endpoint{ID: "iso6523-actorid-upis::0192:923609016", SchemeID: "NO:ORG"}.Normalize()
+	if err != nil || e != (basware.Endpoint{ID: "923609016", SchemeID: "0192"}) {
+		t.Errorf("unexpected normalized endpoint %+v (%v)", e, err)
+	}
+}
@@ -0,0 +1,59 @@
+package basware
+
+import (
+	"fmt"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// BumID identifies a business document (invoice, credit note) in Basware
+// Network. It's chosen by the sender when posting the document.
+type BumID string
+
+// NewBumID generates a random (UUID v4) bumId
+func NewBumID() BumID {
+	return BumID(uuid.NewV4().String())
+}
+
+func (id BumID) String() string {
+	return string(id)
+}
+
+// Validate checks the bumId can be used as path parameter: it can't be empty
+// and only contains letters, digits and - _ . ~
+func (id BumID) Validate() error {
+	return validateID("bumId", string(id))
+}
+
+// NotificationID identifies a notification, used to acknowledge it
+type NotificationID string
+
+func (id NotificationID) String() string {
+	return string(id)
+}
+
+// Validate checks the notificationId can be used as path parameter
+func (id NotificationID) Validate() error {
+	return validateID("notificationId", string(id))
+}
+
+// validateID only allows the unreserved URL characters, so an id never ends
+// up in another path segment or endpoint
+func validateID(name string, id string) error {
+	if id == "" {
+		return fmt.Errorf("Expected a %s", name)
+	}
+	if strings.Trim(id, ".") == "" {
+		return fmt.Errorf("Invalid %s \"%s\"", name, id)
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.' || r == '~':
+		default:
+			return fmt.Errorf("Invalid %s \"%s\": character %q isn't allowed", name, id, r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package basware_test
+
+import (
+	"context"
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+	"github.com/tim-online/go-basware/baswaretest"
+)
+
+func TestBumIDValidate(t *testing.T) {
+	valid := []basware.BumID{"bum-1", "INV_2024.03~1", basware.NewBumID()}
+	for _, id := range valid {
+		if err := id.Validate(); err != nil {
+			t.Errorf("Expected %s to be valid, got %s", id, err)
+		}
+	}
+
+	invalid := []basware.BumID{"", ".", "..", "a/b", "a b", "a?b", "a%2Fb", "ä"}
+	for _, id := range invalid {
+		if err := id.Validate(); err == nil {
+			t.Errorf("Expected %q to be invalid", id)
+		}
+	}
+}
+
+func TestServicesValidateIDs(t *testing.T) {
+	server := baswaretest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+	ctx := context.Background()
+
+	// the fake would answer ../notifications with the notification list
+	get := client.Invoices.NewGetPathParams()
+	get.BumID = "../notifications"
+	if _, err := client.Invoices.Get(ctx, get); err == nil {
+		t.Error("Expected an invalid bumId error")
+	}
+
+	post := client.Invoices.NewPostPathParams()
+	if _, err := client.Invoices.Post(ctx, post, client.Invoices.NewPostRequestBody()); err == nil || err.Error() != "Expected a bumId" {
+		t.Errorf("Expected a missing bumId error, got %v", err)
+	}
+
+	ack := client.Notifications.NewAcknowledgePathParams()
+	ack.NotificationID = "a/acknowledge"
+	if err := client.Notifications.Acknowledge(ctx, ack); err == nil {
+		t.Error("Expected an invalid notificationId error")
+	}
+}
@@ -33,6 +33,15 @@ type AccountingCustomerParty struct {
 	// Information about taxes. Notice that only one tax scheme is used,
 	// although there could be multiple.
 	PartyTaxScheme PartyTaxScheme `json:"partyTaxScheme,omitempty"`
+
+	// Tax schemes of the party next to PartyTaxScheme, e.g. a state tax next
+	// to GST. The Basware API takes a single tax scheme so these are sent as
+	// party identifiers.
+	AdditionalPartyTaxSchemes []PartyTaxScheme `json:"-"`
+
+	// Legal registrations of the party, e.g. the Swedish organisation number.
+	// These are sent as party identifiers.
+	PartyLegalEntities []PartyLegalEntity `json:"-"`
 }
 
 // Information about taxes. Notice that only one tax scheme is used, although
@@ -53,6 +62,19 @@ type PartyTaxSchemeCompany struct {
 	SchemeID string `json:"schemeId,omitempty"`
 }
 
+// Legal registration of a party
+type PartyLegalEntity struct {
+	// The name under which the party is registered.
+	RegistrationName string `json:"registrationName,omitempty"`
+
+	// The registration identifier, e.g. an organisation number.
+	CompanyID string `json:"companyId,omitempty"`
+
+	// The scheme of the registration identifier. Valid values: ISO 6523 ICD
+	// or country specific agency schema, example 0007 or SE:ORGNR for Sweden.
+	SchemeID string `json:"schemeId,omitempty"`
+}
+
 // Party that is the accountable supplier of the goods/services in the referred
 // business document.
 type AccountingSupplierParty struct {
@@ -79,6 +101,15 @@ type AccountingSupplierParty struct {
 	// An object containing information about contacts. Used for defining the
 	// company contact data
 	Contact Contact `json:"contact,omitempty"`
+
+	// Tax schemes of the party next to PartyTaxScheme, e.g. a state tax next
+	// to GST. The Basware API takes a single tax scheme so these are sent as
+	// party identifiers.
+	AdditionalPartyTaxSchemes []PartyTaxScheme `json:"-"`
+
+	// Legal registrations of the party, e.g. the Swedish organisation number.
+	// These are sent as party identifiers.
+	PartyLegalEntities []PartyLegalEntity `json:"-"`
 }
 
 // An object containing information about contacts. Used for defining the
@@ -258,13 +289,16 @@ type DeliveryParty struct {
 	PartyName           string                    `json:"partyName"`
 	PartyTaxScheme      PartyTaxScheme            `json:"partyTaxScheme,omitempty"`
 	PostalAddress       PostalAddress             `json:"postalAddress,omitempty"`
+
+	AdditionalPartyTaxSchemes []PartyTaxScheme   `json:"-"`
+	PartyLegalEntities        []PartyLegalEntity `json:"-"`
 }
 
 // Description of the Business Document line item.
 type DescriptionItem string
 
 type FileRef struct {
-	FileType string `json:"fileType"`
+	FileType string `json:"fileType,omitempty"`
 	RefID    string `json:"refId"`
 }
 
@@ -372,6 +406,24 @@ type Item struct {
 
 	// Tax amount for the item
 	TaxPercent float64 `json:"taxPercent,omitempty"`
+
+	// Tax category of the item. Valid values: UNCL 5305 code, e.g. S
+	// (standard rated), Z (zero rated), E (exempt), AE (reverse charge) or K
+	// (intra-community supply). If empty, the category follows from the tax
+	// percent. The Basware API has no tax category fields, so this and the
+	// fields below are only kept by the UBL and CII conversions and
+	// MarshalInvoiceJSON.
+	TaxCategoryID string `json:"-"`
+
+	// Code of the reason the item is exempt from tax. Valid values: VATEX
+	// code, e.g. VATEX-EU-IC.
+	TaxExemptionReasonCode string `json:"-"`
+
+	// Reason the item is exempt from tax, as text.
+	TaxExemptionReason string `json:"-"`
+
+	// Tax scheme of the tax category, e.g. VAT. If empty, VAT is assumed.
+	TaxSchemeID string `json:"-"`
 }
 
 type LegalMonetaryTotal struct {
@@ -552,6 +604,21 @@ type TaxSubTotalItem struct {
 	// Basis of the taxes. The net amount to which the tax percent (rate) is
 	// applied to calculate the tax amount.
 	TaxableAmount float64 `json:"taxableAmount,omitempty"`
+
+	// Tax category of the subtotal. Valid values: UNCL 5305 code. If empty,
+	// the category follows from the percent. Like the fields below it isn't
+	// sent to the Basware API, see MarshalInvoiceJSON.
+	TaxCategoryID string `json:"-"`
+
+	// Code of the reason the amount is exempt from tax. Valid values: VATEX
+	// code, e.g. VATEX-EU-AE.
+	TaxExemptionReasonCode string `json:"-"`
+
+	// Reason the amount is exempt from tax, as text, e.g. Reverse charge.
+	TaxExemptionReason string `json:"-"`
+
+	// Tax scheme of the tax category, e.g. VAT. If empty, VAT is assumed.
+	TaxSchemeID string `json:"-"`
 }
 
 // An object holding transaction tax.
@@ -3,7 +3,6 @@ package basware
 import (
 	"context"
 	"net/http"
-	"strings"
 )
 
 var (
@@ -19,28 +18,33 @@ func NewInvoicesService(client *Client) *InvoicesService {
 }
 
 func (s *InvoicesService) Get(ctx context.Context, pathParams *InvoiceGetPathParams) (*InvoicesGetResponse, error) {
-	// @TODO: create wrapper?
 	responseBody := s.NewGetResponse()
-	method := http.MethodGet
-
-	path := endpointInvoices
-	path = strings.Replace(path, "{bumId}", pathParams.BumID, 1)
-	apiURL, err := s.client.GetEndpointURL(path)
-	if err != nil {
+	if err := pathParams.BumID.Validate(); err != nil {
 		return nil, err
 	}
 
-	// create new request
-	httpReq, err := s.client.NewRequest(ctx, method, apiURL, nil)
-	if err != nil {
-		return nil, err
-	}
+	_, err := s.client.Call(ctx, http.MethodGet, endpointInvoices, pathParams, nil, nil, responseBody)
+	return responseBody, err
+}
+
+// Self fetches the invoice again through its self link
+func (s *InvoicesService) Self(ctx context.Context, invoice *InvoicesGetResponse) (*InvoicesGetResponse, error) {
+	responseBody := s.NewGetResponse()
+	err := s.client.followRel(ctx, invoice.Links, LinkRelSelf, nil, responseBody)
+	return responseBody, err
+}
 
-	// process query parameters
-	// utils.AddQueryParamsToRequest(queryParams, httpReq, false)
+// Status fetches the processing status of the invoice through its status link
+func (s *InvoicesService) Status(ctx context.Context, invoice *InvoicesGetResponse) (*DocumentStatus, error) {
+	responseBody := &DocumentStatus{}
+	err := s.client.followRel(ctx, invoice.Links, LinkRelStatus, nil, responseBody)
+	return responseBody, err
+}
 
-	// submit the request
-	_, err = s.client.Do(httpReq, responseBody)
+// Credit sends a credit note for the invoice through its credit link
+func (s *InvoicesService) Credit(ctx context.Context, invoice *InvoicesGetResponse, requestBody *InvoicesPostRequestBody) (*InvoicesPostResponseBody, error) {
+	responseBody := s.NewPostResponseBody()
+	err := s.client.followRel(ctx, invoice.Links, LinkRelCredit, requestBody, responseBody)
 	return responseBody, err
 }
 
@@ -53,32 +57,16 @@ func (s *InvoicesService) NewGetPathParams() *InvoiceGetPathParams {
 }
 
 type InvoiceGetPathParams struct {
-	BumID string `json:"bumId"`
+	BumID BumID `json:"bumId"`
 }
 
 func (s *InvoicesService) Post(ctx context.Context, pathParams *InvoicePostPathParams, requestBody *InvoicesPostRequestBody) (*InvoicesPostResponseBody, error) {
-	// @TODO: create wrapper?
-	method := http.MethodPost
 	responseBody := s.NewPostResponseBody()
-
-	path := endpointInvoices
-	path = strings.Replace(path, "{bumId}", pathParams.BumID, 1)
-	apiURL, err := s.client.GetEndpointURL(path)
-	if err != nil {
-		return nil, err
-	}
-
-	// create new request
-	httpReq, err := s.client.NewRequest(ctx, method, apiURL, requestBody)
-	if err != nil {
+	if err := pathParams.BumID.Validate(); err != nil {
 		return nil, err
 	}
 
-	// process query parameters
-	// utils.AddQueryParamsToRequest(queryParams, httpReq, false)
-
-	// submit the request
-	_, err = s.client.Do(httpReq, responseBody)
+	_, err := s.client.Call(ctx, http.MethodPost, endpointInvoices, pathParams, nil, requestBody, responseBody)
 	return responseBody, err
 }
 
@@ -87,7 +75,7 @@ func (s *InvoicesService) NewPostPathParams() *InvoicePostPathParams {
 }
 
 type InvoicePostPathParams struct {
-	BumID string `json:"bumId"`
+	BumID BumID `json:"bumId"`
 }
 
 func (s *InvoicesService) NewPostRequestBody() *InvoicesPostRequestBody {
@@ -100,3 +88,24 @@ func (s *InvoicesService) NewPostResponseBody() *InvoicesPostResponseBody {
 
 type InvoicesPostResponseBody struct {
 }
+
+// Processing status of a business document
+type DocumentStatus struct {
+	BumID BumID `json:"bumId"`
+
+	// Status of the document, for example DELIVERED.
+	Status string `json:"status"`
+
+	// Time of the last status change in ISO 8601 format.
+	Updated string `json:"updated,omitempty"`
+
+	// Description of the status, e.g. why the delivery failed.
+	Message string `json:"message,omitempty"`
+}
+
+// Document statuses
+const (
+	DocumentStatusReceived  = "RECEIVED"
+	DocumentStatusDelivered = "DELIVERED"
+	DocumentStatusFailed    = "FAILED"
+)
@@ -4,6 +4,6 @@ package basware
 type InvoicesGetResponse struct {
 	Data     Invoice   `json:"data"`
 	FileRefs []FileRef `json:"fileRefs,omitempty"`
-	Links    []Link    `json:"links,omitempty"`
+	Links    Links     `json:"links,omitempty"`
 	Version  string    `json:"version"`
 }
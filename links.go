@@ -0,0 +1,94 @@
+package basware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Rels of the links returned with business documents
+const (
+	LinkRelSelf   = "self"
+	LinkRelFile   = "file"
+	LinkRelStatus = "status"
+	LinkRelCredit = "credit"
+)
+
+// Links related to a business document
+type Links []Link
+
+// Find returns the first link with the rel
+func (links Links) Find(rel string) (Link, bool) {
+	for _, l := range links {
+		if l.Rel == rel {
+			return l, true
+		}
+	}
+	return Link{}, false
+}
+
+// FindAll returns all links with the rel, e.g. a file link per file
+func (links Links) FindAll(rel string) Links {
+	found := Links{}
+	for _, l := range links {
+		if l.Rel == rel {
+			found = append(found, l)
+		}
+	}
+	return found
+}
+
+// FollowLink sends a request to the href of the link with its method (GET
+// when empty). Errors are returned like Do returns them; out may be nil.
+func (c *Client) FollowLink(ctx context.Context, link Link, body interface{}, out interface{}) (*http.Response, error) {
+	apiURL, err := c.LinkURL(link)
+	if err != nil {
+		return nil, err
+	}
+
+	method := link.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	httpReq, err := c.NewRequest(ctx, strings.ToUpper(method), apiURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if out == nil {
+		var discard interface{}
+		out = &discard
+	}
+	return c.Do(httpReq, out)
+}
+
+// LinkURL resolves the href of the link against the base URL. Links to
+// another host are rejected, so the credentials are never sent elsewhere.
+func (c *Client) LinkURL(link Link) (url.URL, error) {
+	if link.Href == "" {
+		return url.URL{}, fmt.Errorf("Expected a href in link %s", link.Rel)
+	}
+	href, err := url.Parse(link.Href)
+	if err != nil {
+		return url.URL{}, fmt.Errorf("Invalid href \"%s\" in link %s: %s", link.Href, link.Rel, err)
+	}
+
+	baseURL := c.BaseURL()
+	apiURL := baseURL.ResolveReference(href)
+	if !strings.EqualFold(apiURL.Scheme, baseURL.Scheme) || !strings.EqualFold(apiURL.Host, baseURL.Host) {
+		return url.URL{}, fmt.Errorf("Invalid href \"%s\" in link %s: expected a link to %s://%s", link.Href, link.Rel, baseURL.Scheme, baseURL.Host)
+	}
+	return *apiURL, nil
+}
+
+// followRel follows the first link with the rel
+func (c *Client) followRel(ctx context.Context, links Links, rel string, body interface{}, out interface{}) error {
+	link, ok := links.Find(rel)
+	if !ok {
+		return fmt.Errorf("Expected a link with rel %s", rel)
+	}
+	_, err := c.FollowLink(ctx, link, body, out)
+	return err
+}
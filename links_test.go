@@ -0,0 +1,124 @@
+package basware_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+	"github.com/tim-online/go-basware/baswaretest"
+)
+
+func TestLinkURL(t *testing.T) {
+	client := basware.NewClient(nil, "user", "secret")
+	baseURL, _ := url.Parse("https://api.example.com/api/")
+	client.SetBaseURL(*baseURL)
+
+	tests := map[string]string{
+		"v1/invoices/bum-1":                          "https://api.example.com/api/v1/invoices/bum-1",
+		"/v1/invoices/bum-1":                         "https://api.example.com/v1/invoices/bum-1",
+		"https://API.example.com/api/v1/files/ref-1": "https://API.example.com/api/v1/files/ref-1",
+		"v1/invoices/bum-1/status?lang=fi":           "https://api.example.com/api/v1/invoices/bum-1/status?lang=fi",
+	}
+	for href, expected := range tests {
+		u, err := client.LinkURL(basware.Link{Href: href, Rel: "self"})
+		if err != nil || u.String() != expected {
+			t.Errorf("Expected %s for %s, got %s (%v)", expected, href, u.String(), err)
+		}
+	}
+
+	for _, href := range []string{"", "https://evil.example.com/v1/invoices/bum-1", "//evil.example.com/x", "http://api.example.com/api/v1/invoices"} {
+		if _, err := client.LinkURL(basware.Link{Href: href, Rel: "self"}); err == nil {
+			t.Errorf("Expected %q to be rejected", href)
+		}
+	}
+}
+
+func TestFollowLinks(t *testing.T) {
+	server := baswaretest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+	ctx := context.Background()
+
+	upload := client.Files.NewPostRequestBodyFromPDF("invoice.pdf", []byte("%PDF-1.4"))
+	file, err := client.Files.Post(ctx, upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := newInvoiceBody(t)
+	body.FileRefs = []basware.FileRef{{RefID: file.RefID}}
+	post := client.Invoices.NewPostPathParams()
+	post.BumID = "bum-1"
+	if _, err := client.Invoices.Post(ctx, post, body); err != nil {
+		t.Fatal(err)
+	}
+
+	get := client.Invoices.NewGetPathParams()
+	get.BumID = "bum-1"
+	invoice, err := client.Invoices.Get(ctx, get)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	self, err := client.Invoices.Self(ctx, invoice)
+	if err != nil || self.Data.ID != invoice.Data.ID {
+		t.Errorf("Expected the invoice through the self link, got %v", err)
+	}
+
+	server.AddNotification(basware.Notification{NotificationType: basware.NotificationTypeDocumentDelivered, BumID: "bum-1", DocumentType: "INVOICE"})
+	status, err := client.Invoices.Status(ctx, invoice)
+	if err != nil || status.Status != basware.DocumentStatusDelivered {
+		t.Errorf("Expected a delivered status, got %+v (%v)", status, err)
+	}
+
+	link, ok := invoice.Links.Find(basware.LinkRelFile)
+	if !ok {
+		t.Fatal("Expected a file link")
+	}
+	download, err := client.Files.Download(ctx, link)
+	if err != nil || string(download.Content) != "%PDF-1.4" {
+		t.Errorf("Expected the file, got %+v (%v)", download, err)
+	}
+
+	credit := newInvoiceBody(t)
+	credit.ClientToken = "credit-token"
+	if _, err := client.Invoices.Credit(ctx, invoice, credit); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.Document(baswaretest.KindCreditNotes, "bum-1-credit"); !ok {
+		t.Error("Expected the credit note to be received")
+	}
+
+	invoice.Links = nil
+	if _, err := client.Invoices.Status(ctx, invoice); err == nil {
+		t.Error("Expected a missing link error")
+	}
+}
+
+func newInvoiceBody(t *testing.T) *basware.InvoicesPostRequestBody {
+	body := &basware.InvoicesPostRequestBody{}
+	err := json.Unmarshal([]byte(`{
+		"clientToken": "8d1c7a6e-4b0a-4f6e-9a39-1a0b7f6b2c11",
+		"data": {
+			"id": "INV-1",
+			"issueDate": "2024-03-01",
+			"documentCurrencyCode": "EUR",
+			"accountingSupplierParty": {"partyName": "Seller Oy"},
+			"accountingCustomerParty": {"partyName": "Buyer Oy"},
+			"legalMonetaryTotal": {
+				"lineExtensionAmount": {"currencyId": "EUR", "amount": 100},
+				"payableAmount": {"currencyId": "EUR", "amount": 100}
+			},
+			"invoiceLine": [{
+				"id": "1",
+				"lineExtension": {"currencyId": "EUR", "amount": 100},
+				"item": {"name": "Widget"}
+			}]
+		}
+	}`), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
@@ -1,5 +1,15 @@
 package basware
 
+import (
+	"context"
+	"net/http"
+)
+
+var (
+	endpointNotifications            = "v1/notifications"
+	endpointNotificationsAcknowledge = "v1/notifications/{notificationId}/acknowledge"
+)
+
 type NotificationsService struct {
 	client *Client
 }
@@ -7,3 +17,64 @@ type NotificationsService struct {
 func NewNotificationsService(client *Client) *NotificationsService {
 	return &NotificationsService{client: client}
 }
+
+// List fetches the notifications that haven't been acknowledged yet
+func (s *NotificationsService) List(ctx context.Context) (*NotificationsGetResponse, error) {
+	responseBody := s.NewGetResponse()
+	_, err := s.client.Call(ctx, http.MethodGet, endpointNotifications, nil, nil, nil, responseBody)
+	return responseBody, err
+}
+
+// Acknowledge marks a notification as processed so it isn't listed anymore
+func (s *NotificationsService) Acknowledge(ctx context.Context, pathParams *NotificationAcknowledgePathParams) error {
+	if err := pathParams.NotificationID.Validate(); err != nil {
+		return err
+	}
+
+	_, err := s.client.Call(ctx, http.MethodPost, endpointNotificationsAcknowledge, pathParams, nil, nil, nil)
+	return err
+}
+
+func (s *NotificationsService) NewGetResponse() *NotificationsGetResponse {
+	return &NotificationsGetResponse{}
+}
+
+func (s *NotificationsService) NewAcknowledgePathParams() *NotificationAcknowledgePathParams {
+	return &NotificationAcknowledgePathParams{}
+}
+
+type NotificationAcknowledgePathParams struct {
+	NotificationID NotificationID `json:"notificationId"`
+}
+
+type NotificationsGetResponse struct {
+	Notifications []Notification `json:"notifications"`
+}
+
+// Notification about the processing of a business document
+type Notification struct {
+	// Identifier of the notification, used to acknowledge it.
+	NotificationID NotificationID `json:"notificationId"`
+
+	// Type of the notification, for example DOCUMENT_DELIVERED.
+	NotificationType string `json:"notificationType"`
+
+	// Identifier of the business document the notification is about.
+	BumID BumID `json:"bumId,omitempty"`
+
+	// Type of the business document: INVOICE or CREDIT_NOTE.
+	DocumentType string `json:"documentType,omitempty"`
+
+	// Description of the event.
+	Message string `json:"message,omitempty"`
+
+	// Time of the event in ISO 8601 format.
+	Created string `json:"created,omitempty"`
+}
+
+// Notification types
+const (
+	NotificationTypeDocumentReceived  = "DOCUMENT_RECEIVED"
+	NotificationTypeDocumentDelivered = "DOCUMENT_DELIVERED"
+	NotificationTypeDocumentFailed    = "DOCUMENT_FAILED"
+)
@@ -0,0 +1,191 @@
+package basware
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// The Basware API takes a single tax scheme per party and has no legal
+// entities. To stay compatible the additional tax schemes and the legal
+// registrations are sent as party identifiers with their scheme. When read
+// back, identifiers with a tax scheme (letters only, e.g. GST) become
+// additional tax schemes and identifiers with the scheme of a legal
+// registration become legal entities again.
+
+func (p AccountingSupplierParty) MarshalJSON() ([]byte, error) {
+	type alias AccountingSupplierParty
+	a := alias(p)
+	a.PartyName = p.party().name()
+	a.PartyIdentification = p.party().identifiers()
+	return json.Marshal(a)
+}
+
+func (p AccountingCustomerParty) MarshalJSON() ([]byte, error) {
+	type alias AccountingCustomerParty
+	a := alias(p)
+	a.PartyName = p.party().name()
+	a.PartyIdentification = p.party().identifiers()
+	return json.Marshal(a)
+}
+
+func (p DeliveryParty) MarshalJSON() ([]byte, error) {
+	type alias DeliveryParty
+	a := alias(p)
+	a.PartyName = p.party().name()
+	a.PartyIdentification = p.party().identifiers()
+	return json.Marshal(a)
+}
+
+func (p *AccountingSupplierParty) UnmarshalJSON(data []byte) error {
+	type alias AccountingSupplierParty
+	a := alias{}
+	if err := json.Unmarshal(data, &a); err != nil {
+		return err
+	}
+	*p = AccountingSupplierParty(a)
+	p.PartyIdentification, p.PartyLegalEntities, p.AdditionalPartyTaxSchemes = splitIdentifiers(p.PartyIdentification)
+	return nil
+}
+
+func (p *AccountingCustomerParty) UnmarshalJSON(data []byte) error {
+	type alias AccountingCustomerParty
+	a := alias{}
+	if err := json.Unmarshal(data, &a); err != nil {
+		return err
+	}
+	*p = AccountingCustomerParty(a)
+	p.PartyIdentification, p.PartyLegalEntities, p.AdditionalPartyTaxSchemes = splitIdentifiers(p.PartyIdentification)
+	return nil
+}
+
+func (p *DeliveryParty) UnmarshalJSON(data []byte) error {
+	type alias DeliveryParty
+	a := alias{}
+	if err := json.Unmarshal(data, &a); err != nil {
+		return err
+	}
+	*p = DeliveryParty(a)
+	p.PartyIdentification, p.PartyLegalEntities, p.AdditionalPartyTaxSchemes = splitIdentifiers(p.PartyIdentification)
+	return nil
+}
+
+// legalRegistrationSchemes are the ISO 6523 ICD and Basware schemes of
+// company registers
+var legalRegistrationSchemes = []string{
+	"0002", "0007", "0009", "0037", "0106", "0184", "0190", "0192", "0196", "0208", "0212",
+	"SE:ORGNR", "NO:ORGNR", "DK:CVR", "NL:KVK", "NL:OIN", "FR:SIRENE", "FR:SIRET", "BE:EN",
+}
+
+// splitIdentifiers reverses identifiers: the party identifiers sent to the
+// API are split into party identifiers, legal registrations and additional
+// tax schemes
+func splitIdentifiers(all []PartyIdentificationItem) ([]PartyIdentificationItem, []PartyLegalEntity, []PartyTaxScheme) {
+	var ids []PartyIdentificationItem
+	var entities []PartyLegalEntity
+	var taxSchemes []PartyTaxScheme
+	for _, id := range all {
+		switch {
+		case isTaxSchemeID(id.SchemeID):
+			taxSchemes = append(taxSchemes, PartyTaxScheme{Company: PartyTaxSchemeCompany{ID: id.ID, SchemeID: id.SchemeID}})
+		case isLegalRegistrationScheme(id.SchemeID):
+			entities = append(entities, PartyLegalEntity{CompanyID: id.ID, SchemeID: id.SchemeID})
+		default:
+			ids = append(ids, id)
+		}
+	}
+	if len(all) == 0 {
+		ids = all
+	}
+	return ids, entities, taxSchemes
+}
+
+// isTaxSchemeID reports whether the scheme is a tax scheme like VAT or GST
+// rather than an identifier scheme, which are numbers or have a country prefix
+func isTaxSchemeID(scheme string) bool {
+	if scheme == "" {
+		return false
+	}
+	for _, r := range scheme {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isLegalRegistrationScheme(scheme string) bool {
+	for _, s := range legalRegistrationSchemes {
+		if strings.EqualFold(scheme, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// name returns the party name, falling back to the registration name
+func (p party) name() string {
+	if p.PartyName != "" {
+		return p.PartyName
+	}
+	for _, le := range p.PartyLegalEntities {
+		if le.RegistrationName != "" {
+			return le.RegistrationName
+		}
+	}
+	return ""
+}
+
+// identifiers returns the party identifiers followed by the legal
+// registrations and additional tax schemes
+func (p party) identifiers() []PartyIdentificationItem {
+	ids := append([]PartyIdentificationItem{}, p.PartyIdentification...)
+	add := func(id PartyIdentificationItem) {
+		if id.ID == "" {
+			return
+		}
+		for _, existing := range ids {
+			if existing == id {
+				return
+			}
+		}
+		ids = append(ids, id)
+	}
+
+	for _, le := range p.PartyLegalEntities {
+		add(PartyIdentificationItem{ID: le.CompanyID, SchemeID: le.SchemeID})
+	}
+	for _, ts := range p.AdditionalPartyTaxSchemes {
+		add(PartyIdentificationItem{ID: ts.Company.ID, SchemeID: ts.Company.SchemeID})
+	}
+
+	if len(ids) == 0 {
+		return p.PartyIdentification
+	}
+	return ids
+}
+
+// taxSchemes returns all tax schemes of the party
+func (p party) taxSchemes() []PartyTaxScheme {
+	schemes := []PartyTaxScheme{}
+	if p.PartyTaxScheme.Company.ID != "" {
+		schemes = append(schemes, p.PartyTaxScheme)
+	}
+	for _, ts := range p.AdditionalPartyTaxSchemes {
+		if ts.Company.ID != "" {
+			schemes = append(schemes, ts)
+		}
+	}
+	return schemes
+}
+
+// vatIdentifier returns the VAT identifier of the party: the tax scheme with
+// the VAT scheme or without scheme. Other taxes like GST don't count.
+func (p party) vatIdentifier() string {
+	for _, ts := range p.taxSchemes() {
+		switch ts.Company.SchemeID {
+		case "", TaxSchemeVAT, "VA":
+			return ts.Company.ID
+		}
+	}
+	return ""
+}
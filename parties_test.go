@@ -0,0 +1,77 @@
+package basware_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+func TestPartyMarshalJSON(t *testing.T) {
+	party := basware.AccountingSupplierParty{
+		PartyIdentification: []basware.PartyIdentificationItem{{ID: "7300010000001", SchemeID: "0088"}},
+		AdditionalPartyTaxSchemes: []basware.PartyTaxScheme{
+			{Company: basware.PartyTaxSchemeCompany{ID: "123", SchemeID: "STATE"}},
+		},
+		PartyLegalEntities: []basware.PartyLegalEntity{
+			{RegistrationName: "Supplier AB", CompanyID: "5560360793", SchemeID: "0007"},
+		},
+	}
+	party.PartyTaxScheme.Company.ID = "SE556036079301"
+
+	b, err := json.Marshal(party)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `"partyIdentification":[{"id":"7300010000001","schemeId":"0088"},{"id":"5560360793","schemeId":"0007"},{"id":"123","schemeId":"STATE"}],"partyName":"Supplier AB"`
+	if !strings.Contains(string(b), expected) {
+		t.Errorf("expected registrations as party identifiers, got %s", b)
+	}
+	if strings.Contains(string(b), "partyLegalEntities") || strings.Contains(string(b), "additionalPartyTaxSchemes") {
+		t.Errorf("expected only fields known to the Basware API, got %s", b)
+	}
+
+	if len(party.PartyIdentification) != 1 {
+		t.Error("expected marshalling to leave the party unchanged")
+	}
+}
+
+func TestPartyUnmarshalJSON(t *testing.T) {
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inv := doc.Invoice
+	inv.AccountingSupplierParty.PartyIdentification = []basware.PartyIdentificationItem{{ID: "7300010000001", SchemeID: "0088"}}
+	inv.AccountingSupplierParty.AdditionalPartyTaxSchemes = []basware.PartyTaxScheme{
+		{Company: basware.PartyTaxSchemeCompany{ID: "FI99999999", SchemeID: "VAT"}},
+		{Company: basware.PartyTaxSchemeCompany{ID: "123", SchemeID: "STATE"}},
+	}
+	inv.AccountingSupplierParty.PartyLegalEntities = []basware.PartyLegalEntity{{CompanyID: "5560360793", SchemeID: "0007"}}
+	inv.AccountingCustomerParty.PartyLegalEntities = []basware.PartyLegalEntity{{CompanyID: "12345674", SchemeID: "DK:CVR"}}
+
+	b, err := json.Marshal(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := basware.Invoice{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded.AccountingSupplierParty, inv.AccountingSupplierParty) {
+		t.Errorf("expected supplier %+v, got %+v", inv.AccountingSupplierParty, decoded.AccountingSupplierParty)
+	}
+	if !reflect.DeepEqual(decoded.AccountingCustomerParty, inv.AccountingCustomerParty) {
+		t.Errorf("expected customer %+v, got %+v", inv.AccountingCustomerParty, decoded.AccountingCustomerParty)
+	}
+
+	// the second VAT number is a tax scheme again, not an identifier
+	if vat := decoded.AccountingSupplierParty.AdditionalPartyTaxSchemes[0].Company; vat.ID != "FI99999999" || vat.SchemeID != "VAT" {
+		t.Errorf("expected the second VAT number, got %+v", vat)
+	}
+}
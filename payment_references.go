@@ -0,0 +1,216 @@
+package basware
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Payment reference schemes of PaymentIdentifier.SchemeID
+const (
+	// ISO 11649 creditor reference, e.g. RF18539007547034
+	PaymentReferenceRF = "RF"
+	// Finnish reference number (viitenumero), e.g. 1234561
+	PaymentReferenceFI = "FI"
+	// Norwegian KID with a MOD 10 (Luhn) check digit
+	PaymentReferenceKID = "KID"
+	// Norwegian KID with a MOD 11 check digit
+	PaymentReferenceKID11 = "KID11"
+	// Swedish OCR reference with a length and a Luhn check digit
+	PaymentReferenceOCR = "OCR"
+	// Danish FIK payment id of card type 71
+	PaymentReferenceFIK = "FIK"
+	// Dutch betalingskenmerk of 16 digits starting with a check digit
+	PaymentReferenceNL = "NL"
+)
+
+var (
+	rfReference      = regexp.MustCompile(`^RF[0-9]{2}[0-9A-Z]{1,21}$`)
+	numericReference = regexp.MustCompile(`^[0-9]+$`)
+	// a MOD 11 check digit of 10 is written as -
+	kidReference = regexp.MustCompile(`^[0-9]+-?$`)
+)
+
+// paymentReferenceSchemes holds the generator and validator per scheme.
+// Generators get the number without check digits, validators the complete
+// reference; both without spaces.
+var paymentReferenceSchemes = map[string]struct {
+	minLength int
+	maxLength int
+	generate  func(n string) string
+	validate  func(ref string) bool
+}{
+	PaymentReferenceFI: {3, 19, func(n string) string {
+		return n + strconv.Itoa(finnishReferenceCheck(n))
+	}, func(ref string) bool {
+		return len(ref) >= 4 && len(ref) <= 20 && finnishReferenceCheck(ref[:len(ref)-1]) == digit(ref, len(ref)-1)
+	}},
+	PaymentReferenceKID: {1, 24, func(n string) string {
+		return n + strconv.Itoa(luhnCheck(n))
+	}, func(ref string) bool {
+		return len(ref) >= 2 && ((numericReference.MatchString(ref) && isValidLuhn(ref)) || isValidKIDMod11(ref))
+	}},
+	PaymentReferenceKID11: {1, 24, func(n string) string {
+		return n + kidMod11Check(n)
+	}, func(ref string) bool {
+		return len(ref) >= 2 && isValidKIDMod11(ref)
+	}},
+	PaymentReferenceOCR: {1, 23, func(n string) string {
+		n += strconv.Itoa((len(n) + 2) % 10)
+		return n + strconv.Itoa(luhnCheck(n))
+	}, func(ref string) bool {
+		return len(ref) >= 2 && isValidLuhn(ref)
+	}},
+	PaymentReferenceFIK: {1, 14, func(n string) string {
+		n = fmt.Sprintf("%014s", n)
+		return n + strconv.Itoa(luhnCheck(n))
+	}, func(ref string) bool {
+		return (len(ref) == 15 || len(ref) == 16) && isValidLuhn(ref)
+	}},
+	PaymentReferenceNL: {1, 15, func(n string) string {
+		n = fmt.Sprintf("%015s", n)
+		return strconv.Itoa(betalingskenmerkCheck(n)) + n
+	}, func(ref string) bool {
+		return len(ref) == 16 && betalingskenmerkCheck(ref[1:]) == digit(ref, 0)
+	}},
+}
+
+// GeneratePaymentReference builds a payment reference of the scheme from a
+// number, e.g. an invoice number, by adding the check digits. Spaces, dashes
+// and other separators in the number are ignored. RF creditor references
+// take letters as well, the other schemes only digits.
+func GeneratePaymentReference(schemeID string, number string) (string, error) {
+	n := alphanumeric(number)
+	if schemeID == PaymentReferenceRF {
+		if n == "" || len(n) > 21 {
+			return "", fmt.Errorf("Expected 1 to 21 letters or digits for an RF reference, got \"%s\"", number)
+		}
+		return rfCheck(n) + n, nil
+	}
+
+	s, ok := paymentReferenceSchemes[schemeID]
+	if !ok {
+		return "", fmt.Errorf("Unknown payment reference scheme \"%s\"", schemeID)
+	}
+	if !numericReference.MatchString(n) || len(n) < s.minLength || len(n) > s.maxLength {
+		return "", fmt.Errorf("Expected %d to %d digits for a %s reference, got \"%s\"", s.minLength, s.maxLength, schemeID, number)
+	}
+	return s.generate(n), nil
+}
+
+// ValidatePaymentReference checks the syntax and check digits of a payment
+// reference of the scheme. References of other schemes are not checked.
+func ValidatePaymentReference(schemeID string, reference string) error {
+	ref := strings.ToUpper(strings.Replace(reference, " ", "", -1))
+	if schemeID == PaymentReferenceRF {
+		if !rfReference.MatchString(ref) || !isValidMod9710(ref[4:]+ref[:4]) {
+			return fmt.Errorf("Invalid RF reference \"%s\"", reference)
+		}
+		return nil
+	}
+
+	s, ok := paymentReferenceSchemes[schemeID]
+	if !ok {
+		return nil
+	}
+	pattern := numericReference
+	if schemeID == PaymentReferenceKID || schemeID == PaymentReferenceKID11 {
+		pattern = kidReference
+	}
+	if !pattern.MatchString(ref) || len(ref) > 25 || !s.validate(ref) {
+		return fmt.Errorf("Invalid %s reference \"%s\"", schemeID, reference)
+	}
+	return nil
+}
+
+// Validate checks the payment reference against its scheme
+func (pi PaymentIdentifier) Validate() error {
+	return ValidatePaymentReference(pi.SchemeID, pi.ID)
+}
+
+// rfCheck returns the RF prefix with the check digits of a reference
+func rfCheck(n string) string {
+	remainder := 0
+	for _, r := range n + "RF00" {
+		if r >= 'A' && r <= 'Z' {
+			remainder = (remainder*100 + int(r-'A') + 10) % 97
+		} else {
+			remainder = (remainder*10 + int(r-'0')) % 97
+		}
+	}
+	return fmt.Sprintf("RF%02d", 98-remainder)
+}
+
+// finnishReferenceCheck uses the weights 7, 3, 1 from the right
+func finnishReferenceCheck(n string) int {
+	weights := []int{7, 3, 1}
+	sum := 0
+	for i := range n {
+		sum += int(n[len(n)-1-i]-'0') * weights[i%3]
+	}
+	return (10 - sum%10) % 10
+}
+
+// luhnCheck returns the Luhn check digit to append to a number
+func luhnCheck(n string) int {
+	sum := 0
+	for i := range n {
+		d := int(n[len(n)-1-i] - '0')
+		if i%2 == 0 {
+			d = d*2/10 + d*2%10
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+// kidMod11Check uses the weights 2 to 7 from the right; a remainder of 1 gives
+// the check digit "-"
+func kidMod11Check(n string) string {
+	sum := 0
+	for i := range n {
+		sum += int(n[len(n)-1-i]-'0') * (2 + i%6)
+	}
+	switch check := (11 - sum%11) % 11; check {
+	case 10:
+		return "-"
+	default:
+		return strconv.Itoa(check)
+	}
+}
+
+func isValidKIDMod11(ref string) bool {
+	return kidMod11Check(ref[:len(ref)-1]) == ref[len(ref)-1:]
+}
+
+// betalingskenmerkCheck uses the weights 2, 4, 8, 5, 10, 9, 7, 3, 6, 1 from the
+// right
+func betalingskenmerkCheck(n string) int {
+	weights := []int{2, 4, 8, 5, 10, 9, 7, 3, 6, 1}
+	sum := 0
+	for i := range n {
+		sum += int(n[len(n)-1-i]-'0') * weights[i%len(weights)]
+	}
+	switch check := 11 - sum%11; check {
+	case 10:
+		return 1
+	case 11:
+		return 0
+	default:
+		return check
+	}
+}
+
+// alphanumeric returns the upper cased letters and digits of a string
+func alphanumeric(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r >= 'A' && r <= 'Z':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,69 @@
+package basware_test
+
+import (
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+func TestGeneratePaymentReference(t *testing.T) {
+	tests := []struct {
+		schemeID string
+		number   string
+		expected string
+	}{
+		{basware.PaymentReferenceRF, "539007547034", "RF18539007547034"},
+		{basware.PaymentReferenceFI, "123456", "1234561"},
+		{basware.PaymentReferenceKID, "1234567", "12345674"},
+		{basware.PaymentReferenceKID11, "12345", "123455"},
+		{basware.PaymentReferenceKID11, "79290", "79290-"},
+		{basware.PaymentReferenceOCR, "12345", "1234574"},
+		{basware.PaymentReferenceFIK, "2024-0042", "000000202400420"},
+		{basware.PaymentReferenceNL, "2024-0042", "4000000020240042"},
+	}
+	for _, test := range tests {
+		ref, err := basware.GeneratePaymentReference(test.schemeID, test.number)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if ref != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.schemeID, test.expected, ref)
+		}
+		if err := basware.ValidatePaymentReference(test.schemeID, ref); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, err := basware.GeneratePaymentReference(basware.PaymentReferenceFI, "INV-42"); err == nil {
+		t.Error("expected an error for letters in a Finnish reference")
+	}
+}
+
+func TestValidatePaymentReference(t *testing.T) {
+	tests := []struct {
+		schemeID  string
+		reference string
+		valid     bool
+	}{
+		{basware.PaymentReferenceRF, "RF18 5390 0754 7034", true},
+		{basware.PaymentReferenceRF, "RF19539007547034", false},
+		{basware.PaymentReferenceFI, "12345 61", true},
+		{basware.PaymentReferenceFI, "1234562", false},
+		{basware.PaymentReferenceFI, "12345678901234567894", true},
+		{basware.PaymentReferenceFI, "123456789012345678908", false},
+		{basware.PaymentReferenceKID, "79290-", true},
+		{basware.PaymentReferenceKID11, "79291-", false},
+		{basware.PaymentReferenceKID11, "7929-0", false},
+		{basware.PaymentReferenceKID, "12345674", true},
+		{basware.PaymentReferenceKID, "12345675", false},
+		{basware.PaymentReferenceNL, "4000000020240043", false},
+		{"", "anything", true},
+	}
+	for _, test := range tests {
+		pi := basware.PaymentIdentifier{ID: test.reference, SchemeID: test.schemeID}
+		if err := pi.Validate(); (err == nil) != test.valid {
+			t.Errorf("%s %s: expected valid to be %v, got %v", test.schemeID, test.reference, test.valid, err)
+		}
+	}
+}
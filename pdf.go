@@ -0,0 +1,1027 @@
+package basware
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// This file contains a minimal PDF object model with a reader and an
+// incremental update writer. It supports what is needed to add embedded files
+// and metadata to an existing document: classic cross reference tables, cross
+// reference streams and object streams. It doesn't render or interpret page
+// content.
+
+type pdfObject interface{}
+
+// pdfName is a PDF name without the leading slash
+type pdfName string
+
+// pdfRef is an indirect object reference (12 0 R)
+type pdfRef struct {
+	Num int
+	Gen int
+}
+
+// pdfRaw holds numbers, booleans, null and strings exactly as they appear in
+// the file
+type pdfRaw string
+
+type pdfArray []pdfObject
+
+// pdfDict keeps the order of its keys so rewritten objects stay recognizable
+type pdfDict struct {
+	keys   []pdfName
+	values map[pdfName]pdfObject
+}
+
+type pdfStream struct {
+	Dict *pdfDict
+
+	// Data as stored in the file, still encoded
+	Data []byte
+}
+
+func newPDFDict() *pdfDict {
+	return &pdfDict{values: map[pdfName]pdfObject{}}
+}
+
+func (d *pdfDict) get(key pdfName) pdfObject {
+	if d == nil {
+		return nil
+	}
+	return d.values[key]
+}
+
+func (d *pdfDict) set(key pdfName, value pdfObject) *pdfDict {
+	if _, ok := d.values[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.values[key] = value
+	return d
+}
+
+func (d *pdfDict) copy() *pdfDict {
+	c := newPDFDict()
+	if d == nil {
+		return c
+	}
+	for _, k := range d.keys {
+		c.set(k, d.values[k])
+	}
+	return c
+}
+
+// pdfString creates a text string: a literal string for ASCII text, UTF-16BE
+// with byte order mark otherwise
+func pdfString(s string) pdfRaw {
+	for _, c := range []byte(s) {
+		if c >= 0x80 {
+			buf := []byte{0xfe, 0xff}
+			for _, u := range utf16.Encode([]rune(s)) {
+				buf = append(buf, byte(u>>8), byte(u))
+			}
+			return pdfRaw("<" + strings.ToUpper(hex.EncodeToString(buf)) + ">")
+		}
+	}
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", `\r`, "\n", `\n`)
+	return pdfRaw("(" + r.Replace(s) + ")")
+}
+
+// decodePDFString returns the text of a literal or hex string. Text without
+// byte order mark is read as Latin-1, which covers the printable ASCII and
+// most of PDFDocEncoding.
+func decodePDFString(raw pdfRaw) (string, bool) {
+	s := string(raw)
+	data := []byte{}
+	switch {
+	case strings.HasPrefix(s, "<") && strings.HasSuffix(s, ">"):
+		digits := strings.Map(func(r rune) rune {
+			if isPDFWhitespace(byte(r)) {
+				return -1
+			}
+			return r
+		}, s[1:len(s)-1])
+		if len(digits)%2 == 1 {
+			digits += "0"
+		}
+		b, err := hex.DecodeString(digits)
+		if err != nil {
+			return "", false
+		}
+		data = b
+	case strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")"):
+		s = s[1 : len(s)-1]
+		for i := 0; i < len(s); i++ {
+			if s[i] != '\\' || i+1 == len(s) {
+				data = append(data, s[i])
+				continue
+			}
+			i++
+			switch c := s[i]; c {
+			case 'n':
+				data = append(data, '\n')
+			case 'r':
+				data = append(data, '\r')
+			case 't':
+				data = append(data, '\t')
+			case 'b':
+				data = append(data, '\b')
+			case 'f':
+				data = append(data, '\f')
+			case '\r', '\n':
+				// line continuation
+				if c == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+					i++
+				}
+			default:
+				if c >= '0' && c <= '7' {
+					v := 0
+					j := i
+					for ; j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7'; j++ {
+						v = v*8 + int(s[j]-'0')
+					}
+					data = append(data, byte(v))
+					i = j - 1
+				} else {
+					data = append(data, c)
+				}
+			}
+		}
+	default:
+		return "", false
+	}
+
+	if len(data) >= 2 && data[0] == 0xfe && data[1] == 0xff {
+		units := make([]uint16, (len(data)-2)/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(data[2+2*i:])
+		}
+		return string(utf16.Decode(units)), true
+	}
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes), true
+}
+
+func pdfInt(i int) pdfRaw {
+	return pdfRaw(strconv.Itoa(i))
+}
+
+// writePDFObject serializes an object
+func writePDFObject(buf *bytes.Buffer, obj pdfObject) {
+	switch o := obj.(type) {
+	case nil:
+		buf.WriteString("null")
+	case pdfName:
+		buf.WriteString("/")
+		for _, b := range []byte(o) {
+			if b < '!' || b > '~' || strings.IndexByte("#/()<>[]{}%", b) >= 0 {
+				fmt.Fprintf(buf, "#%02X", b)
+			} else {
+				buf.WriteByte(b)
+			}
+		}
+	case pdfRef:
+		fmt.Fprintf(buf, "%d %d R", o.Num, o.Gen)
+	case pdfRaw:
+		buf.WriteString(string(o))
+	case pdfArray:
+		buf.WriteString("[")
+		for i, v := range o {
+			if i > 0 {
+				buf.WriteString(" ")
+			}
+			writePDFObject(buf, v)
+		}
+		buf.WriteString("]")
+	case *pdfDict:
+		buf.WriteString("<<")
+		for _, k := range o.keys {
+			writePDFObject(buf, k)
+			buf.WriteString(" ")
+			writePDFObject(buf, o.values[k])
+		}
+		buf.WriteString(">>")
+	case *pdfStream:
+		o.Dict.set("Length", pdfInt(len(o.Data)))
+		writePDFObject(buf, o.Dict)
+		buf.WriteString("\nstream\n")
+		buf.Write(o.Data)
+		buf.WriteString("\nendstream")
+	}
+}
+
+// pdfParser reads objects from a byte slice
+type pdfParser struct {
+	data []byte
+	pos  int
+
+	// depth of the arrays and dictionaries being parsed
+	depth int
+}
+
+// maxPDFNesting limits the depth of nested arrays and dictionaries
+const maxPDFNesting = 256
+
+func isPDFWhitespace(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\r' || b == '\t' || b == '\f' || b == 0
+}
+
+func isPDFDelimiter(b byte) bool {
+	return strings.IndexByte("()<>[]{}/%", b) >= 0
+}
+
+func (p *pdfParser) skipWhitespace() {
+	for p.pos < len(p.data) {
+		b := p.data[p.pos]
+		if isPDFWhitespace(b) {
+			p.pos++
+			continue
+		}
+		if b == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+// token returns the next regular token (number, keyword) without consuming
+// delimiters
+func (p *pdfParser) token() string {
+	p.skipWhitespace()
+	start := p.pos
+	for p.pos < len(p.data) && !isPDFWhitespace(p.data[p.pos]) && !isPDFDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+func (p *pdfParser) peek(s string) bool {
+	p.skipWhitespace()
+	return bytes.HasPrefix(p.data[p.pos:], []byte(s))
+}
+
+func (p *pdfParser) parseObject() (pdfObject, error) {
+	p.skipWhitespace()
+	if p.pos >= len(p.data) {
+		return nil, errors.New("pdf: unexpected end of data")
+	}
+
+	switch b := p.data[p.pos]; {
+	case bytes.HasPrefix(p.data[p.pos:], []byte("<<")):
+		return p.parseDict()
+	case b == '[':
+		if p.depth >= maxPDFNesting {
+			return nil, fmt.Errorf("pdf: arrays nested too deeply at %d", p.pos)
+		}
+		p.depth++
+		defer func() { p.depth-- }()
+		p.pos++
+		arr := pdfArray{}
+		for !p.peek("]") {
+			if p.pos >= len(p.data) {
+				return nil, errors.New("pdf: unterminated array")
+			}
+			obj, err := p.parseObject()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, obj)
+		}
+		p.pos++
+		return arr, nil
+	case b == '/':
+		p.pos++
+		start := p.pos
+		for p.pos < len(p.data) && !isPDFWhitespace(p.data[p.pos]) && !isPDFDelimiter(p.data[p.pos]) {
+			p.pos++
+		}
+		return pdfName(decodePDFName(string(p.data[start:p.pos]))), nil
+	case b == '(':
+		start := p.pos
+		depth := 0
+		for p.pos < len(p.data) {
+			c := p.data[p.pos]
+			p.pos++
+			switch c {
+			case '\\':
+				p.pos++
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if depth == 0 {
+				return pdfRaw(p.data[start:p.pos]), nil
+			}
+		}
+		return nil, errors.New("pdf: unterminated string")
+	case b == '<':
+		end := bytes.IndexByte(p.data[p.pos:], '>')
+		if end < 0 {
+			return nil, errors.New("pdf: unterminated hex string")
+		}
+		raw := pdfRaw(p.data[p.pos : p.pos+end+1])
+		p.pos = p.pos + end + 1
+		return raw, nil
+	}
+
+	tok := p.token()
+	if tok == "" {
+		return nil, fmt.Errorf("pdf: unexpected character %q at %d", p.data[p.pos], p.pos)
+	}
+
+	// an integer could be the start of a reference
+	if num, err := strconv.Atoi(tok); err == nil {
+		save := p.pos
+		if gen, err := strconv.Atoi(p.token()); err == nil && p.token() == "R" {
+			return pdfRef{Num: num, Gen: gen}, nil
+		}
+		p.pos = save
+	}
+
+	return pdfRaw(tok), nil
+}
+
+func (p *pdfParser) parseDict() (*pdfDict, error) {
+	if p.depth >= maxPDFNesting {
+		return nil, fmt.Errorf("pdf: dictionaries nested too deeply at %d", p.pos)
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+	p.pos += 2
+	d := newPDFDict()
+	for !p.peek(">>") {
+		if p.pos >= len(p.data) {
+			return nil, errors.New("pdf: unterminated dictionary")
+		}
+		key, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			return nil, fmt.Errorf("pdf: expected name as dictionary key at %d", p.pos)
+		}
+		value, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		d.set(name, value)
+	}
+	p.pos += 2
+	return d, nil
+}
+
+func decodePDFName(s string) string {
+	if !strings.Contains(s, "#") {
+		return s
+	}
+
+	out := []byte{}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' && i+2 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				out = append(out, byte(v))
+				i += 2
+				continue
+			}
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
+
+// pdfXrefEntry locates an object: either at an offset in the file or inside
+// an object stream
+type pdfXrefEntry struct {
+	Offset      int
+	StreamNum   int
+	StreamIndex int
+	InObjectStm bool
+	Free        bool
+}
+
+// pdfReader gives access to the objects of an existing document
+type pdfReader struct {
+	data     []byte
+	version  string
+	xref     map[int]pdfXrefEntry
+	trailer  *pdfDict
+	lastXref int
+
+	// xrefStream is set when the newest cross reference section is a stream
+	xrefStream bool
+
+	objectStreams map[int]*pdfObjectStream
+
+	// objects being resolved, to stop at reference cycles
+	resolving map[int]bool
+}
+
+// maxPDFResolveDepth limits the chain of references resolved for one object
+const maxPDFResolveDepth = 32
+
+// pdfObjectStream is a decoded object stream
+type pdfObjectStream struct {
+	// pairs of object numbers and offsets
+	header []byte
+
+	// the objects
+	data []byte
+}
+
+func readPDF(data []byte) (*pdfReader, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, errors.New("pdf: missing %PDF header")
+	}
+
+	r := &pdfReader{
+		data:          data,
+		xref:          map[int]pdfXrefEntry{},
+		objectStreams: map[int]*pdfObjectStream{},
+		resolving:     map[int]bool{},
+	}
+
+	eol := bytes.IndexAny(data, "\r\n")
+	if eol > 5 {
+		r.version = strings.TrimSpace(string(data[5:eol]))
+	}
+
+	i := bytes.LastIndex(data, []byte("startxref"))
+	if i < 0 {
+		return nil, errors.New("pdf: startxref not found")
+	}
+	p := &pdfParser{data: data, pos: i + len("startxref")}
+	offset, err := strconv.Atoi(p.token())
+	if err != nil {
+		return nil, errors.New("pdf: invalid startxref")
+	}
+	r.lastXref = offset
+	if offset >= 0 && offset < len(data) {
+		r.xrefStream = !(&pdfParser{data: data, pos: offset}).peek("xref")
+	}
+
+	// walk the chain of cross reference sections from newest to oldest
+	seen := map[int]bool{}
+	for offset > 0 && !seen[offset] {
+		seen[offset] = true
+		trailer, err := r.readXref(offset)
+		if err != nil {
+			return nil, err
+		}
+		if r.trailer == nil {
+			r.trailer = trailer
+		}
+
+		if stm, ok := trailer.get("XRefStm").(pdfRaw); ok {
+			if o, err := strconv.Atoi(string(stm)); err == nil && !seen[o] {
+				seen[o] = true
+				if _, err := r.readXref(o); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		prev, ok := trailer.get("Prev").(pdfRaw)
+		if !ok {
+			break
+		}
+		offset, err = strconv.Atoi(string(prev))
+		if err != nil {
+			return nil, errors.New("pdf: invalid Prev offset")
+		}
+	}
+
+	if r.trailer == nil {
+		return nil, errors.New("pdf: trailer not found")
+	}
+	return r, nil
+}
+
+// addXref registers an entry unless a newer section already defined it
+func (r *pdfReader) addXref(num int, e pdfXrefEntry) {
+	if _, ok := r.xref[num]; !ok {
+		r.xref[num] = e
+	}
+}
+
+func (r *pdfReader) readXref(offset int) (*pdfDict, error) {
+	if offset < 0 || offset >= len(r.data) {
+		return nil, errors.New("pdf: xref offset out of range")
+	}
+
+	p := &pdfParser{data: r.data, pos: offset}
+	if p.peek("xref") {
+		p.pos += 4
+		return r.readXrefTable(p)
+	}
+
+	// cross reference stream
+	obj, err := r.parseIndirectObject(offset)
+	if err != nil {
+		return nil, err
+	}
+	stream, ok := obj.(*pdfStream)
+	if !ok {
+		return nil, errors.New("pdf: expected cross reference stream")
+	}
+	return stream.Dict, r.readXrefStream(stream)
+}
+
+func (r *pdfReader) readXrefTable(p *pdfParser) (*pdfDict, error) {
+	for {
+		if p.peek("trailer") {
+			p.pos += len("trailer")
+			p.skipWhitespace()
+			return p.parseDict()
+		}
+
+		start, err1 := strconv.Atoi(p.token())
+		count, err2 := strconv.Atoi(p.token())
+		if err1 != nil || err2 != nil {
+			return nil, errors.New("pdf: invalid xref subsection")
+		}
+
+		for i := 0; i < count; i++ {
+			offset, err1 := strconv.Atoi(p.token())
+			_, err2 := strconv.Atoi(p.token())
+			kind := p.token()
+			if err1 != nil || err2 != nil {
+				return nil, errors.New("pdf: invalid xref entry")
+			}
+			r.addXref(start+i, pdfXrefEntry{Offset: offset, Free: kind != "n"})
+		}
+	}
+}
+
+func (r *pdfReader) readXrefStream(stream *pdfStream) error {
+	data, err := r.decodeStream(stream)
+	if err != nil {
+		return err
+	}
+
+	widths := []int{}
+	if w, ok := stream.Dict.get("W").(pdfArray); ok {
+		for _, v := range w {
+			raw, ok := v.(pdfRaw)
+			if !ok {
+				return errors.New("pdf: invalid cross reference stream widths")
+			}
+			i, err := strconv.Atoi(string(raw))
+			if err != nil || i < 0 {
+				return errors.New("pdf: invalid cross reference stream widths")
+			}
+			widths = append(widths, i)
+		}
+	}
+	if len(widths) != 3 || widths[0]+widths[1]+widths[2] == 0 {
+		return errors.New("pdf: invalid cross reference stream widths")
+	}
+
+	size, _ := strconv.Atoi(string(r.raw(stream.Dict.get("Size"))))
+	index := []int{0, size}
+	if idx, ok := stream.Dict.get("Index").(pdfArray); ok {
+		index = []int{}
+		for _, v := range idx {
+			i, _ := strconv.Atoi(string(r.raw(v)))
+			index = append(index, i)
+		}
+	}
+
+	field := func(b []byte) int {
+		v := 0
+		for _, c := range b {
+			v = v<<8 | int(c)
+		}
+		return v
+	}
+
+	rowSize := widths[0] + widths[1] + widths[2]
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		for num := index[i]; num < index[i]+index[i+1]; num++ {
+			if pos+rowSize > len(data) {
+				return errors.New("pdf: cross reference stream too short")
+			}
+			row := data[pos : pos+rowSize]
+			pos += rowSize
+
+			kind := 1
+			if widths[0] > 0 {
+				kind = field(row[0:widths[0]])
+			}
+			f2 := field(row[widths[0] : widths[0]+widths[1]])
+			f3 := field(row[widths[0]+widths[1]:])
+
+			switch kind {
+			case 0:
+				r.addXref(num, pdfXrefEntry{Free: true})
+			case 1:
+				r.addXref(num, pdfXrefEntry{Offset: f2})
+			case 2:
+				r.addXref(num, pdfXrefEntry{InObjectStm: true, StreamNum: f2, StreamIndex: f3})
+			}
+		}
+	}
+	return nil
+}
+
+// raw resolves references to direct values
+func (r *pdfReader) raw(obj pdfObject) pdfRaw {
+	if ref, ok := obj.(pdfRef); ok {
+		resolved, err := r.object(ref)
+		if err != nil {
+			return ""
+		}
+		obj = resolved
+	}
+	raw, _ := obj.(pdfRaw)
+	return raw
+}
+
+// parseIndirectObject parses "num gen obj ... endobj" at offset
+func (r *pdfReader) parseIndirectObject(offset int) (pdfObject, error) {
+	if offset < 0 || offset >= len(r.data) {
+		return nil, fmt.Errorf("pdf: object offset %d out of range", offset)
+	}
+	p := &pdfParser{data: r.data, pos: offset}
+	p.token()
+	p.token()
+	if p.token() != "obj" {
+		return nil, fmt.Errorf("pdf: expected object at offset %d", offset)
+	}
+
+	obj, err := p.parseObject()
+	if err != nil {
+		return nil, err
+	}
+
+	dict, ok := obj.(*pdfDict)
+	if !ok || !p.peek("stream") {
+		return obj, nil
+	}
+
+	// stream data starts after the EOL following the keyword
+	p.pos += len("stream")
+	if p.pos < len(r.data) && r.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(r.data) && r.data[p.pos] == '\n' {
+		p.pos++
+	}
+
+	length := -1
+	if l, err := strconv.Atoi(string(r.raw(dict.get("Length")))); err == nil {
+		length = l
+	}
+	if length < 0 || p.pos+length > len(r.data) {
+		end := bytes.Index(r.data[p.pos:], []byte("endstream"))
+		if end < 0 {
+			return nil, errors.New("pdf: unterminated stream")
+		}
+		length = end
+	}
+
+	return &pdfStream{Dict: dict, Data: r.data[p.pos : p.pos+length]}, nil
+}
+
+// object returns the object referenced by ref
+func (r *pdfReader) object(ref pdfRef) (pdfObject, error) {
+	e, ok := r.xref[ref.Num]
+	if !ok || e.Free {
+		return nil, fmt.Errorf("pdf: object %d not found", ref.Num)
+	}
+
+	// e.g. a stream whose length refers to the stream itself
+	if r.resolving[ref.Num] {
+		return nil, fmt.Errorf("pdf: object %d refers to itself", ref.Num)
+	}
+	if len(r.resolving) >= maxPDFResolveDepth {
+		return nil, fmt.Errorf("pdf: references of object %d nested too deeply", ref.Num)
+	}
+	r.resolving[ref.Num] = true
+	defer delete(r.resolving, ref.Num)
+
+	if !e.InObjectStm {
+		return r.parseIndirectObject(e.Offset)
+	}
+
+	objstm, ok := r.objectStreams[e.StreamNum]
+	if !ok {
+		obj, err := r.object(pdfRef{Num: e.StreamNum})
+		if err != nil {
+			return nil, err
+		}
+		stream, ok := obj.(*pdfStream)
+		if !ok {
+			return nil, fmt.Errorf("pdf: object %d is not an object stream", e.StreamNum)
+		}
+		data, err := r.decodeStream(stream)
+		if err != nil {
+			return nil, err
+		}
+		first, err := strconv.Atoi(string(r.raw(stream.Dict.get("First"))))
+		if err != nil || first > len(data) {
+			return nil, fmt.Errorf("pdf: invalid object stream %d", e.StreamNum)
+		}
+
+		objstm = &pdfObjectStream{header: data[:first], data: data[first:]}
+		r.objectStreams[e.StreamNum] = objstm
+	}
+
+	p := &pdfParser{data: objstm.header}
+	for {
+		num, err1 := strconv.Atoi(p.token())
+		off, err2 := strconv.Atoi(p.token())
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("pdf: object %d not found in object stream", ref.Num)
+		}
+		if num == ref.Num {
+			op := &pdfParser{data: objstm.data, pos: off}
+			return op.parseObject()
+		}
+	}
+}
+
+// decodeStream undoes the Flate compression and PNG predictors used by cross
+// reference and object streams
+func (r *pdfReader) decodeStream(stream *pdfStream) ([]byte, error) {
+	filter := stream.Dict.get("Filter")
+	if arr, ok := filter.(pdfArray); ok && len(arr) == 1 {
+		filter = arr[0]
+	}
+	if filter == nil {
+		return stream.Data, nil
+	}
+	if filter != pdfName("FlateDecode") {
+		return nil, fmt.Errorf("pdf: unsupported stream filter %v", filter)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(stream.Data))
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	params, _ := stream.Dict.get("DecodeParms").(*pdfDict)
+	if arr, ok := stream.Dict.get("DecodeParms").(pdfArray); ok && len(arr) == 1 {
+		params, _ = arr[0].(*pdfDict)
+	}
+	rawPredictor, _ := params.get("Predictor").(pdfRaw)
+	predictor, _ := strconv.Atoi(string(rawPredictor))
+	if predictor < 10 {
+		return data, nil
+	}
+
+	columns := 1
+	if c, ok := params.get("Columns").(pdfRaw); ok {
+		columns, _ = strconv.Atoi(string(c))
+	}
+	return unpredictPNG(data, columns)
+}
+
+// unpredictPNG reverses the PNG row filters with one byte per pixel
+func unpredictPNG(data []byte, columns int) ([]byte, error) {
+	rowSize := columns + 1
+	if len(data)%rowSize != 0 {
+		return nil, errors.New("pdf: invalid predictor data")
+	}
+
+	out := make([]byte, 0, len(data)/rowSize*columns)
+	prev := make([]byte, columns)
+	for i := 0; i < len(data); i += rowSize {
+		filter := data[i]
+		row := make([]byte, columns)
+		copy(row, data[i+1:i+rowSize])
+		for j := 0; j < columns; j++ {
+			left := byte(0)
+			upLeft := byte(0)
+			if j > 0 {
+				left = row[j-1]
+				upLeft = prev[j-1]
+			}
+			switch filter {
+			case 1:
+				row[j] += left
+			case 2:
+				row[j] += prev[j]
+			case 3:
+				row[j] += byte((int(left) + int(prev[j])) / 2)
+			case 4:
+				row[j] += paeth(left, prev[j], upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// pdfUpdate collects new and replaced objects and appends them to the
+// original document as an incremental update
+type pdfUpdate struct {
+	reader  *pdfReader
+	size    int
+	objects map[int]pdfObject
+}
+
+func newPDFUpdate(r *pdfReader) *pdfUpdate {
+	size, _ := strconv.Atoi(string(r.raw(r.trailer.get("Size"))))
+	return &pdfUpdate{reader: r, size: size, objects: map[int]pdfObject{}}
+}
+
+// add stores a new object and returns its reference
+func (u *pdfUpdate) add(obj pdfObject) pdfRef {
+	ref := pdfRef{Num: u.size}
+	u.size++
+	u.objects[ref.Num] = obj
+	return ref
+}
+
+// replace stores a new version of an existing object
+func (u *pdfUpdate) replace(ref pdfRef, obj pdfObject) {
+	u.objects[ref.Num] = obj
+}
+
+// bytes returns the original document followed by the update. The update
+// has a cross reference stream when the original has one, a classic table
+// otherwise, and a new second file identifier.
+func (u *pdfUpdate) bytes(trailer *pdfDict) []byte {
+	buf := bytes.NewBuffer(append([]byte{}, u.reader.data...))
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteString("\n")
+	}
+
+	nums := []int{}
+	for num := range u.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	offsets := map[int]int{}
+	for _, num := range nums {
+		offsets[num] = buf.Len()
+		fmt.Fprintf(buf, "%d 0 obj\n", num)
+		writePDFObject(buf, u.objects[num])
+		buf.WriteString("\nendobj\n")
+	}
+
+	// the first identifier stays, the second identifies this version
+	sum := md5.Sum(buf.Bytes())
+	var first pdfObject
+	if id, ok := u.reader.trailer.get("ID").(pdfArray); ok && len(id) == 2 {
+		first = id[0]
+	} else {
+		original := md5.Sum(u.reader.data)
+		first = pdfRaw("<" + hex.EncodeToString(original[:]) + ">")
+	}
+	trailer.set("ID", pdfArray{first, pdfRaw("<" + hex.EncodeToString(sum[:]) + ">")})
+
+	xref := buf.Len()
+	if u.reader.xrefStream {
+		// the stream lists itself
+		num := u.size
+		u.size++
+		offsets[num] = xref
+		nums = append(nums, num)
+	}
+
+	// subsections of consecutive object numbers
+	sections := [][]int{}
+	for i := 0; i < len(nums); {
+		j := i
+		for j+1 < len(nums) && nums[j+1] == nums[j]+1 {
+			j++
+		}
+		sections = append(sections, nums[i:j+1])
+		i = j + 1
+	}
+
+	trailer.set("Size", pdfInt(u.size))
+	trailer.set("Prev", pdfInt(u.reader.lastXref))
+
+	if u.reader.xrefStream {
+		index := pdfArray{}
+		data := []byte{}
+		for _, section := range sections {
+			index = append(index, pdfInt(section[0]), pdfInt(len(section)))
+			for _, num := range section {
+				row := []byte{1, 0, 0, 0, 0, 0}
+				binary.BigEndian.PutUint32(row[1:5], uint32(offsets[num]))
+				data = append(data, row...)
+			}
+		}
+		dict := newPDFDict().
+			set("Type", pdfName("XRef")).
+			set("W", pdfArray{pdfInt(1), pdfInt(4), pdfInt(1)}).
+			set("Index", index)
+		for _, k := range trailer.keys {
+			dict.set(k, trailer.values[k])
+		}
+		fmt.Fprintf(buf, "%d 0 obj\n", nums[len(nums)-1])
+		writePDFObject(buf, &pdfStream{Dict: dict, Data: data})
+		buf.WriteString("\nendobj\n")
+	} else {
+		buf.WriteString("xref\n")
+		for _, section := range sections {
+			fmt.Fprintf(buf, "%d %d\n", section[0], len(section))
+			for _, num := range section {
+				fmt.Fprintf(buf, "%010d 00000 n\r\n", offsets[num])
+			}
+		}
+		buf.WriteString("trailer\n")
+		writePDFObject(buf, trailer)
+		buf.WriteString("\n")
+	}
+	fmt.Fprintf(buf, "startxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
+
+// pdfWriter creates a new document
+type pdfWriter struct {
+	objects []pdfObject
+}
+
+// add stores an object and returns its reference
+func (w *pdfWriter) add(obj pdfObject) pdfRef {
+	w.objects = append(w.objects, obj)
+	return pdfRef{Num: len(w.objects)}
+}
+
+// reserve returns a reference for an object that is set later, needed for
+// objects referring to each other
+func (w *pdfWriter) reserve() pdfRef {
+	return w.add(nil)
+}
+
+func (w *pdfWriter) set(ref pdfRef, obj pdfObject) {
+	w.objects[ref.Num-1] = obj
+}
+
+// bytes writes the document with a classic cross reference table
+func (w *pdfWriter) bytes(root pdfRef, info pdfRef) []byte {
+	buf := new(bytes.Buffer)
+	// binary comment marks the file as binary for transfer programs
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, len(w.objects))
+	for i, obj := range w.objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(buf, "%d 0 obj\n", i+1)
+		writePDFObject(buf, obj)
+		buf.WriteString("\nendobj\n")
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f\r\n", len(w.objects)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n\r\n", o)
+	}
+
+	trailer := newPDFDict().
+		set("Size", pdfInt(len(w.objects)+1)).
+		set("Root", root).
+		set("Info", info)
+	buf.WriteString("trailer\n")
+	writePDFObject(buf, trailer)
+	fmt.Fprintf(buf, "\nstartxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
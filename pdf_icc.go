@@ -0,0 +1,96 @@
+package basware
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// srgbICCProfile builds a small ICC v2 display profile approximating sRGB
+// (D50 adapted primaries, gamma 2.2). PDF/A requires an output intent with an
+// embedded profile; generating it avoids shipping a binary profile.
+func srgbICCProfile() []byte {
+	type tag struct {
+		sig  string
+		data []byte
+	}
+
+	s15 := func(f float64) []byte {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, uint32(int32(f*65536+0.5)))
+		return b
+	}
+
+	xyz := func(x, y, z float64) []byte {
+		b := []byte("XYZ \x00\x00\x00\x00")
+		b = append(b, s15(x)...)
+		b = append(b, s15(y)...)
+		return append(b, s15(z)...)
+	}
+
+	// gamma 2.2 as u8Fixed8Number
+	curve := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x01\x02\x33\x00\x00")
+
+	desc := func(s string) []byte {
+		b := []byte("desc\x00\x00\x00\x00")
+		count := make([]byte, 4)
+		binary.BigEndian.PutUint32(count, uint32(len(s)+1))
+		b = append(b, count...)
+		b = append(b, s...)
+		b = append(b, 0)
+		// empty unicode and scriptcode descriptions
+		b = append(b, make([]byte, 4+4+2+1+67)...)
+		return b
+	}
+
+	text := func(s string) []byte {
+		b := []byte("text\x00\x00\x00\x00")
+		b = append(b, s...)
+		return append(b, 0)
+	}
+
+	tags := []tag{
+		{"desc", desc("sRGB")},
+		{"cprt", text("No copyright, use freely")},
+		{"wtpt", xyz(0.9642, 1.0, 0.8249)},
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", curve},
+		{"gTRC", curve},
+		{"bTRC", curve},
+	}
+
+	tableSize := 4 + 12*len(tags)
+	offset := 128 + tableSize
+	table := new(bytes.Buffer)
+	body := new(bytes.Buffer)
+	binary.Write(table, binary.BigEndian, uint32(len(tags)))
+	for _, t := range tags {
+		for body.Len()%4 != 0 {
+			body.WriteByte(0)
+		}
+		table.WriteString(t.sig)
+		binary.Write(table, binary.BigEndian, uint32(offset+body.Len()))
+		binary.Write(table, binary.BigEndian, uint32(len(t.data)))
+		body.Write(t.data)
+	}
+	for body.Len()%4 != 0 {
+		body.WriteByte(0)
+	}
+
+	size := 128 + tableSize + body.Len()
+	header := new(bytes.Buffer)
+	binary.Write(header, binary.BigEndian, uint32(size))
+	header.Write(make([]byte, 4))                              // preferred CMM
+	binary.Write(header, binary.BigEndian, uint32(0x02100000)) // version 2.1
+	header.WriteString("mntrRGB XYZ ")
+	header.Write([]byte{0x07, 0xe1, 0, 1, 0, 1, 0, 0, 0, 0, 0, 0}) // 2017-01-01
+	header.WriteString("acsp")
+	header.Write(make([]byte, 4+4+4+4+8+4)) // platform, flags, manufacturer, model, attributes, intent
+	header.Write(s15(0.9642))
+	header.Write(s15(1.0))
+	header.Write(s15(0.8249))
+	header.Write(make([]byte, 128-header.Len()))
+
+	return append(append(header.Bytes(), table.Bytes()...), body.Bytes()...)
+}
@@ -0,0 +1,222 @@
+package basware
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// postalAddressFormat holds the postal rules of a country
+type postalAddressFormat struct {
+	name string
+
+	// Postal code format; nil when the country has no postal codes
+	postalCode         *regexp.Regexp
+	postalCodeOptional bool
+
+	// Valid country subentities; nil when the post doesn't need them
+	subentities map[string]bool
+
+	// Lines with the postal code, city and subentity; defaults to the postal
+	// code followed by the city
+	lastLines func(a PostalAddress) []string
+}
+
+var (
+	usStates    = codeSet("AL AK AZ AR CA CO CT DE DC FL GA HI ID IL IN IA KS KY LA ME MD MA MI MN MS MO MT NE NV NH NJ NM NY NC ND OH OK OR PA RI SC SD TN TX UT VT VA WA WV WI WY AS GU MP PR VI AA AE AP")
+	caProvinces = codeSet("AB BC MB NB NL NS NT NU ON PE QC SK YT")
+	auStates    = codeSet("ACT NSW NT QLD SA TAS VIC WA")
+)
+
+var postalAddressFormats = map[string]postalAddressFormat{
+	"AT": {name: "Austria", postalCode: regexp.MustCompile(`^[0-9]{4}$`)},
+	"AU": {name: "Australia", postalCode: regexp.MustCompile(`^[0-9]{4}$`), subentities: auStates, lastLines: func(a PostalAddress) []string {
+		return []string{joinNonEmpty(" ", strings.ToUpper(a.CityName), a.CountrySubentity, a.PostalZone)}
+	}},
+	"BE": {name: "Belgium", postalCode: regexp.MustCompile(`^[0-9]{4}$`)},
+	"BG": {name: "Bulgaria", postalCode: regexp.MustCompile(`^[0-9]{4}$`)},
+	"CA": {name: "Canada", postalCode: regexp.MustCompile(`^[A-Z][0-9][A-Z] ?[0-9][A-Z][0-9]$`), subentities: caProvinces, lastLines: func(a PostalAddress) []string {
+		return []string{joinNonEmpty(" ", a.CityName, a.CountrySubentity, a.PostalZone)}
+	}},
+	"CH": {name: "Switzerland", postalCode: regexp.MustCompile(`^[0-9]{4}$`)},
+	"CY": {name: "Cyprus", postalCode: regexp.MustCompile(`^[0-9]{4}$`)},
+	"CZ": {name: "Czech Republic", postalCode: regexp.MustCompile(`^[0-9]{3} ?[0-9]{2}$`)},
+	"DE": {name: "Germany", postalCode: regexp.MustCompile(`^[0-9]{5}$`)},
+	"DK": {name: "Denmark", postalCode: regexp.MustCompile(`^[0-9]{4}$`)},
+	"EE": {name: "Estonia", postalCode: regexp.MustCompile(`^[0-9]{5}$`)},
+	"ES": {name: "Spain", postalCode: regexp.MustCompile(`^[0-9]{5}$`)},
+	"FI": {name: "Finland", postalCode: regexp.MustCompile(`^[0-9]{5}$`)},
+	"FR": {name: "France", postalCode: regexp.MustCompile(`^[0-9]{5}$`), lastLines: func(a PostalAddress) []string {
+		return []string{joinNonEmpty(" ", a.PostalZone, strings.ToUpper(a.CityName))}
+	}},
+	"GB": {name: "United Kingdom", postalCode: regexp.MustCompile(`^[A-Z]{1,2}[0-9][A-Z0-9]? ?[0-9][A-Z]{2}$`), lastLines: func(a PostalAddress) []string {
+		return []string{strings.ToUpper(a.CityName), a.PostalZone}
+	}},
+	"GR": {name: "Greece", postalCode: regexp.MustCompile(`^[0-9]{3} ?[0-9]{2}$`)},
+	"HR": {name: "Croatia", postalCode: regexp.MustCompile(`^[0-9]{5}$`)},
+	"HU": {name: "Hungary", postalCode: regexp.MustCompile(`^[0-9]{4}$`)},
+	"IE": {name: "Ireland", postalCode: regexp.MustCompile(`^([AC-FHKNPRTV-Y][0-9]{2}|D6W) ?[0-9AC-FHKNPRTV-Y]{4}$`), postalCodeOptional: true, lastLines: func(a PostalAddress) []string {
+		return []string{a.CityName, a.CountrySubentity, a.PostalZone}
+	}},
+	"IS": {name: "Iceland", postalCode: regexp.MustCompile(`^[0-9]{3}$`)},
+	"IT": {name: "Italy", postalCode: regexp.MustCompile(`^[0-9]{5}$`), lastLines: func(a PostalAddress) []string {
+		return []string{joinNonEmpty(" ", a.PostalZone, a.CityName, a.CountrySubentity)}
+	}},
+	"LT": {name: "Lithuania", postalCode: regexp.MustCompile(`^(LT-)?[0-9]{5}$`)},
+	"LU": {name: "Luxembourg", postalCode: regexp.MustCompile(`^(L-)?[0-9]{4}$`)},
+	"LV": {name: "Latvia", postalCode: regexp.MustCompile(`^LV-[0-9]{4}$`)},
+	"MT": {name: "Malta", postalCode: regexp.MustCompile(`^[A-Z]{3} ?[0-9]{4}$`)},
+	"NL": {name: "Netherlands", postalCode: regexp.MustCompile(`^[1-9][0-9]{3} ?[A-Z]{2}$`), lastLines: func(a PostalAddress) []string {
+		return []string{joinNonEmpty("  ", a.PostalZone, strings.ToUpper(a.CityName))}
+	}},
+	"NO": {name: "Norway", postalCode: regexp.MustCompile(`^[0-9]{4}$`)},
+	"PL": {name: "Poland", postalCode: regexp.MustCompile(`^[0-9]{2}-[0-9]{3}$`)},
+	"PT": {name: "Portugal", postalCode: regexp.MustCompile(`^[0-9]{4}-[0-9]{3}$`)},
+	"RO": {name: "Romania", postalCode: regexp.MustCompile(`^[0-9]{6}$`)},
+	"SE": {name: "Sweden", postalCode: regexp.MustCompile(`^[0-9]{3} ?[0-9]{2}$`)},
+	"SI": {name: "Slovenia", postalCode: regexp.MustCompile(`^[0-9]{4}$`)},
+	"SK": {name: "Slovakia", postalCode: regexp.MustCompile(`^[0-9]{3} ?[0-9]{2}$`)},
+	"US": {name: "United States", postalCode: regexp.MustCompile(`^[0-9]{5}(-[0-9]{4})?$`), subentities: usStates, lastLines: func(a PostalAddress) []string {
+		return []string{joinNonEmpty(" ", joinNonEmpty(", ", a.CityName, a.CountrySubentity), a.PostalZone)}
+	}},
+}
+
+// postalAddressFields returns the invalid fields of an address for printing
+// with the reason, keyed by their JSON name
+func postalAddressFields(a PostalAddress) map[string]string {
+	invalid := map[string]string{}
+	if strings.TrimSpace(a.AddressLine) == "" {
+		invalid["addressLine"] = "Expected a street address"
+	}
+	if strings.TrimSpace(a.CityName) == "" {
+		invalid["cityName"] = "Expected a city"
+	}
+	if strings.TrimSpace(a.CountryID) == "" {
+		invalid["countryId"] = "Expected a country"
+		return invalid
+	}
+
+	f, ok := postalAddressFormats[strings.ToUpper(a.CountryID)]
+	if !ok {
+		return invalid
+	}
+	postalZone := strings.ToUpper(strings.TrimSpace(a.PostalZone))
+	if f.postalCode != nil && !f.postalCode.MatchString(postalZone) && !(f.postalCodeOptional && postalZone == "") {
+		invalid["postalZone"] = fmt.Sprintf("Expected a %s postal code, got \"%s\"", f.name, a.PostalZone)
+	}
+	if f.subentities != nil && !f.subentities[strings.ToUpper(strings.TrimSpace(a.CountrySubentity))] {
+		invalid["countrySubentity"] = fmt.Sprintf("Expected a %s state or province code, got \"%s\"", f.name, a.CountrySubentity)
+	}
+	return invalid
+}
+
+// ValidatePostalAddress checks that an address is complete and printable: it
+// has a street address, city and country, a postal code in the format of its
+// country and, for countries where the post needs it (US, CA, AU), a state or
+// province code
+func ValidatePostalAddress(a PostalAddress) error {
+	var errors *multierror.Error
+	invalid := postalAddressFields(a)
+	// report in field order
+	for _, field := range []string{"addressLine", "cityName", "postalZone", "countrySubentity", "countryId"} {
+		if reason, ok := invalid[field]; ok {
+			errors = multierror.Append(errors, fmt.Errorf("%s: %s", field, reason))
+		}
+	}
+	return errors.ErrorOrNil()
+}
+
+// FormatPostalAddress returns the lines of an address block in the order of the
+// address's country. The country is added in English for addresses outside the
+// sender's country.
+func FormatPostalAddress(a PostalAddress, senderCountryID string) []string {
+	lines := splitNonEmpty(a.AddressLine)
+	lines = append(lines, splitNonEmpty(a.AddressLine2)...)
+	lines = append(lines, splitNonEmpty(a.Locality)...)
+
+	countryID := strings.ToUpper(strings.TrimSpace(a.CountryID))
+	f, ok := postalAddressFormats[countryID]
+	if ok && f.lastLines != nil {
+		for _, l := range f.lastLines(a) {
+			lines = append(lines, splitNonEmpty(l)...)
+		}
+	} else {
+		lines = append(lines, splitNonEmpty(joinNonEmpty(" ", a.PostalZone, a.CityName))...)
+		lines = append(lines, splitNonEmpty(a.CountrySubentity)...)
+	}
+
+	if countryID != "" && countryID != strings.ToUpper(senderCountryID) {
+		if ok {
+			lines = append(lines, strings.ToUpper(f.name))
+		} else {
+			lines = append(lines, countryID)
+		}
+	}
+	return lines
+}
+
+// PrintRuleSet checks that the buyer's address can be printed and posted
+var PrintRuleSet = RuleSet{
+	Name: "Print",
+	Rules: []Rule{
+		{
+			ID:       "PRINT-01",
+			Severity: RuleSeverityFatal,
+			Message:  "A printed invoice shall have a buyer name",
+			Check: func(inv Invoice) []string {
+				return requiredField("accountingCustomerParty.partyName", inv.AccountingCustomerParty.party().name())
+			},
+		},
+		printAddressRule("PRINT-02", "addressLine", "A printed invoice shall have a buyer street address"),
+		printAddressRule("PRINT-03", "cityName", "A printed invoice shall have a buyer city"),
+		printAddressRule("PRINT-04", "postalZone", "A printed invoice shall have a buyer postal code in the format of the buyer's country"),
+		printAddressRule("PRINT-05", "countrySubentity", "A printed invoice to the US, Canada or Australia shall have the buyer's state or province code"),
+		printAddressRule("PRINT-06", "countryId", "A printed invoice shall have a buyer country"),
+	},
+}
+
+func printAddressRule(id string, field string, message string) Rule {
+	return Rule{
+		ID:       id,
+		Severity: RuleSeverityFatal,
+		Message:  message,
+		Check: func(inv Invoice) []string {
+			if _, ok := postalAddressFields(inv.AccountingCustomerParty.PostalAddress)[field]; ok {
+				return []string{"accountingCustomerParty.postalAddress." + field}
+			}
+			return nil
+		},
+	}
+}
+
+// Validate checks the invoice with ValidateInvoice and the given extra rule
+// sets. When the delivery channel preference allows printing, the buyer's
+// address is checked with PrintRuleSet as well.
+func (b InvoicesPostRequestBody) Validate(ruleSets ...RuleSet) RuleViolations {
+	switch b.DeliveryChannelPreference {
+	case DeliveryChannelPrintingAlways, DeliveryChannelPrintingAllowed:
+		ruleSets = append(ruleSets, PrintRuleSet)
+	}
+	return ValidateInvoice(b.Data, ruleSets...)
+}
+
+func codeSet(codes string) map[string]bool {
+	set := map[string]bool{}
+	for _, c := range strings.Fields(codes) {
+		set[c] = true
+	}
+	return set
+}
+
+// joinNonEmpty joins the non-empty trimmed values
+func joinNonEmpty(sep string, values ...string) string {
+	nonEmpty := []string{}
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			nonEmpty = append(nonEmpty, v)
+		}
+	}
+	return strings.Join(nonEmpty, sep)
+}
@@ -0,0 +1,80 @@
+package basware_test
+
+import (
+	"strings"
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+func TestValidatePostalAddress(t *testing.T) {
+	tests := []struct {
+		address basware.PostalAddress
+		invalid string
+	}{
+		{basware.PostalAddress{AddressLine: "Main street 1", CityName: "Helsinki", PostalZone: "00100", CountryID: "FI"}, ""},
+		{basware.PostalAddress{AddressLine: "Damrak 1", CityName: "Amsterdam", PostalZone: "1012 lg", CountryID: "NL"}, ""},
+		{basware.PostalAddress{AddressLine: "10 Downing Street", CityName: "London", PostalZone: "SW1A 2AA", CountryID: "GB"}, ""},
+		{basware.PostalAddress{AddressLine: "1 Main St", CityName: "Springfield", PostalZone: "62701", CountrySubentity: "IL", CountryID: "US"}, ""},
+		{basware.PostalAddress{AddressLine: "Main Street", CityName: "Cork", CountryID: "IE"}, ""},
+		{basware.PostalAddress{AddressLine: "Main street 1", CityName: "Helsinki", PostalZone: "0010", CountryID: "FI"}, "postalZone"},
+		{basware.PostalAddress{AddressLine: "1 Main St", CityName: "Springfield", PostalZone: "62701", CountryID: "US"}, "countrySubentity"},
+		{basware.PostalAddress{CityName: "Oslo", PostalZone: "0150", CountryID: "NO"}, "addressLine"},
+		{basware.PostalAddress{AddressLine: "Main street 1", CityName: "Helsinki", PostalZone: "00100"}, "countryId"},
+	}
+	for _, test := range tests {
+		err := basware.ValidatePostalAddress(test.address)
+		if test.invalid == "" && err != nil {
+			t.Errorf("%+v: unexpected error %s", test.address, err)
+		}
+		if test.invalid != "" && (err == nil || !strings.Contains(err.Error(), test.invalid+":")) {
+			t.Errorf("%+v: expected %s to be invalid, got %v", test.address, test.invalid, err)
+		}
+	}
+}
+
+func TestFormatPostalAddress(t *testing.T) {
+	tests := []struct {
+		address  basware.PostalAddress
+		sender   string
+		expected string
+	}{
+		{basware.PostalAddress{AddressLine: "Main street 1", CityName: "Helsinki", PostalZone: "00100", CountryID: "FI"}, "FI", "Main street 1|00100 Helsinki"},
+		{basware.PostalAddress{AddressLine: "10 Downing Street", CityName: "London", PostalZone: "SW1A 2AA", CountryID: "GB"}, "FI", "10 Downing Street|LONDON|SW1A 2AA|UNITED KINGDOM"},
+		{basware.PostalAddress{AddressLine: "1 Main St", AddressLine2: "Suite 5", CityName: "Springfield", PostalZone: "62701", CountrySubentity: "IL", CountryID: "US"}, "US", "1 Main St|Suite 5|Springfield, IL 62701"},
+		{basware.PostalAddress{AddressLine: "Storgatan 2", CityName: "Stockholm", PostalZone: "111 22", CountryID: "SE"}, "FI", "Storgatan 2|111 22 Stockholm|SWEDEN"},
+	}
+	for _, test := range tests {
+		lines := strings.Join(basware.FormatPostalAddress(test.address, test.sender), "|")
+		if lines != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, lines)
+		}
+	}
+}
+
+func TestInvoicesPostRequestBodyValidate(t *testing.T) {
+	body := basware.InvoicesPostRequestBody{DeliveryChannelPreference: basware.DeliveryChannelPrintingAlways}
+	body.Data.AccountingCustomerParty.PostalAddress = basware.PostalAddress{CityName: "Springfield", PostalZone: "62701", CountryID: "US"}
+
+	found := map[string]string{}
+	for _, v := range body.Validate() {
+		found[v.RuleID] = v.Path
+	}
+	tests := map[string]string{
+		"PRINT-01": "accountingCustomerParty.partyName",
+		"PRINT-02": "accountingCustomerParty.postalAddress.addressLine",
+		"PRINT-05": "accountingCustomerParty.postalAddress.countrySubentity",
+	}
+	for id, path := range tests {
+		if found[id] != path {
+			t.Errorf("expected violation %s of %s, got %s", id, path, found[id])
+		}
+	}
+
+	body.DeliveryChannelPreference = basware.DeliveryChannelOnlyEInvoicing
+	for _, v := range body.Validate() {
+		if strings.HasPrefix(v.RuleID, "PRINT-") {
+			t.Errorf("unexpected print violation %s for e-invoicing", v.RuleID)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package basware
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Delivery channel preferences of InvoicesPostRequestBody
+const (
+	DeliveryChannelPrintingAlways  = "printing-always"
+	DeliveryChannelOnlyEInvoicing  = "only-eInvoicing"
+	DeliveryChannelPrintingAllowed = "printing-allowed"
+)
+
+// ReceiverDirectory looks up whether a participant can receive e-invoices
+type ReceiverDirectory interface {
+	Lookup(ctx context.Context, participant ParticipantID) (Receiver, error)
+}
+
+// Receiver is the result of a directory lookup
+type Receiver struct {
+	ParticipantID ParticipantID
+
+	// Whether the participant is registered in the directory
+	Registered bool
+
+	// Document type identifiers the participant accepts; empty when the
+	// directory doesn't tell
+	DocumentTypes []string
+}
+
+// CanReceiveInvoices reports whether the participant is registered and, when
+// the document types are known, accepts UBL or CII invoices
+func (r Receiver) CanReceiveInvoices() bool {
+	if !r.Registered {
+		return false
+	}
+	if len(r.DocumentTypes) == 0 {
+		return true
+	}
+	for _, dt := range r.DocumentTypes {
+		if strings.Contains(dt, "Invoice-2::Invoice") || strings.Contains(dt, "CrossIndustryInvoice") {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryChannelFor chooses the delivery channel preference for a receiver
+// endpoint: only-eInvoicing when the directory knows the endpoint as invoice
+// receiver, printing-allowed otherwise
+func DeliveryChannelFor(ctx context.Context, dir ReceiverDirectory, e Endpoint) (string, error) {
+	if e.ID == "" {
+		return DeliveryChannelPrintingAllowed, nil
+	}
+
+	p, err := e.ParticipantID()
+	if err != nil {
+		return "", err
+	}
+	receiver, err := dir.Lookup(ctx, p)
+	if err != nil {
+		return "", err
+	}
+	if receiver.CanReceiveInvoices() {
+		return DeliveryChannelOnlyEInvoicing, nil
+	}
+	return DeliveryChannelPrintingAllowed, nil
+}
+
+// SetDeliveryChannel sets the delivery channel preference from the
+// reachability of the buyer's endpoint. A preference that's already set is
+// kept.
+func (b *InvoicesPostRequestBody) SetDeliveryChannel(ctx context.Context, dir ReceiverDirectory) error {
+	if b.DeliveryChannelPreference != "" {
+		return nil
+	}
+	channel, err := DeliveryChannelFor(ctx, dir, b.Data.AccountingCustomerParty.Endpoint)
+	if err != nil {
+		return err
+	}
+	b.DeliveryChannelPreference = channel
+	return nil
+}
+
+// Peppol service metadata locator domains
+const (
+	SMLDomain     = "edelivery.tech.ec.europa.eu"
+	SMLDomainTest = "acc.edelivery.tech.ec.europa.eu"
+)
+
+// SMPDirectory looks up participants in their service metadata publisher
+// (SMP), found through the service metadata locator (SML) like Peppol access
+// points do
+type SMPDirectory struct {
+	// HTTP client used to query the SMP
+	HTTPClient *http.Client
+
+	// SML domain, defaults to SMLDomain
+	SMLDomain string
+
+	// Fixed SMP to query instead of the one the SML points to
+	SMPURL *url.URL
+}
+
+// NewSMPDirectory returns a directory using the production SML
+func NewSMPDirectory(httpClient *http.Client) *SMPDirectory {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &SMPDirectory{HTTPClient: httpClient, SMLDomain: SMLDomain}
+}
+
+// smpURL returns the SMP address of a participant: the host named after the
+// MD5 hash of the lower cased identifier in the SML domain
+func (d *SMPDirectory) smpURL(p ParticipantID) url.URL {
+	if d.SMPURL != nil {
+		return *d.SMPURL
+	}
+
+	domain := d.SMLDomain
+	if domain == "" {
+		domain = SMLDomain
+	}
+	hash := md5.Sum([]byte(strings.ToLower(p.String())))
+	return url.URL{
+		Scheme: "http",
+		Host:   "B-" + hex.EncodeToString(hash[:]) + "." + ParticipantIDScheme + "." + domain,
+	}
+}
+
+// Lookup fetches the service group of the participant from its SMP. A
+// participant without SMP registration is returned as not registered.
+func (d *SMPDirectory) Lookup(ctx context.Context, p ParticipantID) (Receiver, error) {
+	receiver := Receiver{ParticipantID: p}
+
+	u := d.smpURL(p)
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(u.String(), "/")+"/"+url.QueryEscape(p.URI()), nil)
+	if err != nil {
+		return receiver, err
+	}
+	req = req.WithContext(ctx)
+
+	httpClient := d.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// hosts of unregistered participants don't resolve
+		if isHostNotFound(err) {
+			return receiver, nil
+		}
+		return receiver, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return receiver, nil
+	case resp.StatusCode != http.StatusOK:
+		return receiver, fmt.Errorf("Unexpected SMP status %s for %s", resp.Status, p)
+	}
+
+	root, err := parseXMLNode(resp.Body)
+	if err != nil {
+		return receiver, err
+	}
+	receiver.Registered = true
+	for _, ref := range root.child("ServiceMetadataReferenceCollection").children("ServiceMetadataReference") {
+		if dt := documentTypeOfReference(ref.attr("href")); dt != "" {
+			receiver.DocumentTypes = append(receiver.DocumentTypes, dt)
+		}
+	}
+	return receiver, nil
+}
+
+// documentTypeOfReference returns the document type identifier of a service
+// metadata reference: the last path segment without its scheme
+func documentTypeOfReference(href string) string {
+	i := strings.LastIndex(href, "/services/")
+	if i < 0 {
+		return ""
+	}
+	dt, err := url.PathUnescape(href[i+len("/services/"):])
+	if err != nil {
+		return ""
+	}
+	if j := strings.Index(dt, "::"); j >= 0 {
+		dt = dt[j+2:]
+	}
+	return dt
+}
+
+func isHostNotFound(err error) bool {
+	if uerr, ok := err.(*url.Error); ok {
+		err = uerr.Err
+	}
+	if oerr, ok := err.(*net.OpError); ok {
+		err = oerr.Err
+	}
+	dnsErr, ok := err.(*net.DNSError)
+	return ok && dnsErr.IsNotFound
+}
+
+// StaticDirectory is an in memory directory of registered participants, e.g.
+// for tests. Participants are keyed by their normalized identifier.
+type StaticDirectory map[ParticipantID]Receiver
+
+// Lookup returns the participant's entry or an unregistered receiver
+func (d StaticDirectory) Lookup(ctx context.Context, p ParticipantID) (Receiver, error) {
+	if r, ok := d[p]; ok {
+		r.ParticipantID = p
+		return r, nil
+	}
+	return Receiver{ParticipantID: p}, nil
+}
+
+// CachedDirectory keeps the lookups of a directory for a time to live. Failed
+// lookups aren't cached.
+type CachedDirectory struct {
+	directory ReceiverDirectory
+	ttl       time.Duration
+
+	mu      sync.Mutex
+	entries map[ParticipantID]cachedReceiver
+}
+
+type cachedReceiver struct {
+	receiver Receiver
+	expires  time.Time
+}
+
+// NewCachedDirectory wraps a directory in a cache
+func NewCachedDirectory(directory ReceiverDirectory, ttl time.Duration) *CachedDirectory {
+	return &CachedDirectory{
+		directory: directory,
+		ttl:       ttl,
+		entries:   map[ParticipantID]cachedReceiver{},
+	}
+}
+
+// Lookup returns the cached receiver or looks it up in the wrapped directory
+func (d *CachedDirectory) Lookup(ctx context.Context, p ParticipantID) (Receiver, error) {
+	d.mu.Lock()
+	entry, ok := d.entries[p]
+	d.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.receiver, nil
+	}
+
+	receiver, err := d.directory.Lookup(ctx, p)
+	if err != nil {
+		return receiver, err
+	}
+
+	d.mu.Lock()
+	d.entries[p] = cachedReceiver{receiver: receiver, expires: time.Now().Add(d.ttl)}
+	d.mu.Unlock()
+	return receiver, nil
+}
+
+// Forget drops a participant from the cache
+func (d *CachedDirectory) Forget(p ParticipantID) {
+	d.mu.Lock()
+	delete(d.entries, p)
+	d.mu.Unlock()
+}
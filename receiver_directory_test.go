@@ -0,0 +1,78 @@
+package basware_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+const smpServiceGroup = `<?xml version="1.0" encoding="UTF-8"?>
+<ServiceGroup xmlns="http://busdox.org/serviceMetadata/publishing/1.0/" xmlns:id="http://busdox.org/transport/identifiers/1.0/">
+	<id:ParticipantIdentifier scheme="iso6523-actorid-upis">0192:923609016</id:ParticipantIdentifier>
+	<ServiceMetadataReferenceCollection>
+		<ServiceMetadataReference href="%s/iso6523-actorid-upis%%3A%%3A0192%%3A923609016/services/busdox-docid-qns%%3A%%3Aurn%%3Aoasis%%3Anames%%3Aspecification%%3Aubl%%3Aschema%%3Axsd%%3AInvoice-2%%3A%%3AInvoice%%23%%23urn%%3Acen.eu%%3Aen16931%%3A2017%%3A%%3A2.1"/>
+	</ServiceMetadataReferenceCollection>
+</ServiceGroup>`
+
+func TestSMPDirectory(t *testing.T) {
+	lookups := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		if r.URL.Path != "/iso6523-actorid-upis::0192:923609016" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, smpServiceGroup, server.URL)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	smp := basware.NewSMPDirectory(nil)
+	smp.SMPURL = u
+	dir := basware.NewCachedDirectory(smp, time.Hour)
+
+	ctx := context.Background()
+	body := &basware.InvoicesPostRequestBody{}
+	body.Data.AccountingCustomerParty.Endpoint = basware.Endpoint{ID: "923609016", SchemeID: "NO:ORGNR"}
+	for i := 0; i < 2; i++ {
+		body.DeliveryChannelPreference = ""
+		if err := body.SetDeliveryChannel(ctx, dir); err != nil {
+			t.Fatal(err)
+		}
+		if body.DeliveryChannelPreference != basware.DeliveryChannelOnlyEInvoicing {
+			t.Errorf("expected %s, got %s", basware.DeliveryChannelOnlyEInvoicing, body.DeliveryChannelPreference)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("expected the second lookup to be cached, got %d lookups", lookups)
+	}
+
+	channel, err := basware.DeliveryChannelFor(ctx, dir, basware.Endpoint{ID: "974760673", SchemeID: "0192"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if channel != basware.DeliveryChannelPrintingAllowed {
+		t.Errorf("expected %s for an unregistered receiver, got %s", basware.DeliveryChannelPrintingAllowed, channel)
+	}
+}
+
+func TestStaticDirectory(t *testing.T) {
+	dir := basware.StaticDirectory{
+		{SchemeID: "0088", ID: "7300010000001"}: {Registered: true, DocumentTypes: []string{"urn:oasis:names:specification:ubl:schema:xsd:Order-2::Order"}},
+	}
+
+	r, err := dir.Lookup(context.Background(), basware.ParticipantID{SchemeID: "0088", ID: "7300010000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Registered || r.CanReceiveInvoices() {
+		t.Errorf("expected a registered receiver of orders only, got %+v", r)
+	}
+}
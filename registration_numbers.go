@@ -0,0 +1,549 @@
+package basware
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// vatNumberFormats holds the syntax of the VAT numbers after the country
+// prefix
+var vatNumberFormats = map[string]*regexp.Regexp{
+	"AT": regexp.MustCompile(`^U[0-9]{8}$`),
+	"BE": regexp.MustCompile(`^[01][0-9]{9}$`),
+	"BG": regexp.MustCompile(`^[0-9]{9,10}$`),
+	"CY": regexp.MustCompile(`^[0-59][0-9]{7}[A-Z]$`),
+	"CZ": regexp.MustCompile(`^[0-9]{8,10}$`),
+	"DE": regexp.MustCompile(`^[1-9][0-9]{8}$`),
+	"DK": regexp.MustCompile(`^[1-9][0-9]{7}$`),
+	"EE": regexp.MustCompile(`^10[0-9]{7}$`),
+	"EL": regexp.MustCompile(`^[0-9]{9}$`),
+	"ES": regexp.MustCompile(`^[0-9A-Z][0-9]{7}[0-9A-Z]$`),
+	"FI": regexp.MustCompile(`^[0-9]{8}$`),
+	"FR": regexp.MustCompile(`^[0-9A-HJ-NP-Z]{2}[0-9]{9}$`),
+	"HR": regexp.MustCompile(`^[0-9]{11}$`),
+	"HU": regexp.MustCompile(`^[0-9]{8}$`),
+	"IE": regexp.MustCompile(`^([0-9]{7}[A-W][A-IW]?|[0-9][A-Z+*][0-9]{5}[A-W])$`),
+	"IT": regexp.MustCompile(`^[0-9]{11}$`),
+	"LT": regexp.MustCompile(`^([0-9]{9}|[0-9]{12})$`),
+	"LU": regexp.MustCompile(`^[0-9]{8}$`),
+	"LV": regexp.MustCompile(`^[0-9]{11}$`),
+	"MT": regexp.MustCompile(`^[1-9][0-9]{7}$`),
+	"NL": regexp.MustCompile(`^[0-9]{9}B[0-9]{2}$`),
+	"PL": regexp.MustCompile(`^[0-9]{10}$`),
+	"PT": regexp.MustCompile(`^[1-9][0-9]{8}$`),
+	"RO": regexp.MustCompile(`^[1-9][0-9]{1,9}$`),
+	"SE": regexp.MustCompile(`^[0-9]{10}01$`),
+	"SI": regexp.MustCompile(`^[1-9][0-9]{7}$`),
+	"SK": regexp.MustCompile(`^[1-9][0-9][2-47-9][0-9]{7}$`),
+	"NO": regexp.MustCompile(`^[89][0-9]{8}(MVA)?$`),
+	"XI": regexp.MustCompile(`^([0-9]{9}|[0-9]{12}|GD[0-4][0-9]{2}|HA[5-9][0-9]{2})$`),
+	"GB": regexp.MustCompile(`^([0-9]{9}|[0-9]{12}|GD[0-4][0-9]{2}|HA[5-9][0-9]{2})$`),
+}
+
+// vatNumberChecks holds the check digit algorithms of the VAT numbers. The
+// number is passed without country prefix and has a valid syntax.
+var vatNumberChecks = map[string]func(n string) bool{
+	"AT": func(n string) bool {
+		sum := 0
+		for i, d := range digits(n[1:8]) {
+			if i%2 == 1 {
+				d = d*2/10 + d*2%10
+			}
+			sum += d
+		}
+		return (10-(sum+4)%10)%10 == digit(n, 8)
+	},
+	"BE": func(n string) bool {
+		return 97-atoi(n[:8])%97 == atoi(n[8:])
+	},
+	"BG": func(n string) bool {
+		if len(n) == 9 {
+			check := weightedSum(n[:8], 1, 2, 3, 4, 5, 6, 7, 8) % 11
+			if check == 10 {
+				check = weightedSum(n[:8], 3, 4, 5, 6, 7, 8, 9, 10) % 11 % 10
+			}
+			return check == digit(n, 8)
+		}
+
+		// natural persons, foreigners and others each have their own weights
+		person := weightedSum(n[:9], 2, 4, 8, 5, 10, 9, 7, 3, 6) % 11 % 10
+		foreigner := weightedSum(n[:9], 21, 19, 17, 13, 11, 9, 7, 3, 1) % 10
+		other := (11 - weightedSum(n[:9], 4, 3, 2, 7, 6, 5, 4, 3, 2)%11) % 11
+		check := digit(n, 9)
+		return check == person || check == foreigner || (other != 10 && check == other)
+	},
+	"CY": func(n string) bool {
+		odd := []int{1, 0, 5, 7, 9, 13, 15, 17, 19, 21}
+		sum := 0
+		for i, d := range digits(n[:8]) {
+			if i%2 == 0 {
+				d = odd[d]
+			}
+			sum += d
+		}
+		return n[8] == byte('A'+sum%26) && n[:2] != "12"
+	},
+	"CZ": func(n string) bool {
+		switch len(n) {
+		case 8:
+			if n[0] == '9' {
+				return false
+			}
+			check := (11 - weightedSum(n[:7], 8, 7, 6, 5, 4, 3, 2)%11) % 11
+			if check == 0 {
+				check = 1
+			}
+			return check%10 == digit(n, 7)
+		case 10:
+			// birth numbers of people born after 1953 are divisible by 11
+			return atoi(n)%11 == 0
+		}
+		return true
+	},
+	"DE": func(n string) bool {
+		return isValidMod1110(n)
+	},
+	"DK": func(n string) bool {
+		return weightedSum(n, 2, 7, 6, 5, 4, 3, 2, 1)%11 == 0
+	},
+	"EE": func(n string) bool {
+		return weightedSum(n, 3, 7, 1, 3, 7, 1, 3, 7, 1)%10 == 0
+	},
+	"EL": func(n string) bool {
+		return weightedSum(n[:8], 256, 128, 64, 32, 16, 8, 4, 2)%11%10 == digit(n, 8)
+	},
+	"ES": func(n string) bool {
+		return isValidSpanishNIF(n)
+	},
+	"FI": func(n string) bool {
+		return isValidYTunnus(n)
+	},
+	"FR": func(n string) bool {
+		siren := n[2:]
+		if !isValidLuhn(siren) {
+			return false
+		}
+		// the key of older numbers is derived from the SIREN
+		if key, err := strconv.Atoi(n[:2]); err == nil {
+			return key == (12+3*(atoi(siren)%97))%97
+		}
+		return true
+	},
+	"HR": func(n string) bool {
+		return isValidMod1110(n)
+	},
+	"HU": func(n string) bool {
+		return weightedSum(n, 9, 7, 3, 1, 9, 7, 3, 1)%10 == 0
+	},
+	"IE": func(n string) bool {
+		// old style numbers move the second character to the end
+		if n[1] < '0' || n[1] > '9' {
+			n = "0" + n[2:7] + n[0:1] + n[7:8]
+		}
+		sum := weightedSum(n[:7], 8, 7, 6, 5, 4, 3, 2)
+		if len(n) == 9 && n[8] != 'W' {
+			sum += int(n[8]-'A'+1) * 9
+		}
+		return n[7] == "WABCDEFGHIJKLMNOPQRSTUV"[sum%23]
+	},
+	"IT": func(n string) bool {
+		office := atoi(n[7:10])
+		return n[:7] != "0000000" && (office <= 100 || office == 120 || office == 121 || office == 888 || office == 999) && isValidLuhn(n)
+	},
+	"LT": func(n string) bool {
+		if n[len(n)-2] != '1' {
+			return false
+		}
+		body := n[:len(n)-1]
+		sum := 0
+		for i, d := range digits(body) {
+			sum += d * (1 + i%9)
+		}
+		check := sum % 11
+		if check == 10 {
+			sum = 0
+			for i, d := range digits(body) {
+				sum += d * (1 + (i+2)%9)
+			}
+			check = sum % 11 % 10
+		}
+		return check == digit(n, len(n)-1)
+	},
+	"LU": func(n string) bool {
+		return atoi(n[:6])%89 == atoi(n[6:])
+	},
+	"LV": func(n string) bool {
+		if n[0] > '3' {
+			return weightedSum(n, 9, 1, 4, 8, 3, 10, 2, 5, 7, 6, 1)%11 == 3
+		}
+		// personal codes starting with 32 have no check digit
+		if n[:2] == "32" {
+			return true
+		}
+		return (1+weightedSum(n[:10], 10, 5, 8, 4, 2, 1, 6, 3, 7, 9))%11%10 == digit(n, 10)
+	},
+	"MT": func(n string) bool {
+		return weightedSum(n, 3, 4, 6, 7, 8, 9, 10, 1)%37 == 0
+	},
+	"NL": func(n string) bool {
+		if weightedSum(n[:9], 9, 8, 7, 6, 5, 4, 3, 2, -1)%11 == 0 {
+			return true
+		}
+		// numbers issued to sole proprietors since 2020
+		return isValidMod9710("NL" + n)
+	},
+	"PL": func(n string) bool {
+		return weightedSum(n[:9], 6, 5, 7, 2, 3, 4, 5, 6, 7)%11 == digit(n, 9)
+	},
+	"PT": func(n string) bool {
+		return (11-weightedSum(n[:8], 9, 8, 7, 6, 5, 4, 3, 2)%11)%11%10 == digit(n, 8)
+	},
+	"RO": func(n string) bool {
+		body := fmt.Sprintf("%09s", n[:len(n)-1])
+		return 10*weightedSum(body, 7, 5, 3, 2, 1, 7, 5, 3, 2)%11%10 == digit(n, len(n)-1)
+	},
+	"SE": func(n string) bool {
+		return isValidLuhn(n[:10])
+	},
+	"SI": func(n string) bool {
+		check := 11 - weightedSum(n[:7], 8, 7, 6, 5, 4, 3, 2)%11
+		if check == 10 {
+			check = 0
+		}
+		return check != 11 && check == digit(n, 7)
+	},
+	"SK": func(n string) bool {
+		return atoi(n)%11 == 0
+	},
+	"NO": func(n string) bool {
+		return isValidMod11(n[:9], []int{3, 2, 7, 6, 5, 4, 3, 2})
+	},
+	"XI": isValidUKVATNumber,
+	"GB": isValidUKVATNumber,
+}
+
+// ValidateVATNumber checks the syntax and check digits of a VAT number with
+// its country prefix, e.g. FI12345671. It knows the VAT numbers of the EU
+// member states (with EL for Greece), Norway and the United Kingdom (GB, XI).
+func ValidateVATNumber(number string) error {
+	n := normalizeRegistrationNumber(number)
+	if len(n) < 3 {
+		return fmt.Errorf("Expected a VAT number with country prefix, got \"%s\"", number)
+	}
+
+	country := n[:2]
+	if country == "GR" {
+		country = "EL"
+	}
+	format, ok := vatNumberFormats[country]
+	if !ok {
+		return fmt.Errorf("Unknown VAT number country prefix \"%s\"", n[:2])
+	}
+	if !format.MatchString(n[2:]) || !vatNumberChecks[country](n[2:]) {
+		return fmt.Errorf("Invalid %s VAT number \"%s\"", country, number)
+	}
+	return nil
+}
+
+// companyRegistrationChecks validates the national company registration
+// numbers per country
+var companyRegistrationChecks = map[string]struct {
+	name   string
+	format *regexp.Regexp
+	check  func(n string) bool
+}{
+	"BE": {"KBO/BCE enterprise number", regexp.MustCompile(`^[01][0-9]{9}$`), vatNumberChecks["BE"]},
+	"DK": {"CVR number", regexp.MustCompile(`^[1-9][0-9]{7}$`), vatNumberChecks["DK"]},
+	"FI": {"Y-tunnus", regexp.MustCompile(`^[0-9]{8}$`), isValidYTunnus},
+	"NL": {"KvK number", regexp.MustCompile(`^[0-9]{8}$`), func(string) bool { return true }},
+	"NO": {"organisation number", regexp.MustCompile(`^[89][0-9]{8}$`), func(n string) bool {
+		return isValidMod11(n, []int{3, 2, 7, 6, 5, 4, 3, 2})
+	}},
+}
+
+// ValidateCompanyRegistrationNumber checks a national company registration
+// number of a country: BE KBO/BCE, DK CVR, FI Y-tunnus, NL KvK or NO
+// organisation number. Numbers of other countries are not checked.
+func ValidateCompanyRegistrationNumber(number string, countryID string) error {
+	countryID = strings.ToUpper(countryID)
+	c, ok := companyRegistrationChecks[countryID]
+	if !ok {
+		return nil
+	}
+
+	n := normalizeRegistrationNumber(number)
+	// Y-tunnus 1234567-8 may have lost its leading zero
+	if countryID == "FI" && len(n) == 7 {
+		n = "0" + n
+	}
+	if !c.format.MatchString(n) || !c.check(n) {
+		return fmt.Errorf("Invalid %s %s \"%s\"", countryID, c.name, number)
+	}
+	return nil
+}
+
+// ValidatePartyID checks a party identifier with its scheme: VAT numbers
+// (VAT, VA) and the schemes of the identifier scheme registry by their code
+// (e.g. 0192), name or alias (e.g. DK:CVR). Identifiers of other schemes are
+// not checked.
+func ValidatePartyID(id string, schemeID string) error {
+	scheme := strings.ToUpper(schemeID)
+	if scheme == TaxSchemeVAT || scheme == "VA" {
+		return ValidateVATNumber(id)
+	}
+	if s, ok := LookupIdentifierScheme(scheme); ok {
+		return s.Validate(id)
+	}
+	return nil
+}
+
+// IdentifierRuleSet checks the syntax and check digits of the VAT numbers and
+// company registrations and endpoints of the parties and of the payment
+// accounts, reference and virtual bank barcodes
+var IdentifierRuleSet = RuleSet{
+	Name: "Identifiers",
+	Rules: []Rule{
+		{
+			ID:       "ID-01",
+			Severity: RuleSeverityFatal,
+			Message:  "A VAT identifier shall be a valid VAT number of its country",
+			Check: eachParty(func(path string, p party) []string {
+				paths := []string{}
+				schemes := append([]PartyTaxScheme{p.PartyTaxScheme}, p.AdditionalPartyTaxSchemes...)
+				for i, ts := range schemes {
+					if !isVATIdentifier(ts.Company) || ValidateVATNumber(ts.Company.ID) == nil {
+						continue
+					}
+					if i == 0 {
+						paths = append(paths, path+".partyTaxScheme.company.id")
+					} else {
+						paths = append(paths, fmt.Sprintf("%s.additionalPartyTaxSchemes[%d].company.id", path, i-1))
+					}
+				}
+				return paths
+			}),
+		},
+		{
+			ID:       "ID-02",
+			Severity: RuleSeverityFatal,
+			Message:  "A company registration number shall be valid for its scheme",
+			Check: eachParty(func(path string, p party) []string {
+				paths := []string{}
+				for i, id := range p.PartyIdentification {
+					if ValidatePartyID(id.ID, id.SchemeID) != nil {
+						paths = append(paths, fmt.Sprintf("%s.partyIdentification[%d].id", path, i))
+					}
+				}
+				for i, le := range p.PartyLegalEntities {
+					if ValidatePartyID(le.CompanyID, le.SchemeID) != nil {
+						paths = append(paths, fmt.Sprintf("%s.partyLegalEntities[%d].companyId", path, i))
+					}
+				}
+				return paths
+			}),
+		},
+		{
+			ID:       "ID-03",
+			Severity: RuleSeverityFatal,
+			Message:  "A payment account shall have a valid IBAN, BIC and branch identifier",
+			Check: func(inv Invoice) []string {
+				paths := []string{}
+				for i, fa := range inv.PaymentMeans.FinancialAccount {
+					for _, f := range fa.invalidFields() {
+						paths = append(paths, fmt.Sprintf("paymentMeans.financialAccount[%d].%s", i, f.path))
+					}
+				}
+				return paths
+			},
+		},
+		{
+			ID:       "ID-04",
+			Severity: RuleSeverityFatal,
+			Message:  "A payment reference shall be valid for its scheme",
+			Check: func(inv Invoice) []string {
+				if inv.PaymentMeans.PaymentIdentifier.Validate() != nil {
+					return []string{"paymentMeans.paymentIdentifier.id"}
+				}
+				return nil
+			},
+		},
+		{
+			ID:       "ID-05",
+			Severity: RuleSeverityFatal,
+			Message:  "A virtual bank barcode shall match the account, payable amount, payment reference and due date",
+			Check: func(inv Invoice) []string {
+				paths := []string{}
+				for i, fa := range inv.PaymentMeans.FinancialAccount {
+					if checkVirtualBankBarcode(inv, fa) != nil {
+						paths = append(paths, fmt.Sprintf("paymentMeans.financialAccount[%d].accounting.virtualBankBarcode.id", i))
+					}
+				}
+				return paths
+			},
+		},
+		{
+			ID:       "ID-06",
+			Severity: RuleSeverityFatal,
+			Message:  "An endpoint shall be valid for its scheme",
+			Check: eachParty(func(path string, p party) []string {
+				if p.Endpoint.ID == "" {
+					return nil
+				}
+				// endpoints of unknown schemes are up to the receiver
+				if _, err := p.Endpoint.ParticipantID(); err != nil {
+					return nil
+				}
+				if p.Endpoint.Validate() != nil {
+					return []string{path + ".endpoint.id"}
+				}
+				return nil
+			}),
+		},
+	},
+}
+
+// isVATIdentifier reports whether a tax scheme holds a VAT number of a
+// country ValidateVATNumber knows
+func isVATIdentifier(c PartyTaxSchemeCompany) bool {
+	switch strings.ToUpper(c.SchemeID) {
+	case "", TaxSchemeVAT, "VA":
+		n := normalizeRegistrationNumber(c.ID)
+		if len(n) < 2 {
+			return false
+		}
+		if n[:2] == "GR" {
+			return true
+		}
+		_, ok := vatNumberFormats[n[:2]]
+		return ok
+	}
+	return false
+}
+
+// eachParty applies a check to the supplier, customer and delivery party
+func eachParty(check func(path string, p party) []string) func(inv Invoice) []string {
+	return func(inv Invoice) []string {
+		paths := check("accountingSupplierParty", inv.AccountingSupplierParty.party())
+		paths = append(paths, check("accountingCustomerParty", inv.AccountingCustomerParty.party())...)
+		return append(paths, check("deliveryParty", inv.DeliveryParty.party())...)
+	}
+}
+
+// normalizeRegistrationNumber removes the separators and spaces commonly used
+// when printing registration numbers
+func normalizeRegistrationNumber(s string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", ".", "", "-", "", "/", "").Replace(s))
+}
+
+func isValidYTunnus(n string) bool {
+	sum := weightedSum(n[:7], 7, 9, 10, 5, 8, 4, 2) % 11
+	switch sum {
+	case 0:
+		return digit(n, 7) == 0
+	case 1:
+		return false
+	}
+	return digit(n, 7) == 11-sum
+}
+
+// isValidSpanishNIF checks the NIF of companies (CIF), residents (DNI) and
+// foreigners (NIE)
+func isValidSpanishNIF(n string) bool {
+	const letters = "TRWAGMYFPDXBNJZSQVHLCKE"
+	switch {
+	case n[0] >= '0' && n[0] <= '9':
+		return n[8] == letters[atoi(n[:8])%23]
+	case strings.IndexByte("XYZ", n[0]) >= 0:
+		return n[8] == letters[atoi(strconv.Itoa(strings.IndexByte("XYZ", n[0]))+n[1:8])%23]
+	case strings.IndexByte("KLM", n[0]) >= 0:
+		return n[8] == letters[atoi(n[1:8])%23]
+	}
+
+	sum := 0
+	for i, d := range digits(n[1:8]) {
+		if i%2 == 0 {
+			d = d*2/10 + d*2%10
+		}
+		sum += d
+	}
+	check := (10 - sum%10) % 10
+	return n[8] == byte('0'+check) || n[8] == "JABCDEFGHI"[check]
+}
+
+func isValidUKVATNumber(n string) bool {
+	if n[0] == 'G' || n[0] == 'H' {
+		return true
+	}
+	sum := weightedSum(n[:9], 8, 7, 6, 5, 4, 3, 2, 10, 1)
+	return sum%97 == 0 || (sum+55)%97 == 0
+}
+
+// isValidLuhn checks the Luhn check digit of a number
+func isValidLuhn(n string) bool {
+	sum := 0
+	for i, d := range digits(n) {
+		if (len(n)-i)%2 == 0 {
+			d = d*2/10 + d*2%10
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// isValidMod1110 checks an ISO 7064 MOD 11,10 check digit
+func isValidMod1110(n string) bool {
+	product := 10
+	for _, d := range digits(n[:len(n)-1]) {
+		sum := (d + product) % 10
+		if sum == 0 {
+			sum = 10
+		}
+		product = 2 * sum % 11
+	}
+	return (11-product)%10 == digit(n, len(n)-1)
+}
+
+// isValidMod9710 checks ISO 7064 MOD 97-10 check digits, converting letters to
+// two digits like IBAN
+func isValidMod9710(s string) bool {
+	remainder := 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			remainder = (remainder*10 + int(r-'0')) % 97
+		case r >= 'A' && r <= 'Z':
+			remainder = (remainder*100 + int(r-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+	return remainder == 1
+}
+
+func weightedSum(n string, weights ...int) int {
+	sum := 0
+	for i, d := range digits(n) {
+		sum += d * weights[i]
+	}
+	return sum
+}
+
+// digits returns the values of the digits of a numeric string
+func digits(n string) []int {
+	ds := make([]int, len(n))
+	for i := range n {
+		ds[i] = int(n[i] - '0')
+	}
+	return ds
+}
+
+func digit(n string, i int) int {
+	return int(n[i] - '0')
+}
+
+func atoi(n string) int {
+	i, _ := strconv.Atoi(n)
+	return i
+}
@@ -0,0 +1,79 @@
+package basware_test
+
+import (
+	"strings"
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+func TestValidateVATNumber(t *testing.T) {
+	valid := []string{
+		"ATU13585627", "BE0403019261", "BG175074752", "BG7523169263",
+		"CY10259033P", "CZ25123891", "CZ7103192745", "DE136695976",
+		"DK13585628", "EE100931558", "EL094259216", "ESA13585625",
+		"ES54362315K", "ESX2482300W", "FI20774740", "FR40303265045",
+		"FRK7399859412", "HR33392005961", "HU12892312", "IE6433435F",
+		"IE6433435OA", "IE8D79739I", "IT00743110157", "LT119511515",
+		"LT100001919017", "LU15027442", "LV40003521600", "LV16117519997",
+		"MT11679112", "NL004495445B01", "NL000099998B57", "PL8567346215",
+		"PT501964843", "RO18547290", "SE123456789701", "SI50223054",
+		"SK2022749619", "GB980780684", "NO923609016MVA", "FI 2077 4740",
+	}
+	for _, n := range valid {
+		if err := basware.ValidateVATNumber(n); err != nil {
+			t.Error(err)
+		}
+	}
+
+	invalid := []string{"DE136695978", "FI20774741", "NL004495446B01", "SE123456789801", "US123456789", "FI"}
+	for _, n := range invalid {
+		if basware.ValidateVATNumber(n) == nil {
+			t.Errorf("expected %s to be invalid", n)
+		}
+	}
+}
+
+func TestValidatePartyID(t *testing.T) {
+	tests := []struct {
+		id       string
+		schemeID string
+		valid    bool
+	}{
+		{"2077474-0", "0212", true},
+		{"2077474-1", "FI:YT", false},
+		{"13585628", "DK:CVR", true},
+		{"13585627", "0184", false},
+		{"974760673", "0192", true},
+		{"974760674", "NO:ORGNR", false},
+		{"0403.019.261", "0208", true},
+		{"12345678", "0106", true},
+		{"1234567", "NL:KVK", false},
+		{"DE136695976", "VAT", true},
+		{"7300010000001", "0088", true},
+	}
+	for _, test := range tests {
+		if err := basware.ValidatePartyID(test.id, test.schemeID); (err == nil) != test.valid {
+			t.Errorf("%s %s: expected valid to be %v, got %v", test.schemeID, test.id, test.valid, err)
+		}
+	}
+
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv := doc.Invoice
+	inv.AccountingSupplierParty.PartyTaxScheme.Company.ID = "FI12345672"
+	inv.AccountingCustomerParty.PartyIdentification = []basware.PartyIdentificationItem{{ID: "974760674", SchemeID: "0192"}}
+
+	paths := []string{}
+	for _, v := range basware.ValidateInvoice(inv) {
+		if strings.HasPrefix(v.RuleID, "ID-") {
+			paths = append(paths, v.Path)
+		}
+	}
+	expected := "accountingSupplierParty.partyTaxScheme.company.id,accountingCustomerParty.partyIdentification[0].id"
+	if strings.Join(paths, ",") != expected {
+		t.Errorf("expected violations of %s, got %v", expected, paths)
+	}
+}
@@ -0,0 +1,747 @@
+package basware
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RenderOptions controls the layout of a rendered invoice image
+type RenderOptions struct {
+	// Language of the labels and number formats: en, fi, sv, de or nl.
+	// Defaults to en.
+	Language string
+
+	// Replaces single labels, keyed by the label names used in renderLabels
+	// (e.g. "payable").
+	Labels map[string]string
+
+	Branding Branding
+}
+
+// Branding of the rendered invoice image
+type Branding struct {
+	// Logo shown in the top left corner. The supplier name is shown when no
+	// logo is set or the logo is empty.
+	Logo image.Image
+
+	// Color of the title, table header and separators. Defaults to a dark
+	// blue.
+	AccentColor color.Color
+
+	// Text shown at the bottom of every page, e.g. the registered office and
+	// company registration number
+	FooterText string
+}
+
+// renderLabels contains the texts of the invoice image per language
+var renderLabels = map[string]map[string]string{
+	"en": {
+		"invoice": "Invoice", "creditNote": "Credit note", "supplier": "Seller", "customer": "Buyer",
+		"deliverTo": "Deliver to", "invoiceNumber": "Invoice number", "issueDate": "Issue date",
+		"dueDate": "Due date", "buyerReference": "Your reference", "orderReference": "Order number",
+		"paymentReference": "Payment reference", "currency": "Currency", "deliveryDate": "Delivery date",
+		"line": "#", "description": "Description", "quantity": "Quantity", "unit": "Unit",
+		"unitPrice": "Unit price", "vatPercent": "VAT %", "amount": "Amount",
+		"lineTotal": "Total excl. VAT", "freight": "Freight", "handling": "Handling", "vat": "VAT",
+		"totalVat": "Total VAT", "payable": "Amount due", "payment": "Payment details",
+		"iban": "IBAN", "bic": "BIC", "account": "Account", "notes": "Notes",
+		"paymentTerms": "Payment terms", "page": "Page", "of": "of", "vatNumber": "VAT number",
+	},
+	"fi": {
+		"invoice": "Lasku", "creditNote": "Hyvityslasku", "supplier": "Myyjä", "customer": "Ostaja",
+		"deliverTo": "Toimitusosoite", "invoiceNumber": "Laskun numero", "issueDate": "Laskun päiväys",
+		"dueDate": "Eräpäivä", "buyerReference": "Viitteenne", "orderReference": "Tilausnumero",
+		"paymentReference": "Viitenumero", "currency": "Valuutta", "deliveryDate": "Toimituspäivä",
+		"line": "#", "description": "Kuvaus", "quantity": "Määrä", "unit": "Yksikkö",
+		"unitPrice": "Yksikköhinta", "vatPercent": "ALV %", "amount": "Summa",
+		"lineTotal": "Veroton yhteensä", "freight": "Rahti", "handling": "Käsittely", "vat": "ALV",
+		"totalVat": "ALV yhteensä", "payable": "Maksettava", "payment": "Maksutiedot",
+		"iban": "IBAN", "bic": "BIC", "account": "Tili", "notes": "Lisätiedot",
+		"paymentTerms": "Maksuehdot", "page": "Sivu", "of": "/", "vatNumber": "ALV-numero",
+	},
+	"sv": {
+		"invoice": "Faktura", "creditNote": "Kreditnota", "supplier": "Säljare", "customer": "Köpare",
+		"deliverTo": "Leveransadress", "invoiceNumber": "Fakturanummer", "issueDate": "Fakturadatum",
+		"dueDate": "Förfallodatum", "buyerReference": "Er referens", "orderReference": "Ordernummer",
+		"paymentReference": "Betalningsreferens", "currency": "Valuta", "deliveryDate": "Leveransdatum",
+		"line": "#", "description": "Beskrivning", "quantity": "Antal", "unit": "Enhet",
+		"unitPrice": "À-pris", "vatPercent": "Moms %", "amount": "Belopp",
+		"lineTotal": "Summa exkl. moms", "freight": "Frakt", "handling": "Hantering", "vat": "Moms",
+		"totalVat": "Summa moms", "payable": "Att betala", "payment": "Betalningsuppgifter",
+		"iban": "IBAN", "bic": "BIC", "account": "Konto", "notes": "Meddelande",
+		"paymentTerms": "Betalningsvillkor", "page": "Sida", "of": "av", "vatNumber": "Momsreg.nr",
+	},
+	"de": {
+		"invoice": "Rechnung", "creditNote": "Gutschrift", "supplier": "Verkäufer", "customer": "Käufer",
+		"deliverTo": "Lieferanschrift", "invoiceNumber": "Rechnungsnummer", "issueDate": "Rechnungsdatum",
+		"dueDate": "Fällig am", "buyerReference": "Ihre Referenz", "orderReference": "Bestellnummer",
+		"paymentReference": "Verwendungszweck", "currency": "Währung", "deliveryDate": "Lieferdatum",
+		"line": "#", "description": "Beschreibung", "quantity": "Menge", "unit": "Einheit",
+		"unitPrice": "Einzelpreis", "vatPercent": "USt. %", "amount": "Betrag",
+		"lineTotal": "Summe netto", "freight": "Fracht", "handling": "Bearbeitung", "vat": "USt.",
+		"totalVat": "Summe USt.", "payable": "Zahlbetrag", "payment": "Zahlungsinformationen",
+		"iban": "IBAN", "bic": "BIC", "account": "Konto", "notes": "Bemerkungen",
+		"paymentTerms": "Zahlungsbedingungen", "page": "Seite", "of": "von", "vatNumber": "USt-IdNr.",
+	},
+	"nl": {
+		"invoice": "Factuur", "creditNote": "Creditnota", "supplier": "Verkoper", "customer": "Koper",
+		"deliverTo": "Afleveradres", "invoiceNumber": "Factuurnummer", "issueDate": "Factuurdatum",
+		"dueDate": "Vervaldatum", "buyerReference": "Uw referentie", "orderReference": "Ordernummer",
+		"paymentReference": "Betalingskenmerk", "currency": "Valuta", "deliveryDate": "Leverdatum",
+		"line": "#", "description": "Omschrijving", "quantity": "Aantal", "unit": "Eenheid",
+		"unitPrice": "Prijs", "vatPercent": "Btw %", "amount": "Bedrag",
+		"lineTotal": "Totaal excl. btw", "freight": "Vracht", "handling": "Behandeling", "vat": "Btw",
+		"totalVat": "Totaal btw", "payable": "Te betalen", "payment": "Betaalgegevens",
+		"iban": "IBAN", "bic": "BIC", "account": "Rekening", "notes": "Opmerkingen",
+		"paymentTerms": "Betalingsvoorwaarden", "page": "Pagina", "of": "van", "vatNumber": "Btw-nummer",
+	},
+}
+
+// renderNumberFormats holds the decimal and grouping separators per language
+var renderNumberFormats = map[string][2]string{
+	"en": {".", ","},
+	"fi": {",", " "},
+	"sv": {",", " "},
+	"de": {",", "."},
+	"nl": {",", "."},
+}
+
+// renderDateFormats holds the order and separator of day, month and year per
+// language
+var renderDateFormats = map[string]string{
+	"en": "2006-01-02",
+	"fi": "2.1.2006",
+	"sv": "2006-01-02",
+	"de": "02.01.2006",
+	"nl": "02-01-2006",
+}
+
+// A4 in points
+const (
+	renderPageWidth    = 595.28
+	renderPageHeight   = 841.89
+	renderMarginLeft   = 50
+	renderMarginRight  = 545
+	renderMarginTop    = 790
+	renderMarginBottom = 80
+)
+
+// RenderPDF lays out the invoice as a paginated PDF document that can be used
+// as the invoice image, for example when the DeliveryChannelPreference is
+// printing-always. The document uses the standard Helvetica font; it isn't
+// embedded so the result isn't PDF/A conformant on its own.
+func RenderPDF(inv Invoice, docType DocumentType, options RenderOptions) ([]byte, error) {
+	r := newInvoiceRenderer(inv, docType, options)
+	r.render()
+	return r.document()
+}
+
+type invoiceRenderer struct {
+	inv     Invoice
+	docType DocumentType
+	options RenderOptions
+	labels  map[string]string
+	accent  color.Color
+
+	pages []*pdfCanvas
+	page  *pdfCanvas
+	y     float64
+}
+
+func newInvoiceRenderer(inv Invoice, docType DocumentType, options RenderOptions) *invoiceRenderer {
+	if _, ok := renderLabels[options.Language]; !ok {
+		options.Language = "en"
+	}
+
+	labels := map[string]string{}
+	for k, v := range renderLabels[options.Language] {
+		labels[k] = v
+	}
+	for k, v := range options.Labels {
+		labels[k] = v
+	}
+
+	// an empty logo can't be scaled
+	if logo := options.Branding.Logo; logo != nil && logo.Bounds().Empty() {
+		options.Branding.Logo = nil
+	}
+
+	accent := options.Branding.AccentColor
+	if accent == nil {
+		accent = color.RGBA{R: 0x1f, G: 0x3a, B: 0x68, A: 0xff}
+	}
+
+	return &invoiceRenderer{
+		inv:     inv,
+		docType: docType,
+		options: options,
+		labels:  labels,
+		accent:  accent,
+	}
+}
+
+func (r *invoiceRenderer) newPage() {
+	r.page = &pdfCanvas{}
+	r.pages = append(r.pages, r.page)
+	r.y = renderMarginTop
+}
+
+// ensure starts a new page when less than height points are left
+func (r *invoiceRenderer) ensure(height float64) bool {
+	if r.y-height >= renderMarginBottom {
+		return false
+	}
+	r.newPage()
+	return true
+}
+
+func (r *invoiceRenderer) render() {
+	r.newPage()
+	r.header()
+	r.parties()
+	r.details()
+	r.lines()
+	r.totals()
+	r.payment()
+	r.notes()
+}
+
+func (r *invoiceRenderer) header() {
+	top := r.y
+	supplier := r.inv.AccountingSupplierParty
+	if r.options.Branding.Logo != nil {
+		b := r.options.Branding.Logo.Bounds()
+		h := 50.0
+		w := h * float64(b.Dx()) / float64(b.Dy())
+		if w > 200 {
+			w = 200
+			h = w * float64(b.Dy()) / float64(b.Dx())
+		}
+		r.page.image("Logo", renderMarginLeft, top-h, w, h)
+	} else {
+		r.page.text(renderMarginLeft, top-16, supplier.PartyName, true, 16, color.Black)
+	}
+
+	title := r.labels["invoice"]
+	if r.docType == DocumentTypeCreditNote {
+		title = r.labels["creditNote"]
+	}
+	r.page.textRight(renderMarginRight, top-20, strings.ToUpper(title), true, 20, r.accent)
+
+	r.y = top - 70
+	r.page.line(renderMarginLeft, r.y, renderMarginRight, r.y, 1, r.accent)
+	r.y -= 20
+}
+
+func (r *invoiceRenderer) partyLines(p party) []string {
+	a := p.PostalAddress
+	lines := []string{p.name()}
+	lines = append(lines, FormatPostalAddress(a, r.inv.AccountingSupplierParty.PostalAddress.CountryID)...)
+	vatID := p.vatIdentifier()
+	if vatID != "" {
+		lines = append(lines, r.labels["vatNumber"]+": "+vatID)
+	}
+	// other tax and company registrations are shown with their scheme
+	for _, ts := range p.taxSchemes() {
+		if ts.Company.ID != vatID {
+			lines = append(lines, strings.TrimPrefix(ts.Company.SchemeID+": ", ": ")+ts.Company.ID)
+		}
+	}
+	for _, le := range p.PartyLegalEntities {
+		if le.CompanyID != "" {
+			lines = append(lines, strings.TrimPrefix(le.SchemeID+": ", ": ")+le.CompanyID)
+		}
+	}
+	lines = append(lines, splitNonEmpty(p.Contact.ElectronicMail)...)
+	return lines
+}
+
+func (r *invoiceRenderer) partyBlock(x float64, y float64, label string, p party) float64 {
+	r.page.text(x, y, label, true, 8, r.accent)
+	y -= 13
+	for i, l := range r.partyLines(p) {
+		r.page.text(x, y, l, i == 0, 10, color.Black)
+		y -= 13
+	}
+	return y
+}
+
+func (r *invoiceRenderer) parties() {
+	y1 := r.partyBlock(renderMarginLeft, r.y, r.labels["supplier"], r.inv.AccountingSupplierParty.party())
+	y2 := r.partyBlock(300, r.y, r.labels["customer"], r.inv.AccountingCustomerParty.party())
+	r.y = minFloat(y1, y2)
+
+	if delivery := r.inv.DeliveryParty.party(); !delivery.isZero() {
+		r.y -= 7
+		r.y = r.partyBlock(300, r.y, r.labels["deliverTo"], delivery)
+	}
+	r.y -= 10
+}
+
+func (r *invoiceRenderer) details() {
+	pm := r.inv.PaymentMeans
+	details := [][2]string{
+		{r.labels["invoiceNumber"], r.inv.ID},
+		{r.labels["issueDate"], r.date(r.inv.IssueDate)},
+		{r.labels["dueDate"], r.date(pm.PaymentDueDate)},
+		{r.labels["deliveryDate"], r.date(r.inv.Delivery.ActualDeliveryDate)},
+		{r.labels["buyerReference"], r.inv.BuyerReference.ID},
+		{r.labels["orderReference"], r.inv.OrderReference.ID},
+		{r.labels["paymentReference"], pm.PaymentIdentifier.ID},
+		{r.labels["currency"], r.inv.DocumentCurrencyCode},
+	}
+
+	for _, d := range details {
+		if d[1] == "" {
+			continue
+		}
+		r.page.text(renderMarginLeft, r.y, d[0], false, 9, color.Gray{Y: 0x55})
+		r.page.text(renderMarginLeft+110, r.y, d[1], true, 9, color.Black)
+		r.y -= 13
+	}
+	r.y -= 15
+}
+
+// line table columns: left edge of left aligned and right edge of right
+// aligned columns
+const (
+	renderColumnLine        = renderMarginLeft
+	renderColumnDescription = renderMarginLeft + 22
+	renderDescriptionWidth  = 210
+	renderColumnQuantity    = 335
+	renderColumnUnit        = 341
+	renderColumnUnitPrice   = 430
+	renderColumnVAT         = 475
+	renderColumnAmount      = renderMarginRight
+)
+
+func (r *invoiceRenderer) tableHeader() {
+	r.page.fillRect(renderMarginLeft, r.y-5, renderMarginRight-renderMarginLeft, 17, r.accent)
+	white := color.White
+	r.page.text(renderColumnLine+3, r.y, r.labels["line"], true, 8, white)
+	r.page.text(renderColumnDescription, r.y, r.labels["description"], true, 8, white)
+	r.page.textRight(renderColumnQuantity, r.y, r.labels["quantity"], true, 8, white)
+	r.page.text(renderColumnUnit, r.y, r.labels["unit"], true, 8, white)
+	r.page.textRight(renderColumnUnitPrice, r.y, r.labels["unitPrice"], true, 8, white)
+	r.page.textRight(renderColumnVAT, r.y, r.labels["vatPercent"], true, 8, white)
+	r.page.textRight(renderColumnAmount-3, r.y, r.labels["amount"], true, 8, white)
+	r.y -= 20
+}
+
+func (r *invoiceRenderer) lines() {
+	r.ensure(60)
+	r.tableHeader()
+
+	for _, l := range r.inv.InvoiceLine {
+		description := l.Item.Name
+		for _, d := range l.Item.Description {
+			description = strings.TrimSpace(description + "\n" + string(d))
+		}
+		wrapped := []string{}
+		for _, d := range strings.Split(description, "\n") {
+			wrapped = append(wrapped, wrapText(d, renderDescriptionWidth, false, 9)...)
+		}
+
+		height := float64(len(wrapped))*11 + 4
+		if r.ensure(height) {
+			r.tableHeader()
+		}
+
+		r.page.text(renderColumnLine+3, r.y, l.ID, false, 9, color.Black)
+		for i, d := range wrapped {
+			r.page.text(renderColumnDescription, r.y-float64(i)*11, d, false, 9, color.Black)
+		}
+		r.page.textRight(renderColumnQuantity, r.y, r.number(l.Quantity.Amount, 0), false, 9, color.Black)
+		r.page.text(renderColumnUnit, r.y, l.Quantity.UnitCode, false, 9, color.Black)
+		r.page.textRight(renderColumnUnitPrice, r.y, r.number(l.Price.Amount, 2), false, 9, color.Black)
+		r.page.textRight(renderColumnVAT, r.y, r.number(l.Item.TaxPercent, 0), false, 9, color.Black)
+		r.page.textRight(renderColumnAmount-3, r.y, r.number(l.LineExtension.Amount, 2), false, 9, color.Black)
+
+		r.y -= height
+		r.page.line(renderMarginLeft, r.y+7, renderMarginRight, r.y+7, 0.3, color.Gray{Y: 0xcc})
+	}
+	r.y -= 10
+}
+
+func (r *invoiceRenderer) totals() {
+	rows := [][2]string{
+		{r.labels["lineTotal"], r.number(r.inv.LegalMonetaryTotal.LineExtensionAmount.Amount, 2)},
+	}
+	if r.inv.AllowanceCharge.Freight != 0 {
+		rows = append(rows, [2]string{r.labels["freight"], r.number(r.inv.AllowanceCharge.Freight, 2)})
+	}
+	if r.inv.AllowanceCharge.Handling != 0 {
+		rows = append(rows, [2]string{r.labels["handling"], r.number(r.inv.AllowanceCharge.Handling, 2)})
+	}
+	for _, sub := range r.inv.TaxTotal.TaxSubTotal {
+		label := fmt.Sprintf("%s %s %% (%s)", r.labels["vat"], r.number(sub.Percent, 0), r.number(sub.TaxableAmount, 2))
+		if category := sub.taxCategory(); category != VATCategoryStandard {
+			label = category + " " + label
+		}
+		rows = append(rows, [2]string{label, r.number(sub.Amount, 2)})
+
+		// exempt amounts have to state why
+		if reason := strings.TrimSpace(sub.TaxExemptionReasonCode + " " + sub.TaxExemptionReason); reason != "" {
+			rows = append(rows, [2]string{reason, ""})
+		}
+	}
+	rows = append(rows, [2]string{r.labels["totalVat"], r.number(r.inv.TaxTotal.Amount, 2)})
+
+	r.ensure(float64(len(rows))*14 + 30)
+	for _, row := range rows {
+		r.page.textRight(renderColumnUnitPrice+45, r.y, row[0], false, 9, color.Black)
+		r.page.textRight(renderColumnAmount-3, r.y, row[1], false, 9, color.Black)
+		r.y -= 14
+	}
+
+	payable := r.inv.LegalMonetaryTotal.PayableAmount
+	r.page.line(renderColumnUnitPrice-90, r.y+9, renderMarginRight, r.y+9, 0.8, r.accent)
+	r.y -= 4
+	r.page.textRight(renderColumnUnitPrice+45, r.y, r.labels["payable"], true, 11, color.Black)
+	r.page.textRight(renderColumnAmount-3, r.y, strings.TrimSpace(r.number(payable.Amount, 2)+" "+payable.CurrencyID), true, 11, color.Black)
+	r.y -= 30
+}
+
+func (r *invoiceRenderer) payment() {
+	pm := r.inv.PaymentMeans
+	if len(pm.FinancialAccount) == 0 && pm.PaymentIdentifier.ID == "" {
+		return
+	}
+
+	r.ensure(60 + float64(len(pm.FinancialAccount))*70)
+	r.page.text(renderMarginLeft, r.y, r.labels["payment"], true, 8, r.accent)
+	r.y -= 14
+
+	rows := [][2]string{}
+	for _, fa := range pm.FinancialAccount {
+		id := fa.accountID()
+		if id.SchemeID == "IBAN" || (id.SchemeID == "" && looksLikeIBAN(id.ID)) {
+			rows = append(rows, [2]string{r.labels["iban"], FormatIBAN(id.ID)})
+		} else if id.ID != "" {
+			rows = append(rows, [2]string{r.labels["account"], id.ID})
+		}
+		if fa.FinancialInstitutionID != "" {
+			rows = append(rows, [2]string{r.labels["bic"], fa.FinancialInstitutionID})
+		}
+	}
+	rows = append(rows,
+		[2]string{r.labels["paymentReference"], pm.PaymentIdentifier.ID},
+		[2]string{r.labels["dueDate"], r.date(pm.PaymentDueDate)},
+	)
+
+	for _, row := range rows {
+		if row[1] == "" {
+			continue
+		}
+		r.page.text(renderMarginLeft, r.y, row[0], false, 9, color.Gray{Y: 0x55})
+		r.page.text(renderMarginLeft+110, r.y, row[1], true, 9, color.Black)
+		r.y -= 13
+	}
+
+	for _, fa := range pm.FinancialAccount {
+		barcode := fa.Accounting.VirtualBankBarcode.VirtualBankBarCode
+		widths, err := code128C(barcode)
+		if err != nil {
+			continue
+		}
+
+		r.y -= 10
+		r.page.barcode(renderMarginLeft, r.y-40, 0.8, 40, widths)
+		r.y -= 52
+		r.page.text(renderMarginLeft, r.y, barcode, false, 8, color.Black)
+		r.y -= 14
+	}
+	r.y -= 10
+}
+
+func (r *invoiceRenderer) notes() {
+	sections := [][2]string{
+		{r.labels["paymentTerms"], r.inv.PaymentTerms.Note},
+		{r.labels["notes"], r.inv.Note},
+	}
+
+	for _, s := range sections {
+		if strings.TrimSpace(s[1]) == "" {
+			continue
+		}
+
+		r.ensure(40)
+		r.page.text(renderMarginLeft, r.y, s[0], true, 8, r.accent)
+		r.y -= 13
+		for _, paragraph := range strings.Split(s[1], "\n") {
+			for _, l := range wrapText(paragraph, renderMarginRight-renderMarginLeft, false, 9) {
+				r.ensure(12)
+				r.page.text(renderMarginLeft, r.y, l, false, 9, color.Black)
+				r.y -= 12
+			}
+		}
+		r.y -= 10
+	}
+}
+
+// number formats a value with at least the given number of decimals using the
+// separators of the language
+func (r *invoiceRenderer) number(f float64, decimals int) string {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if d := strings.IndexByte(s, '.'); d < 0 || len(s)-d-1 < decimals {
+		s = strconv.FormatFloat(f, 'f', decimals, 64)
+	}
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign = "-"
+		s = s[1:]
+	}
+	integer, fraction := s, ""
+	if d := strings.IndexByte(s, '.'); d >= 0 {
+		integer, fraction = s[:d], s[d+1:]
+	}
+
+	separators := renderNumberFormats[r.options.Language]
+	grouped := ""
+	for i, c := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped += separators[1]
+		}
+		grouped += string(c)
+	}
+
+	if fraction == "" {
+		return sign + grouped
+	}
+	return sign + grouped + separators[0] + fraction
+}
+
+// date formats a CCYY-MM-DD date for the language
+func (r *invoiceRenderer) date(s string) string {
+	if len(s) < 10 {
+		return s
+	}
+
+	year, month, day := s[0:4], strings.TrimPrefix(s[5:7], "0"), strings.TrimPrefix(s[8:10], "0")
+	switch renderDateFormats[r.options.Language] {
+	case "2.1.2006":
+		return day + "." + month + "." + year
+	case "02.01.2006":
+		return s[8:10] + "." + s[5:7] + "." + year
+	case "02-01-2006":
+		return s[8:10] + "-" + s[5:7] + "-" + year
+	}
+	return s[0:10]
+}
+
+// document assembles the pages into a PDF document
+func (r *invoiceRenderer) document() ([]byte, error) {
+	w := &pdfWriter{}
+	pagesRef := w.reserve()
+
+	fonts := newPDFDict().
+		set("F1", w.add(newPDFDict().
+			set("Type", pdfName("Font")).
+			set("Subtype", pdfName("Type1")).
+			set("BaseFont", pdfName("Helvetica")).
+			set("Encoding", pdfName("WinAnsiEncoding")))).
+		set("F2", w.add(newPDFDict().
+			set("Type", pdfName("Font")).
+			set("Subtype", pdfName("Type1")).
+			set("BaseFont", pdfName("Helvetica-Bold")).
+			set("Encoding", pdfName("WinAnsiEncoding"))))
+	resources := newPDFDict().set("Font", fonts)
+
+	if logo := r.options.Branding.Logo; logo != nil {
+		resources.set("XObject", newPDFDict().set("Logo", w.add(pdfImage(logo))))
+	}
+
+	kids := pdfArray{}
+	for i, page := range r.pages {
+		// footer with page numbers
+		if r.options.Branding.FooterText != "" {
+			page.line(renderMarginLeft, 55, renderMarginRight, 55, 0.5, r.accent)
+			page.text(renderMarginLeft, 42, r.options.Branding.FooterText, false, 7, color.Gray{Y: 0x55})
+		}
+		pageNumber := fmt.Sprintf("%s %d %s %d", r.labels["page"], i+1, r.labels["of"], len(r.pages))
+		page.textRight(renderMarginRight, 30, pageNumber, false, 7, color.Gray{Y: 0x55})
+
+		content, err := page.stream()
+		if err != nil {
+			return nil, err
+		}
+		kids = append(kids, w.add(newPDFDict().
+			set("Type", pdfName("Page")).
+			set("Parent", pagesRef).
+			set("MediaBox", pdfArray{pdfInt(0), pdfInt(0), pdfRaw(formatDecimal(renderPageWidth)), pdfRaw(formatDecimal(renderPageHeight))}).
+			set("Resources", resources).
+			set("Contents", w.add(content))))
+	}
+
+	w.set(pagesRef, newPDFDict().
+		set("Type", pdfName("Pages")).
+		set("Kids", kids).
+		set("Count", pdfInt(len(kids))))
+
+	catalog := w.add(newPDFDict().
+		set("Type", pdfName("Catalog")).
+		set("Pages", pagesRef))
+
+	title := r.labels["invoice"]
+	if r.docType == DocumentTypeCreditNote {
+		title = r.labels["creditNote"]
+	}
+	info := w.add(newPDFDict().
+		set("Title", pdfString(title+" "+r.inv.ID)).
+		set("Author", pdfString(r.inv.AccountingSupplierParty.PartyName)).
+		set("Producer", pdfString(userAgent)).
+		set("CreationDate", pdfString(pdfDate(time.Now().UTC()))))
+
+	return w.bytes(catalog, info), nil
+}
+
+// pdfCanvas collects the drawing operations of a page
+type pdfCanvas struct {
+	buf bytes.Buffer
+}
+
+func pdfColor(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("%.3f %.3f %.3f", float64(r)/0xffff, float64(g)/0xffff, float64(b)/0xffff)
+}
+
+func (c *pdfCanvas) text(x float64, y float64, s string, bold bool, size float64, col color.Color) {
+	if s == "" {
+		return
+	}
+
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+
+	escaped := []byte{}
+	for _, b := range encodeWinAnsi(s) {
+		if b == '(' || b == ')' || b == '\\' {
+			escaped = append(escaped, '\\')
+		}
+		escaped = append(escaped, b)
+	}
+
+	fmt.Fprintf(&c.buf, "BT /%s %s Tf %s rg %.2f %.2f Td (%s) Tj ET\n", font, formatDecimal(size), pdfColor(col), x, y, escaped)
+}
+
+func (c *pdfCanvas) textRight(right float64, y float64, s string, bold bool, size float64, col color.Color) {
+	c.text(right-textWidth(s, bold, size), y, s, bold, size, col)
+}
+
+func (c *pdfCanvas) line(x1 float64, y1 float64, x2 float64, y2 float64, width float64, col color.Color) {
+	fmt.Fprintf(&c.buf, "%s RG %.2f w %.2f %.2f m %.2f %.2f l S\n", pdfColor(col), width, x1, y1, x2, y2)
+}
+
+func (c *pdfCanvas) fillRect(x float64, y float64, w float64, h float64, col color.Color) {
+	fmt.Fprintf(&c.buf, "%s rg %.2f %.2f %.2f %.2f re f\n", pdfColor(col), x, y, w, h)
+}
+
+func (c *pdfCanvas) image(name string, x float64, y float64, w float64, h float64) {
+	fmt.Fprintf(&c.buf, "q %.2f 0 0 %.2f %.2f %.2f cm /%s Do Q\n", w, h, x, y, name)
+}
+
+// barcode draws bars and spaces of the given widths in modules
+func (c *pdfCanvas) barcode(x float64, y float64, module float64, height float64, widths []int) {
+	c.buf.WriteString("0 0 0 rg\n")
+	for i, w := range widths {
+		if i%2 == 0 {
+			fmt.Fprintf(&c.buf, "%.3f %.2f %.3f %.2f re\n", x, y, float64(w)*module, height)
+		}
+		x += float64(w) * module
+	}
+	c.buf.WriteString("f\n")
+}
+
+func (c *pdfCanvas) stream() (*pdfStream, error) {
+	compressed := new(bytes.Buffer)
+	zw := zlib.NewWriter(compressed)
+	if _, err := zw.Write(c.buf.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return &pdfStream{
+		Dict: newPDFDict().set("Filter", pdfName("FlateDecode")),
+		Data: compressed.Bytes(),
+	}, nil
+}
+
+// pdfImage converts an image into an RGB image XObject
+func pdfImage(img image.Image) *pdfStream {
+	b := img.Bounds()
+	compressed := new(bytes.Buffer)
+	zw := zlib.NewWriter(compressed)
+	row := make([]byte, 0, b.Dx()*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row = row[:0]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			// blend transparent pixels with a white background
+			r, g, bl, a := img.At(x, y).RGBA()
+			bg := 0xffff - a
+			row = append(row, byte((r+bg)>>8), byte((g+bg)>>8), byte((bl+bg)>>8))
+		}
+		zw.Write(row)
+	}
+	zw.Close()
+
+	return &pdfStream{
+		Dict: newPDFDict().
+			set("Type", pdfName("XObject")).
+			set("Subtype", pdfName("Image")).
+			set("Width", pdfInt(b.Dx())).
+			set("Height", pdfInt(b.Dy())).
+			set("ColorSpace", pdfName("DeviceRGB")).
+			set("BitsPerComponent", pdfInt(8)).
+			set("Filter", pdfName("FlateDecode")),
+		Data: compressed.Bytes(),
+	}
+}
+
+// wrapText breaks text into lines fitting width
+func wrapText(s string, width float64, bold bool, size float64) []string {
+	lines := []string{}
+	current := ""
+	for _, word := range strings.Fields(s) {
+		candidate := strings.TrimSpace(current + " " + word)
+		if textWidth(candidate, bold, size) <= width {
+			current = candidate
+			continue
+		}
+
+		if current != "" {
+			lines = append(lines, current)
+		}
+
+		// break words that don't fit on a line of their own
+		current = ""
+		for _, c := range word {
+			if textWidth(current+string(c), bold, size) > width && current != "" {
+				lines = append(lines, current)
+				current = ""
+			}
+			current += string(c)
+		}
+	}
+	if current != "" || len(lines) == 0 {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+func minFloat(a float64, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,127 @@
+package basware_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+func TestRenderPDF(t *testing.T) {
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// enough lines to need several pages
+	inv := doc.Invoice
+	line := inv.InvoiceLine[0]
+	for i := 2; i <= 80; i++ {
+		line.ID = fmt.Sprint(i)
+		inv.InvoiceLine = append(inv.InvoiceLine, line)
+	}
+
+	pdf, err := basware.RenderPDF(inv, doc.Type, basware.RenderOptions{
+		Language: "fi",
+		Branding: basware.Branding{FooterText: "Footer"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+		t.Error("expected a PDF document")
+	}
+	if !bytes.Contains(pdf, []byte("/Count 3")) {
+		t.Error("expected the lines to be paginated over three pages")
+	}
+
+	// the rendered document can be used as a Factur-X base document, but its
+	// standard fonts aren't embedded
+	_, warnings, err := basware.NewFacturX(pdf, inv, doc.Type)
+	if err != nil {
+		t.Errorf("couldn't read rendered document: %s", err)
+	}
+	fonts := []string{}
+	for _, w := range warnings {
+		if strings.HasPrefix(w.Path, "Font/") {
+			fonts = append(fonts, w.Path)
+		}
+	}
+	if strings.Join(fonts, ",") != "Font/Helvetica,Font/Helvetica-Bold" {
+		t.Errorf("expected warnings for the standard fonts, got %v", fonts)
+	}
+}
+
+func TestRenderPDFContent(t *testing.T) {
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv := doc.Invoice
+	barcode := "421123456000007850001240000000000000000000012345240331"
+	inv.PaymentMeans.FinancialAccount[0].Accounting.VirtualBankBarcode.VirtualBankBarCode = barcode
+
+	pdf, err := basware.RenderPDF(inv, doc.Type, basware.RenderOptions{
+		Language: "fi",
+		Branding: basware.Branding{Logo: image.NewRGBA(image.Rect(0, 0, 40, 10))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := pdfContent(t, pdf)
+	for _, s := range []string{
+		"(LASKU) Tj",
+		"(Maksutiedot) Tj",
+		"(IBAN) Tj",
+		"(FI21 1234 5600 0007 85) Tj",
+		"(" + barcode + ") Tj",
+		" re\n",
+		"/Logo Do",
+	} {
+		if !strings.Contains(content, s) {
+			t.Errorf("expected the content to contain %q", s)
+		}
+	}
+	if !bytes.Contains(pdf, []byte("/Subtype /Image")) {
+		t.Error("expected the logo image")
+	}
+
+	// an empty logo falls back to the supplier name
+	pdf, err = basware.RenderPDF(inv, doc.Type, basware.RenderOptions{
+		Branding: basware.Branding{Logo: image.NewRGBA(image.Rect(0, 0, 0, 10))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content = pdfContent(t, pdf)
+	if strings.Contains(content, "/Logo Do") || strings.Contains(content, "NaN") || strings.Contains(content, "Inf") {
+		t.Errorf("expected no logo for empty bounds")
+	}
+	if !strings.Contains(content, "(INVOICE) Tj") || !strings.Contains(content, "("+inv.AccountingSupplierParty.PartyName+") Tj") {
+		t.Error("expected the English labels and the supplier name")
+	}
+}
+
+// pdfContent returns the decompressed streams of a document
+func pdfContent(t *testing.T, pdf []byte) string {
+	content := new(strings.Builder)
+	for _, m := range regexp.MustCompile(`(?s)stream\r?\n(.*?)\nendstream`).FindAllSubmatch(pdf, -1) {
+		zr, err := zlib.NewReader(bytes.NewReader(m[1]))
+		if err != nil {
+			continue
+		}
+		data, err := ioutil.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content.Write(data)
+	}
+	return content.String()
+}
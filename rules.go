@@ -0,0 +1,130 @@
+package basware
+
+import (
+	"fmt"
+	"math"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// RuleSeverity tells whether a violated business rule makes the invoice
+// invalid
+type RuleSeverity string
+
+const (
+	// RuleSeverityFatal violations make the receiver reject the invoice
+	RuleSeverityFatal RuleSeverity = "fatal"
+
+	// RuleSeverityWarning violations are reported but the invoice is accepted
+	RuleSeverityWarning RuleSeverity = "warning"
+)
+
+// Rule is a single business rule, e.g. BR-CO-10 of EN 16931
+type Rule struct {
+	// Rule identifier, e.g. BR-CO-10
+	ID string
+
+	Severity RuleSeverity
+
+	// Description of the rule, used as the message of violations
+	Message string
+
+	// Check returns the paths of the fields that violate the rule, e.g.
+	// invoiceLine[0].quantity.unitCode. An empty path refers to the invoice as
+	// a whole.
+	Check func(inv Invoice) []string
+}
+
+// RuleSet is a named collection of business rules. Rule sets other than
+// EN16931RuleSet can be passed to ValidateInvoice to check additional
+// requirements, e.g. of a receiver or a national CIUS.
+type RuleSet struct {
+	Name  string
+	Rules []Rule
+}
+
+// Validate checks the invoice against every rule of the set
+func (rs RuleSet) Validate(inv Invoice) RuleViolations {
+	violations := RuleViolations{}
+	for _, r := range rs.Rules {
+		for _, path := range r.Check(inv) {
+			violations = append(violations, RuleViolation{
+				RuleSet:  rs.Name,
+				RuleID:   r.ID,
+				Severity: r.Severity,
+				Path:     path,
+				Message:  r.Message,
+			})
+		}
+	}
+	return violations
+}
+
+// ValidateInvoice checks the invoice against the EN 16931 business rules, the
+// party identifiers and the given extra rule sets. Use it to catch semantic
+// errors before sending the invoice with InvoicesService.Post.
+func ValidateInvoice(inv Invoice, ruleSets ...RuleSet) RuleViolations {
+	violations := EN16931RuleSet.Validate(inv)
+	violations = append(violations, IdentifierRuleSet.Validate(inv)...)
+	for _, rs := range ruleSets {
+		violations = append(violations, rs.Validate(inv)...)
+	}
+	return violations
+}
+
+// RuleViolation is a business rule the invoice doesn't satisfy
+type RuleViolation struct {
+	// Name of the rule set the rule belongs to
+	RuleSet string
+
+	RuleID   string
+	Severity RuleSeverity
+
+	// Field in the JSON representation of the invoice, e.g.
+	// accountingSupplierParty.partyTaxScheme.company.id
+	Path string
+
+	Message string
+}
+
+func (v RuleViolation) Error() string {
+	if v.Path == "" {
+		return fmt.Sprintf("[%s] %s", v.RuleID, v.Message)
+	}
+	return fmt.Sprintf("[%s] %s: %s", v.RuleID, v.Path, v.Message)
+}
+
+type RuleViolations []RuleViolation
+
+func (vs RuleViolations) Error() string {
+	if len(vs) == 0 {
+		return ""
+	}
+
+	var errors error
+	for _, v := range vs {
+		errors = multierror.Append(errors, v)
+	}
+	return errors.Error()
+}
+
+// Fatal returns the violations with severity fatal
+func (vs RuleViolations) Fatal() RuleViolations {
+	fatal := RuleViolations{}
+	for _, v := range vs {
+		if v.Severity == RuleSeverityFatal {
+			fatal = append(fatal, v)
+		}
+	}
+	return fatal
+}
+
+// roundAmount rounds a monetary amount to two decimals, the precision the
+// business rules compare amounts at
+func roundAmount(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+func amountsEqual(a float64, b float64) bool {
+	return math.Abs(roundAmount(a)-roundAmount(b)) < 0.001
+}
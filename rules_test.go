@@ -0,0 +1,76 @@
+package basware_test
+
+import (
+	"strings"
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+func TestValidateInvoice(t *testing.T) {
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	violations := basware.ValidateInvoice(doc.Invoice)
+	if len(violations) != 0 {
+		t.Fatalf("expected a valid invoice, got %s", violations)
+	}
+
+	inv := doc.Invoice
+	inv.AccountingSupplierParty.PartyTaxScheme.Company.ID = ""
+	inv.TaxTotal.TaxSubTotal[0].TaxableAmount = 90
+
+	extra := basware.RuleSet{
+		Name: "receiver",
+		Rules: []basware.Rule{{
+			ID:       "R-01",
+			Severity: basware.RuleSeverityWarning,
+			Message:  "An order reference is expected",
+			Check: func(inv basware.Invoice) []string {
+				return []string{"orderReference.id"}
+			},
+		}},
+	}
+
+	found := map[string]basware.RuleViolation{}
+	for _, v := range basware.ValidateInvoice(inv, extra) {
+		found[v.RuleID] = v
+	}
+
+	tests := map[string]string{
+		"BR-S-02": "accountingSupplierParty.partyTaxScheme.company.id",
+		"BR-S-08": "taxTotal.taxSubTotal[0].taxableAmount",
+		"R-01":    "orderReference.id",
+	}
+	for id, path := range tests {
+		if v, ok := found[id]; !ok || v.Path != path {
+			t.Errorf("expected violation %s of %s, got %v", id, path, v)
+		}
+	}
+
+	if len(basware.ValidateInvoice(inv, extra).Fatal()) != len(found)-1 {
+		t.Error("expected the extra rule to be a warning")
+	}
+}
+
+func TestValidateInvoiceOtherTaxSchemes(t *testing.T) {
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a GST registration is no VAT identifier
+	inv := doc.Invoice
+	inv.AccountingSupplierParty.PartyTaxScheme.Company.SchemeID = "GST"
+	found := false
+	for _, v := range basware.ValidateInvoice(inv) {
+		if v.RuleID == "BR-S-02" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected violation BR-S-02 for a seller with only a GST registration")
+	}
+}
@@ -0,0 +1,150 @@
+package basware
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//go:embed invoices_post_request_schema.json
+var invoicesPostRequestSchemaJSON []byte
+
+// jsonSchema is the subset of JSON schema the Basware API schemas use
+type jsonSchema struct {
+	Type                 string                 `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Required             []string               `json:"required"`
+	Items                *jsonSchema            `json:"items"`
+	Pattern              string                 `json:"pattern"`
+	Enum                 []string               `json:"enum"`
+
+	pattern *regexp.Regexp
+}
+
+var invoicesPostRequestSchema = mustParseJSONSchema(invoicesPostRequestSchemaJSON)
+
+func mustParseJSONSchema(data []byte) *jsonSchema {
+	schema := &jsonSchema{}
+	if err := json.Unmarshal(data, schema); err != nil {
+		panic(err)
+	}
+	schema.compile()
+	return schema
+}
+
+func (s *jsonSchema) compile() {
+	if s.Pattern != "" {
+		s.pattern = regexp.MustCompile(s.Pattern)
+	}
+	for _, p := range s.Properties {
+		p.compile()
+	}
+	if s.Items != nil {
+		s.Items.compile()
+	}
+}
+
+// ValidateInvoicesPostRequestJSON checks a request body of InvoicesService.Post
+// against the JSON schema of the API. The errors are reported like the API
+// does in an ErrorResponse.
+func ValidateInvoicesPostRequestJSON(data []byte) ValidationErrors {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return ValidationErrors{{FieldID: "", FieldMessage: err.Error()}}
+	}
+	errs := ValidationErrors{}
+	invoicesPostRequestSchema.validate("", v, &errs)
+	return errs
+}
+
+func (s *jsonSchema) validate(path string, v interface{}, errs *ValidationErrors) {
+	add := func(format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{FieldID: path, FieldMessage: fmt.Sprintf(format, args...)})
+	}
+
+	if t := jsonType(v); s.Type != "" && t != s.Type {
+		add("instance type (%s) does not match any allowed primitive type (allowed: [\"%s\"])", t, s.Type)
+		return
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		missing := []string{}
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				missing = append(missing, fmt.Sprintf("%q", name))
+			}
+		}
+		if len(missing) > 0 {
+			add("object has missing required properties ([%s])", strings.Join(missing, ","))
+		}
+
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		unknown := []string{}
+		for _, name := range names {
+			p, ok := s.Properties[name]
+			if !ok {
+				unknown = append(unknown, fmt.Sprintf("%q", name))
+				continue
+			}
+			p.validate(joinFieldID(path, name), v[name], errs)
+		}
+		if len(unknown) > 0 && s.AdditionalProperties != nil && !*s.AdditionalProperties {
+			add("object instance has properties which are not allowed by the schema: [%s]", strings.Join(unknown, ","))
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+	case string:
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			add("ECMA 262 regex \"%s\" does not match input string \"%s\"", s.Pattern, v)
+		}
+		if len(s.Enum) > 0 && !containsString(s.Enum, v) {
+			add("instance value (\"%s\") not found in enum (possible values: [\"%s\"])", v, strings.Join(s.Enum, "\",\""))
+		}
+	}
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+func joinFieldID(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package basware
+
+// VAT category codes (UNCL 5305) of EN 16931
+const (
+	VATCategoryStandard       = "S"
+	VATCategoryZero           = "Z"
+	VATCategoryExempt         = "E"
+	VATCategoryReverseCharge  = "AE"
+	VATCategoryIntraCommunity = "K"
+	VATCategoryExport         = "G"
+	VATCategoryOutsideScope   = "O"
+)
+
+// TaxSchemeVAT is the tax scheme of items and subtotals without a TaxSchemeID
+const TaxSchemeVAT = "VAT"
+
+// taxCategory returns the explicit tax category or the one implied by the rate
+func (i Item) taxCategory() string {
+	if i.TaxCategoryID != "" {
+		return i.TaxCategoryID
+	}
+	return impliedTaxCategory(i.TaxPercent)
+}
+
+func (i Item) taxScheme() string {
+	if i.TaxSchemeID != "" {
+		return i.TaxSchemeID
+	}
+	return TaxSchemeVAT
+}
+
+func (l InvoiceLine) taxCategory() string {
+	return l.Item.taxCategory()
+}
+
+// taxCategory returns the explicit tax category or the one implied by the rate
+func (sub TaxSubTotalItem) taxCategory() string {
+	if sub.TaxCategoryID != "" {
+		return sub.TaxCategoryID
+	}
+	return impliedTaxCategory(sub.Percent)
+}
+
+func (sub TaxSubTotalItem) taxScheme() string {
+	if sub.TaxSchemeID != "" {
+		return sub.TaxSchemeID
+	}
+	return TaxSchemeVAT
+}
+
+// taxGroup identifies a VAT breakdown
+type taxGroup struct {
+	scheme     string
+	category   string
+	percent    float64
+	reasonCode string
+	reason     string
+}
+
+// CalculateTaxTotal computes the tax breakdown of the invoice from its lines.
+// Lines are grouped by tax scheme, category, rate and exemption reason. The
+// Basware model has no tax category on document level charges; they're taxed
+// in the breakdown with the highest rate.
+func CalculateTaxTotal(inv Invoice) TaxTotal {
+	groups := []taxGroup{}
+	taxable := map[taxGroup]float64{}
+	for _, l := range inv.InvoiceLine {
+		group := taxGroup{
+			scheme:     l.Item.taxScheme(),
+			category:   l.Item.taxCategory(),
+			percent:    l.Item.TaxPercent,
+			reasonCode: l.Item.TaxExemptionReasonCode,
+			reason:     l.Item.TaxExemptionReason,
+		}
+		if _, ok := taxable[group]; !ok {
+			groups = append(groups, group)
+		}
+		taxable[group] += l.LineExtension.Amount
+	}
+
+	tt := TaxTotal{CurrencyID: inv.DocumentCurrencyCode}
+	for _, group := range groups {
+		sub := TaxSubTotalItem{
+			CurrencyID:             inv.DocumentCurrencyCode,
+			Percent:                group.percent,
+			TaxableAmount:          roundAmount(taxable[group]),
+			TaxExemptionReasonCode: group.reasonCode,
+			TaxExemptionReason:     group.reason,
+		}
+		// only state what can't be implied
+		if group.category != impliedTaxCategory(group.percent) {
+			sub.TaxCategoryID = group.category
+		}
+		if group.scheme != TaxSchemeVAT {
+			sub.TaxSchemeID = group.scheme
+		}
+		tt.TaxSubTotal = append(tt.TaxSubTotal, sub)
+	}
+
+	if i := chargeBreakdown(tt.TaxSubTotal); i >= 0 {
+		tt.TaxSubTotal[i].TaxableAmount = roundAmount(tt.TaxSubTotal[i].TaxableAmount + documentCharges(inv))
+	}
+
+	for i, sub := range tt.TaxSubTotal {
+		tt.TaxSubTotal[i].Amount = roundAmount(sub.TaxableAmount * sub.Percent / 100)
+		tt.Amount = roundAmount(tt.Amount + tt.TaxSubTotal[i].Amount)
+	}
+	return tt
+}
+
+// CalculateTotals sets the tax breakdown, the sum of the line amounts and the
+// payable amount of the invoice from its lines and charges
+func (inv *Invoice) CalculateTotals() {
+	lines := 0.0
+	for _, l := range inv.InvoiceLine {
+		lines += l.LineExtension.Amount
+	}
+
+	inv.TaxTotal = CalculateTaxTotal(*inv)
+	inv.LegalMonetaryTotal.LineExtensionAmount = Amount{
+		Amount:     roundAmount(lines),
+		CurrencyID: inv.DocumentCurrencyCode,
+	}
+	inv.LegalMonetaryTotal.PayableAmount = Amount{
+		Amount:     roundAmount(lines + documentCharges(*inv) + inv.TaxTotal.Amount),
+		CurrencyID: inv.DocumentCurrencyCode,
+	}
+}
@@ -0,0 +1,229 @@
+package basware
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// The Basware API has no fields for the tax category, exemption reason and
+// tax scheme of items and tax subtotals. Files and tools can keep them in
+// Basware JSON extended with these fields:
+//
+//	"item": {"name": "Consulting", "taxCategoryId": "AE",
+//		"taxExemptionReasonCode": "VATEX-EU-AE"}
+//
+// The API rejects the extended JSON: strip the fields with StripInvoiceJSON
+// or send the Invoice itself, which leaves them out.
+
+// taxCategoryJSON holds the extension fields of an item or tax subtotal
+type taxCategoryJSON struct {
+	TaxCategoryID          string `json:"taxCategoryId,omitempty"`
+	TaxExemptionReasonCode string `json:"taxExemptionReasonCode,omitempty"`
+	TaxExemptionReason     string `json:"taxExemptionReason,omitempty"`
+	TaxSchemeID            string `json:"taxSchemeId,omitempty"`
+}
+
+var taxCategoryJSONKeys = []string{"taxCategoryId", "taxExemptionReasonCode", "taxExemptionReason", "taxSchemeId"}
+
+// invoiceTaxJSON holds the extension fields at their places in the invoice
+type invoiceTaxJSON struct {
+	TaxTotal struct {
+		TaxSubTotal []taxCategoryJSON `json:"taxSubTotal"`
+	} `json:"taxTotal"`
+	InvoiceLine []invoiceLineTaxJSON `json:"invoiceLine"`
+}
+
+type invoiceLineTaxJSON struct {
+	Item     taxCategoryJSON `json:"item"`
+	TaxTotal []struct {
+		TaxSubTotal []taxCategoryJSON `json:"taxSubTotal"`
+	} `json:"taxTotal"`
+}
+
+func newInvoiceTaxJSON(inv Invoice) *invoiceTaxJSON {
+	ext := &invoiceTaxJSON{}
+	for _, sub := range inv.TaxTotal.TaxSubTotal {
+		ext.TaxTotal.TaxSubTotal = append(ext.TaxTotal.TaxSubTotal, subTotalTaxJSON(sub))
+	}
+	for _, l := range inv.InvoiceLine {
+		line := invoiceLineTaxJSON{Item: taxCategoryJSON{
+			TaxCategoryID:          l.Item.TaxCategoryID,
+			TaxExemptionReasonCode: l.Item.TaxExemptionReasonCode,
+			TaxExemptionReason:     l.Item.TaxExemptionReason,
+			TaxSchemeID:            l.Item.TaxSchemeID,
+		}}
+		line.TaxTotal = make([]struct {
+			TaxSubTotal []taxCategoryJSON `json:"taxSubTotal"`
+		}, len(l.TaxTotal))
+		for i, t := range l.TaxTotal {
+			for _, sub := range t.TaxSubTotal {
+				line.TaxTotal[i].TaxSubTotal = append(line.TaxTotal[i].TaxSubTotal, subTotalTaxJSON(sub))
+			}
+		}
+		ext.InvoiceLine = append(ext.InvoiceLine, line)
+	}
+	return ext
+}
+
+func subTotalTaxJSON(sub TaxSubTotalItem) taxCategoryJSON {
+	return taxCategoryJSON{
+		TaxCategoryID:          sub.TaxCategoryID,
+		TaxExemptionReasonCode: sub.TaxExemptionReasonCode,
+		TaxExemptionReason:     sub.TaxExemptionReason,
+		TaxSchemeID:            sub.TaxSchemeID,
+	}
+}
+
+func (ext taxCategoryJSON) applyToItem(item *Item) {
+	item.TaxCategoryID = ext.TaxCategoryID
+	item.TaxExemptionReasonCode = ext.TaxExemptionReasonCode
+	item.TaxExemptionReason = ext.TaxExemptionReason
+	item.TaxSchemeID = ext.TaxSchemeID
+}
+
+func (ext taxCategoryJSON) applyToSubTotal(sub *TaxSubTotalItem) {
+	sub.TaxCategoryID = ext.TaxCategoryID
+	sub.TaxExemptionReasonCode = ext.TaxExemptionReasonCode
+	sub.TaxExemptionReason = ext.TaxExemptionReason
+	sub.TaxSchemeID = ext.TaxSchemeID
+}
+
+// MarshalInvoiceJSON encodes the invoice as Basware JSON extended with the
+// tax category fields
+func MarshalInvoiceJSON(inv Invoice) ([]byte, error) {
+	doc, err := decodeJSONObject(inv)
+	if err != nil {
+		return nil, err
+	}
+	eachTaxJSON(doc, newInvoiceTaxJSON(inv), func(obj map[string]interface{}, ext taxCategoryJSON) {
+		for k, v := range map[string]string{
+			"taxCategoryId":          ext.TaxCategoryID,
+			"taxExemptionReasonCode": ext.TaxExemptionReasonCode,
+			"taxExemptionReason":     ext.TaxExemptionReason,
+			"taxSchemeId":            ext.TaxSchemeID,
+		} {
+			if v != "" {
+				obj[k] = v
+			}
+		}
+	})
+	return json.Marshal(doc)
+}
+
+// UnmarshalInvoiceJSON decodes Basware JSON with or without the tax category
+// fields
+func UnmarshalInvoiceJSON(data []byte, inv *Invoice) error {
+	if err := json.Unmarshal(data, inv); err != nil {
+		return err
+	}
+	ext := invoiceTaxJSON{}
+	if err := json.Unmarshal(data, &ext); err != nil {
+		return err
+	}
+
+	for i := range inv.TaxTotal.TaxSubTotal {
+		if i < len(ext.TaxTotal.TaxSubTotal) {
+			ext.TaxTotal.TaxSubTotal[i].applyToSubTotal(&inv.TaxTotal.TaxSubTotal[i])
+		}
+	}
+	for i := range inv.InvoiceLine {
+		if i >= len(ext.InvoiceLine) {
+			break
+		}
+		l := &inv.InvoiceLine[i]
+		ext.InvoiceLine[i].Item.applyToItem(&l.Item)
+		for j := range l.TaxTotal {
+			if j >= len(ext.InvoiceLine[i].TaxTotal) {
+				break
+			}
+			subs := ext.InvoiceLine[i].TaxTotal[j].TaxSubTotal
+			for k := range l.TaxTotal[j].TaxSubTotal {
+				if k < len(subs) {
+					subs[k].applyToSubTotal(&l.TaxTotal[j].TaxSubTotal[k])
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// StripInvoiceJSON removes the tax category fields from a Basware JSON
+// invoice, so it can be sent or checked against the API schema
+func StripInvoiceJSON(data []byte) ([]byte, error) {
+	doc := map[string]interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	eachTaxJSON(doc, nil, func(obj map[string]interface{}, ext taxCategoryJSON) {
+		for _, k := range taxCategoryJSONKeys {
+			delete(obj, k)
+		}
+	})
+	return json.Marshal(doc)
+}
+
+// decodeJSONObject encodes v and decodes it as generic JSON object, keeping
+// numbers as they are
+func decodeJSONObject(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc := map[string]interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return doc, dec.Decode(&doc)
+}
+
+// eachTaxJSON calls fn for the items and tax subtotals of a generic JSON
+// invoice with the extension fields at the same place in ext, if any
+func eachTaxJSON(doc map[string]interface{}, ext *invoiceTaxJSON, fn func(obj map[string]interface{}, ext taxCategoryJSON)) {
+	object := func(v interface{}, key string) map[string]interface{} {
+		m, _ := v.(map[string]interface{})
+		o, _ := m[key].(map[string]interface{})
+		return o
+	}
+	array := func(v interface{}, key string) []interface{} {
+		m, _ := v.(map[string]interface{})
+		a, _ := m[key].([]interface{})
+		return a
+	}
+	call := func(v interface{}, exts []taxCategoryJSON, i int) {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := taxCategoryJSON{}
+		if i < len(exts) {
+			fields = exts[i]
+		}
+		fn(obj, fields)
+	}
+	if ext == nil {
+		ext = &invoiceTaxJSON{}
+	}
+
+	for i, sub := range array(object(doc, "taxTotal"), "taxSubTotal") {
+		call(sub, ext.TaxTotal.TaxSubTotal, i)
+	}
+	for i, line := range array(doc, "invoiceLine") {
+		lineExt := invoiceLineTaxJSON{}
+		if i < len(ext.InvoiceLine) {
+			lineExt = ext.InvoiceLine[i]
+		}
+		if item := object(line, "item"); item != nil {
+			fn(item, lineExt.Item)
+		}
+		for j, total := range array(line, "taxTotal") {
+			subs := []taxCategoryJSON{}
+			if j < len(lineExt.TaxTotal) {
+				subs = lineExt.TaxTotal[j].TaxSubTotal
+			}
+			for k, sub := range array(total, "taxSubTotal") {
+				call(sub, subs, k)
+			}
+		}
+	}
+}
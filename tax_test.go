@@ -0,0 +1,116 @@
+package basware_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+func TestCalculateTotals(t *testing.T) {
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inv := doc.Invoice
+	line := inv.InvoiceLine[0]
+	inv.InvoiceLine = nil
+	for i, item := range []basware.Item{
+		{Name: "Standard", TaxPercent: 24},
+		{Name: "Reduced", TaxPercent: 14},
+		{Name: "Standard", TaxPercent: 24},
+		{Name: "Reverse charge", TaxCategoryID: basware.VATCategoryReverseCharge, TaxExemptionReasonCode: "VATEX-EU-AE", TaxExemptionReason: "Reverse charge"},
+	} {
+		line.ID = string(rune('1' + i))
+		line.Item = item
+		inv.InvoiceLine = append(inv.InvoiceLine, line)
+	}
+	inv.AllowanceCharge.Freight = 10
+	inv.AccountingCustomerParty.PartyTaxScheme.Company.ID = "SE123456789701"
+	inv.CalculateTotals()
+
+	subs := inv.TaxTotal.TaxSubTotal
+	if len(subs) != 3 {
+		t.Fatalf("expected three breakdowns, got %+v", subs)
+	}
+	if subs[0].TaxableAmount != 210 || subs[0].Amount != 50.4 {
+		t.Errorf("expected freight in the standard rated breakdown, got %+v", subs[0])
+	}
+	if subs[2].TaxCategoryID != "AE" || subs[2].Amount != 0 || subs[2].TaxExemptionReasonCode != "VATEX-EU-AE" {
+		t.Errorf("unexpected reverse charge breakdown %+v", subs[2])
+	}
+	if inv.TaxTotal.Amount != 64.4 || inv.LegalMonetaryTotal.PayableAmount.Amount != 474.4 {
+		t.Errorf("unexpected totals %v, %+v", inv.TaxTotal.Amount, inv.LegalMonetaryTotal)
+	}
+
+	if violations := basware.ValidateInvoice(inv); len(violations) != 0 {
+		t.Errorf("expected calculated totals to be valid, got %s", violations)
+	}
+
+	// the API has no tax category fields
+	data, err := json.Marshal(basware.InvoicesPostRequestBody{ClientToken: "token", Data: inv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := basware.ValidateInvoicesPostRequestJSON(data); len(errs) != 0 || strings.Contains(string(data), "taxCategoryId") {
+		t.Errorf("expected the tax categories to stay off the wire, got %s", errs)
+	}
+
+	inv.TaxTotal.TaxSubTotal[2].TaxExemptionReasonCode = ""
+	inv.TaxTotal.TaxSubTotal[2].TaxExemptionReason = ""
+	violations := basware.ValidateInvoice(inv)
+	if len(violations) != 1 || violations[0].RuleID != "BR-AE-10" {
+		t.Errorf("expected missing exemption reason, got %s", violations)
+	}
+}
+
+func TestInvoiceJSON(t *testing.T) {
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inv := doc.Invoice
+	inv.InvoiceLine[0].Item.TaxPercent = 0
+	inv.InvoiceLine[0].Item.TaxCategoryID = basware.VATCategoryReverseCharge
+	inv.InvoiceLine[0].Item.TaxExemptionReasonCode = "VATEX-EU-AE"
+	inv.InvoiceLine[0].Item.TaxExemptionReason = "Reverse charge"
+	inv.AccountingCustomerParty.PartyTaxScheme.Company.ID = "SE123456789701"
+	inv.CalculateTotals()
+
+	data, err := basware.MarshalInvoiceJSON(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"taxCategoryId":"AE"`) {
+		t.Errorf("expected the tax category in %s", data)
+	}
+
+	decoded := basware.Invoice{}
+	if err := basware.UnmarshalInvoiceJSON(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.InvoiceLine[0].Item, inv.InvoiceLine[0].Item) {
+		t.Errorf("expected item %+v, got %+v", inv.InvoiceLine[0].Item, decoded.InvoiceLine[0].Item)
+	}
+	sub := decoded.TaxTotal.TaxSubTotal[0]
+	if sub.TaxCategoryID != "AE" || sub.TaxExemptionReasonCode != "VATEX-EU-AE" || sub.TaxExemptionReason != "Reverse charge" {
+		t.Errorf("unexpected reverse charge breakdown %+v", sub)
+	}
+	if violations := basware.ValidateInvoice(decoded); len(violations) != 0 {
+		t.Errorf("expected the decoded invoice to be valid, got %s", violations)
+	}
+
+	// the stripped invoice is accepted by the API
+	stripped, err := basware.StripInvoiceJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"clientToken": "token", "data": ` + string(stripped) + `}`)
+	if errs := basware.ValidateInvoicesPostRequestJSON(body); len(errs) != 0 || strings.Contains(string(stripped), "taxCategoryId") {
+		t.Errorf("expected the tax categories to be stripped, got %s", errs)
+	}
+}
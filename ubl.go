@@ -0,0 +1,748 @@
+package basware
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseUBL converts an UBL 2.1 Invoice or CreditNote document into the Basware
+// invoice model. Elements of the UBL document that have no Basware equivalent
+// are reported in the warnings of the returned document instead of being
+// silently dropped.
+func ParseUBL(r io.Reader) (*ImportedDocument, error) {
+	root, err := parseXMLNode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &ImportedDocument{}
+	lineName := ""
+	quantityName := ""
+	switch root.name() {
+	case "Invoice":
+		doc.Type = DocumentTypeInvoice
+		lineName = "InvoiceLine"
+		quantityName = "InvoicedQuantity"
+	case "CreditNote":
+		doc.Type = DocumentTypeCreditNote
+		lineName = "CreditNoteLine"
+		quantityName = "CreditedQuantity"
+	default:
+		return nil, fmt.Errorf("Expected UBL Invoice or CreditNote, got \"%s\"", root.name())
+	}
+
+	c := &ublConverter{}
+	doc.Invoice = c.invoice(root, lineName, quantityName)
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	c.reportUnused(root)
+	doc.Warnings = c.warnings
+	return doc, nil
+}
+
+// NewPostRequestBodyFromUBL creates a request body from an UBL 2.1 Invoice or
+// CreditNote document. The returned warnings list the UBL elements that
+// couldn't be mapped.
+func (s *InvoicesService) NewPostRequestBodyFromUBL(r io.Reader) (*InvoicesPostRequestBody, ConversionWarnings, error) {
+	doc, err := ParseUBL(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return doc.InvoicesPostRequestBody(), doc.Warnings, nil
+}
+
+type ublConverter struct {
+	converter
+}
+
+func (c *ublConverter) invoice(root *xmlNode, lineName string, quantityName string) Invoice {
+	inv := Invoice{}
+
+	// the UBL version and profile identifiers only describe the envelope
+	root.text("UBLVersionID")
+	root.text("CustomizationID")
+	root.text("ProfileID")
+	root.text("InvoiceTypeCode")
+	root.text("CreditNoteTypeCode")
+
+	inv.ID = root.text("ID")
+	inv.IDSchemeID = root.child("ID").attr("schemeID")
+	inv.IssueDate = root.text("IssueDate")
+	inv.DocumentCurrencyCode = root.text("DocumentCurrencyCode")
+
+	notes := []string{}
+	for _, n := range root.children("Note") {
+		n.used = true
+		notes = append(notes, strings.TrimSpace(n.Content))
+	}
+	inv.Note = strings.Join(notes, "\n")
+
+	inv.BuyerReference.ID = root.text("BuyerReference")
+
+	inv.OrderReference.ID = root.text("OrderReference", "ID")
+	inv.OrderReference.SchemeID = root.child("OrderReference", "ID").attr("schemeID")
+	inv.OrderReference.SalesOrderID = root.text("OrderReference", "SalesOrderID")
+	inv.OrderReference.CustomerReference = root.text("OrderReference", "CustomerReference")
+
+	billing := root.child("BillingReference", "InvoiceDocumentReference")
+	if billing == nil {
+		billing = root.child("BillingReference", "CreditNoteDocumentReference")
+	}
+	inv.BillingReference.ID = billing.text("ID")
+	inv.BillingReference.SchemeID = billing.child("ID").attr("schemeID")
+
+	inv.ContractDocumentReference.ID = root.text("ContractDocumentReference", "ID")
+	inv.ContractDocumentReference.SchemeID = root.child("ContractDocumentReference", "ID").attr("schemeID")
+
+	refs := root.children("AdditionalDocumentReference")
+	if len(refs) > 0 {
+		ref := refs[0]
+		inv.AdditionalDocumentReference.ID = ref.text("ID")
+		inv.AdditionalDocumentReference.SchemeID = ref.child("ID").attr("schemeID")
+		inv.AdditionalDocumentReference.IssueDate = ref.text("IssueDate")
+		inv.AdditionalDocumentReference.TypeCode = ref.text("DocumentTypeCode")
+	}
+	if len(refs) > 1 {
+		for _, ref := range refs[1:] {
+			c.warn(root.name()+"/AdditionalDocumentReference", "only one additional document reference is supported, dropped \"%s\"", ref.text("ID"))
+			ref.markUsed()
+		}
+	}
+
+	inv.AccountingSupplierParty = c.party(root.child("AccountingSupplierParty", "Party")).accountingSupplierParty()
+	inv.AccountingCustomerParty = c.party(root.child("AccountingCustomerParty", "Party")).accountingCustomerParty()
+
+	delivery := root.child("Delivery")
+	inv.Delivery.ActualDeliveryDate = delivery.text("ActualDeliveryDate")
+	if p := delivery.child("DeliveryParty"); p != nil {
+		inv.DeliveryParty = c.party(p).deliveryParty()
+	}
+	if a := delivery.child("DeliveryLocation", "Address"); a != nil {
+		inv.DeliveryParty.PostalAddress = c.postalAddress(a)
+	}
+
+	inv.PaymentMeans = c.paymentMeans(root)
+	inv.PaymentTerms = c.paymentTerms(root.child("PaymentTerms"))
+	inv.AllowanceCharge = c.allowanceCharge(root)
+
+	taxTotals := root.children("TaxTotal")
+	if len(taxTotals) > 0 {
+		inv.TaxTotal = c.taxTotal(taxTotals[0])
+	}
+	if len(taxTotals) > 1 {
+		for _, tt := range taxTotals[1:] {
+			c.warn(root.name()+"/TaxTotal", "only one tax total is supported, dropped tax total in %s", tt.child("TaxAmount").attr("currencyID"))
+			tt.markUsed()
+		}
+	}
+
+	inv.LegalMonetaryTotal = c.legalMonetaryTotal(root.child("LegalMonetaryTotal"))
+
+	inv.InvoiceLine = []InvoiceLine{}
+	for _, l := range root.children(lineName) {
+		inv.InvoiceLine = append(inv.InvoiceLine, c.invoiceLine(l, quantityName))
+	}
+
+	// document level due date is used when the payment means doesn't have one
+	dueDate := root.text("DueDate")
+	if inv.PaymentMeans.PaymentDueDate == "" {
+		inv.PaymentMeans.PaymentDueDate = dueDate
+	}
+
+	return inv
+}
+
+func (c *ublConverter) party(n *xmlNode) party {
+	p := party{}
+	if n == nil {
+		return p
+	}
+
+	p.Endpoint.ID = n.text("EndpointID")
+	p.Endpoint.SchemeID = n.child("EndpointID").attr("schemeID")
+
+	for _, id := range n.children("PartyIdentification") {
+		p.PartyIdentification = append(p.PartyIdentification, PartyIdentificationItem{
+			ID:       id.text("ID"),
+			SchemeID: id.child("ID").attr("schemeID"),
+		})
+	}
+
+	p.PartyName = n.text("PartyName", "Name")
+	if p.PartyName == "" {
+		p.PartyName = n.text("PartyLegalEntity", "RegistrationName")
+	}
+	for _, le := range n.children("PartyLegalEntity") {
+		if le.child("CompanyID") == nil {
+			continue
+		}
+		p.PartyLegalEntities = append(p.PartyLegalEntities, PartyLegalEntity{
+			RegistrationName: le.text("RegistrationName"),
+			CompanyID:        le.text("CompanyID"),
+			SchemeID:         le.child("CompanyID").attr("schemeID"),
+		})
+	}
+
+	if a := n.child("PostalAddress"); a != nil {
+		p.PostalAddress = c.postalAddress(a)
+	}
+
+	for i, scheme := range n.children("PartyTaxScheme") {
+		ts := PartyTaxScheme{}
+		ts.Company.ID = scheme.text("CompanyID")
+		ts.Company.SchemeID = scheme.child("CompanyID").attr("schemeID")
+		if ts.Company.SchemeID == "" {
+			ts.Company.SchemeID = scheme.text("TaxScheme", "ID")
+		} else {
+			scheme.text("TaxScheme", "ID")
+		}
+
+		if i == 0 {
+			p.PartyTaxScheme = ts
+		} else {
+			p.AdditionalPartyTaxSchemes = append(p.AdditionalPartyTaxSchemes, ts)
+		}
+	}
+
+	contact := n.child("Contact")
+	p.Contact.Name = contact.text("Name")
+	p.Contact.Telephone = contact.text("Telephone")
+	p.Contact.Telefax = contact.text("Telefax")
+	p.Contact.ElectronicMail = contact.text("ElectronicMail")
+
+	return p
+}
+
+func (c *ublConverter) postalAddress(n *xmlNode) PostalAddress {
+	a := PostalAddress{
+		AddressLine:      n.text("StreetName"),
+		AddressLine2:     n.text("AdditionalStreetName"),
+		CityName:         n.text("CityName"),
+		PostalZone:       n.text("PostalZone"),
+		Locality:         n.text("District"),
+		CountrySubentity: n.text("CountrySubentity"),
+		CountryID:        n.text("Country", "IdentificationCode"),
+	}
+
+	// free form address lines fill up the empty structured lines
+	for _, l := range n.children("AddressLine") {
+		switch {
+		case a.AddressLine == "":
+			a.AddressLine = l.text("Line")
+		case a.AddressLine2 == "":
+			a.AddressLine2 = l.text("Line")
+		}
+	}
+
+	return a
+}
+
+func (c *ublConverter) paymentMeans(root *xmlNode) PaymentMeans {
+	pm := PaymentMeans{}
+	means := root.children("PaymentMeans")
+	if len(means) == 0 {
+		return pm
+	}
+
+	n := means[0]
+	pm.PaymentMeansCode = n.text("PaymentMeansCode")
+	pm.PaymentDueDate = n.text("PaymentDueDate")
+	pm.PaymentIdentifier.ID = n.text("PaymentID")
+	pm.PaymentIdentifier.SchemeID = n.child("PaymentID").attr("schemeID")
+
+	for _, account := range n.children("PayeeFinancialAccount") {
+		fa := FinancialAccountItem{}
+		fa.Ids = []ID{{
+			ID:       account.text("ID"),
+			SchemeID: account.child("ID").attr("schemeID"),
+		}}
+
+		branch := account.child("FinancialInstitutionBranch")
+		institution := branch.child("FinancialInstitution")
+		if institution != nil {
+			fa.FinancialInstitutionID = institution.text("ID")
+			fa.FinancialInstitutionIDSchemeID = institution.child("ID").attr("schemeID")
+			fa.FinancialInstitutionName = institution.text("Name")
+			fa.FinancialInstitutionBranchID = branch.text("ID")
+			fa.FinancialInstitutionBranchSchemeID = branch.child("ID").attr("schemeID")
+		} else {
+			// Peppol BIS puts the BIC directly on the branch
+			fa.FinancialInstitutionID = branch.text("ID")
+			fa.FinancialInstitutionIDSchemeID = branch.child("ID").attr("schemeID")
+			if fa.FinancialInstitutionID != "" && fa.FinancialInstitutionIDSchemeID == "" {
+				fa.FinancialInstitutionIDSchemeID = "BIC"
+			}
+		}
+		if fa.FinancialInstitutionName == "" {
+			fa.FinancialInstitutionName = branch.text("Name")
+		}
+
+		pm.FinancialAccount = append(pm.FinancialAccount, fa)
+	}
+
+	for _, n := range means[1:] {
+		c.warn(root.name()+"/PaymentMeans", "only one payment means is supported, dropped payment means %s", n.text("PaymentMeansCode"))
+		n.markUsed()
+	}
+
+	return pm
+}
+
+func (c *ublConverter) paymentTerms(n *xmlNode) PaymentTerms {
+	pt := PaymentTerms{}
+	if n == nil {
+		return pt
+	}
+
+	pt.Note = n.text("Note")
+	pt.PenaltySurchargePercent = c.decimal("PaymentTerms/PenaltySurchargePercent", n.text("PenaltySurchargePercent"))
+	pt.SettlementPeriod.StartDate = n.text("SettlementPeriod", "StartDate")
+	pt.SettlementPeriod.EndDate = n.text("SettlementPeriod", "EndDate")
+	return pt
+}
+
+// allowanceCharge maps the document level charges. Basware only knows freight
+// and handling charges.
+func (c *ublConverter) allowanceCharge(root *xmlNode) AllowanceCharge {
+	ac := AllowanceCharge{}
+	for _, n := range root.children("AllowanceCharge") {
+		charge := n.text("ChargeIndicator") == "true"
+		code := n.text("AllowanceChargeReasonCode")
+		reason := n.text("AllowanceChargeReason")
+		amount := n.text("Amount")
+		value := c.decimal(root.name()+"/AllowanceCharge/Amount", amount)
+		if !addCharge(&ac, charge, code, value) {
+			c.warn(root.name()+"/AllowanceCharge", "only freight and handling charges are supported, dropped %s (%s)", reason, amount)
+			n.markUsed()
+		}
+		// the category follows from the tax breakdown
+		n.child("TaxCategory").markUsed()
+	}
+	return ac
+}
+
+func (c *ublConverter) taxTotal(n *xmlNode) TaxTotal {
+	tt := TaxTotal{
+		Amount:     c.decimal("TaxTotal/TaxAmount", n.text("TaxAmount")),
+		CurrencyID: n.child("TaxAmount").attr("currencyID"),
+	}
+
+	for _, sub := range n.children("TaxSubtotal") {
+		tt.TaxSubTotal = append(tt.TaxSubTotal, c.taxSubTotal(sub))
+	}
+	return tt
+}
+
+func (c *ublConverter) taxSubTotal(n *xmlNode) TaxSubTotalItem {
+	sub := TaxSubTotalItem{
+		CurrencyID:    n.child("TaxAmount").attr("currencyID"),
+		Amount:        c.decimal("TaxSubtotal/TaxAmount", n.text("TaxAmount")),
+		TaxableAmount: c.decimal("TaxSubtotal/TaxableAmount", n.text("TaxableAmount")),
+		Percent:       c.decimal("TaxSubtotal/TaxCategory/Percent", n.text("TaxCategory", "Percent")),
+	}
+	sub.TaxCategoryID = taxCategory(n.child("TaxCategory", "ID"), sub.Percent)
+	sub.TaxExemptionReasonCode = n.text("TaxCategory", "TaxExemptionReasonCode")
+	sub.TaxExemptionReason = n.text("TaxCategory", "TaxExemptionReason")
+	sub.TaxSchemeID = taxScheme(n.child("TaxCategory", "TaxScheme", "ID"))
+	return sub
+}
+
+func (c *ublConverter) legalMonetaryTotal(n *xmlNode) LegalMonetaryTotal {
+	// the remaining totals are derived from the lines, charges and taxes
+	n.text("TaxExclusiveAmount")
+	n.text("TaxInclusiveAmount")
+	n.text("ChargeTotalAmount")
+
+	return LegalMonetaryTotal{
+		LineExtensionAmount: Amount{
+			Amount:     c.decimal("LegalMonetaryTotal/LineExtensionAmount", n.text("LineExtensionAmount")),
+			CurrencyID: n.child("LineExtensionAmount").attr("currencyID"),
+		},
+		PayableAmount: Amount{
+			Amount:     c.decimal("LegalMonetaryTotal/PayableAmount", n.text("PayableAmount")),
+			CurrencyID: n.child("PayableAmount").attr("currencyID"),
+		},
+	}
+}
+
+func (c *ublConverter) invoiceLine(n *xmlNode, quantityName string) InvoiceLine {
+	l := InvoiceLine{}
+	l.ID = n.text("ID")
+
+	l.Quantity.Amount = c.decimal(quantityName, n.text(quantityName))
+	l.Quantity.UnitCode = n.child(quantityName).attr("unitCode")
+
+	l.LineExtension.Amount = c.decimal("LineExtensionAmount", n.text("LineExtensionAmount"))
+	l.LineExtension.CurrencyID = n.child("LineExtensionAmount").attr("currencyID")
+
+	l.OrderLineReference.LineID = n.text("OrderLineReference", "LineID")
+	l.OrderLineReference.OrderReference = n.text("OrderLineReference", "OrderReference", "ID")
+	l.Delivery.ActualDeliveryDate = n.text("Delivery", "ActualDeliveryDate")
+
+	item := n.child("Item")
+	for _, d := range item.children("Description") {
+		d.used = true
+		l.Item.Description = append(l.Item.Description, DescriptionItem(strings.TrimSpace(d.Content)))
+	}
+	l.Item.Name = item.text("Name")
+	l.Item.SellersItem.ID = item.text("SellersItemIdentification", "ID")
+	l.Item.SellersItem.SchemeID = item.child("SellersItemIdentification", "ID").attr("schemeID")
+	l.Item.TaxPercent = c.decimal("Item/ClassifiedTaxCategory/Percent", item.text("ClassifiedTaxCategory", "Percent"))
+	l.Item.TaxCategoryID = taxCategory(item.child("ClassifiedTaxCategory", "ID"), l.Item.TaxPercent)
+	l.Item.TaxExemptionReasonCode = item.text("ClassifiedTaxCategory", "TaxExemptionReasonCode")
+	l.Item.TaxExemptionReason = item.text("ClassifiedTaxCategory", "TaxExemptionReason")
+	l.Item.TaxSchemeID = taxScheme(item.child("ClassifiedTaxCategory", "TaxScheme", "ID"))
+
+	l.Price.Amount = c.decimal("Price/PriceAmount", n.text("Price", "PriceAmount"))
+	l.Price.CurrencyID = n.child("Price", "PriceAmount").attr("currencyID")
+
+	for _, tt := range n.children("TaxTotal") {
+		l.TaxTotal = append(l.TaxTotal, lineTaxTotal(c.taxTotal(tt)))
+	}
+
+	return l
+}
+
+// Namespaces of UBL 2.1
+const (
+	ublNamespaceInvoice    = "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"
+	ublNamespaceCreditNote = "urn:oasis:names:specification:ubl:schema:xsd:CreditNote-2"
+	ublNamespaceCAC        = "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2"
+	ublNamespaceCBC        = "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2"
+)
+
+// MarshalUBL converts an invoice into an UBL 2.1 Invoice or CreditNote
+// following EN 16931. The warnings list the invoice fields that have no place
+// in UBL.
+func MarshalUBL(inv Invoice, docType DocumentType) ([]byte, ConversionWarnings, error) {
+	c := &ublConverter{}
+	root := c.document(inv, docType)
+	b, err := root.marshal()
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, c.warnings, nil
+}
+
+func (c *ublConverter) document(inv Invoice, docType DocumentType) *xmlNode {
+	name, namespace, typeCode, lineName, quantityName := "Invoice", ublNamespaceInvoice, "380", "InvoiceLine", "InvoicedQuantity"
+	if docType == DocumentTypeCreditNote {
+		name, namespace, typeCode, lineName, quantityName = "CreditNote", ublNamespaceCreditNote, "381", "CreditNoteLine", "CreditedQuantity"
+	}
+	currency := inv.DocumentCurrencyCode
+
+	root := newXMLNode(name)
+	root.Attrs = []xml.Attr{
+		{Name: xml.Name{Local: "xmlns"}, Value: namespace},
+		{Name: xml.Name{Local: "xmlns:cac"}, Value: ublNamespaceCAC},
+		{Name: xml.Name{Local: "xmlns:cbc"}, Value: ublNamespaceCBC},
+	}
+
+	root.add(
+		newXMLText("cbc:UBLVersionID", "2.1"),
+		newXMLText("cbc:CustomizationID", ciiGuidelineEN16931),
+		newXMLText("cbc:ID", inv.ID, "schemeID", inv.IDSchemeID),
+		newXMLText("cbc:IssueDate", inv.IssueDate),
+	)
+	// a credit note has its due date in the payment means
+	if docType != DocumentTypeCreditNote {
+		root.add(newXMLText("cbc:DueDate", inv.PaymentMeans.PaymentDueDate))
+	}
+	root.add(newXMLText("cbc:"+name+"TypeCode", typeCode))
+	for _, note := range splitNonEmpty(inv.Note) {
+		root.add(newXMLText("cbc:Note", note))
+	}
+	root.add(
+		newXMLText("cbc:DocumentCurrencyCode", currency),
+		newXMLText("cbc:BuyerReference", inv.BuyerReference.ID),
+		newXMLNode("cac:OrderReference",
+			newXMLText("cbc:ID", inv.OrderReference.ID, "schemeID", inv.OrderReference.SchemeID),
+			newXMLText("cbc:SalesOrderID", inv.OrderReference.SalesOrderID),
+			newXMLText("cbc:CustomerReference", inv.OrderReference.CustomerReference),
+		).group(),
+		newXMLNode("cac:BillingReference",
+			newXMLNode("cac:InvoiceDocumentReference",
+				newXMLText("cbc:ID", inv.BillingReference.ID, "schemeID", inv.BillingReference.SchemeID),
+			).group(),
+		).group(),
+		newXMLNode("cac:ContractDocumentReference",
+			newXMLText("cbc:ID", inv.ContractDocumentReference.ID, "schemeID", inv.ContractDocumentReference.SchemeID),
+		).group(),
+	)
+
+	ref := inv.AdditionalDocumentReference
+	if ref.ID != "" {
+		root.add(newXMLNode("cac:AdditionalDocumentReference",
+			newXMLText("cbc:ID", ref.ID, "schemeID", ref.SchemeID),
+			newXMLText("cbc:IssueDate", ref.IssueDate),
+			newXMLText("cbc:DocumentTypeCode", ref.TypeCode),
+		))
+	}
+
+	root.add(
+		newXMLNode("cac:AccountingSupplierParty", c.ublParty("cac:Party", "accountingSupplierParty", inv.AccountingSupplierParty.party())),
+		newXMLNode("cac:AccountingCustomerParty", c.ublParty("cac:Party", "accountingCustomerParty", inv.AccountingCustomerParty.party())),
+	)
+
+	delivery := newXMLNode("cac:Delivery", newXMLText("cbc:ActualDeliveryDate", inv.Delivery.ActualDeliveryDate))
+	if !inv.DeliveryParty.party().isZero() {
+		delivery.add(c.ublParty("cac:DeliveryParty", "deliveryParty", inv.DeliveryParty.party()))
+	}
+	root.add(delivery.group())
+
+	root.add(c.ublPaymentMeans(inv.PaymentMeans, docType))
+
+	terms := inv.PaymentTerms
+	penalty := ""
+	if terms.PenaltySurchargePercent != 0 {
+		penalty = formatDecimal(terms.PenaltySurchargePercent)
+	}
+	root.add(newXMLNode("cac:PaymentTerms",
+		newXMLText("cbc:Note", terms.Note),
+		newXMLText("cbc:PenaltySurchargePercent", penalty),
+		newXMLNode("cac:SettlementPeriod",
+			newXMLText("cbc:StartDate", terms.SettlementPeriod.StartDate),
+			newXMLText("cbc:EndDate", terms.SettlementPeriod.EndDate),
+		).group(),
+	).group())
+
+	chargeTotal := 0.0
+	charges := inv.AllowanceCharge.charges()
+	var chargeTax *xmlNode
+	if len(charges) > 0 {
+		if sub, ok := c.chargeTax("allowanceCharge", inv); ok {
+			chargeTax = newXMLNode("cac:TaxCategory",
+				newXMLText("cbc:ID", c.categoryCode("allowanceCharge", sub.TaxCategoryID, sub.Percent)),
+				newXMLText("cbc:Percent", formatDecimal(sub.Percent)),
+				newXMLNode("cac:TaxScheme", newXMLText("cbc:ID", sub.taxScheme())),
+			)
+		}
+	}
+	for _, ch := range charges {
+		chargeTotal = chargeTotal + ch.Amount
+		root.add(newXMLNode("cac:AllowanceCharge",
+			newXMLText("cbc:ChargeIndicator", "true"),
+			newXMLText("cbc:AllowanceChargeReasonCode", ch.ReasonCode),
+			newXMLText("cbc:AllowanceChargeReason", ch.Reason),
+			newXMLText("cbc:Amount", formatAmount(ch.Amount), "currencyID", currency),
+			chargeTax,
+		))
+	}
+
+	taxCurrency := inv.TaxTotal.CurrencyID
+	if taxCurrency == "" {
+		taxCurrency = currency
+	}
+	taxTotal := newXMLNode("cac:TaxTotal", newXMLText("cbc:TaxAmount", formatAmount(inv.TaxTotal.Amount), "currencyID", taxCurrency))
+	for i, sub := range inv.TaxTotal.TaxSubTotal {
+		taxTotal.add(c.ublTaxSubtotal(fmt.Sprintf("taxTotal.taxSubTotal[%d]", i), sub, currency))
+	}
+	root.add(taxTotal)
+
+	lineTotal := inv.LegalMonetaryTotal.LineExtensionAmount.Amount
+	taxExclusive := lineTotal + chargeTotal
+	chargeTotalAmount := ""
+	if len(charges) > 0 {
+		chargeTotalAmount = formatAmount(chargeTotal)
+	}
+	root.add(newXMLNode("cac:LegalMonetaryTotal",
+		newXMLText("cbc:LineExtensionAmount", formatAmount(lineTotal), "currencyID", currency),
+		newXMLText("cbc:TaxExclusiveAmount", formatAmount(taxExclusive), "currencyID", currency),
+		newXMLText("cbc:TaxInclusiveAmount", formatAmount(taxExclusive+inv.TaxTotal.Amount), "currencyID", currency),
+		newXMLText("cbc:ChargeTotalAmount", chargeTotalAmount, "currencyID", currency),
+		newXMLText("cbc:PayableAmount", formatAmount(inv.LegalMonetaryTotal.PayableAmount.Amount), "currencyID", currency),
+	))
+
+	for i, l := range inv.InvoiceLine {
+		root.add(c.ublLine(fmt.Sprintf("invoiceLine[%d]", i), "cac:"+lineName, "cbc:"+quantityName, l, currency))
+	}
+	return root
+}
+
+func (c *ublConverter) ublParty(name string, path string, p party) *xmlNode {
+	n := newXMLNode(name, newXMLText("cbc:EndpointID", p.Endpoint.ID, "schemeID", p.Endpoint.SchemeID))
+	for _, id := range p.PartyIdentification {
+		n.add(newXMLNode("cac:PartyIdentification", newXMLText("cbc:ID", id.ID, "schemeID", id.SchemeID)))
+	}
+	n.add(newXMLNode("cac:PartyName", newXMLText("cbc:Name", p.PartyName)).group())
+
+	a := p.PostalAddress
+	n.add(newXMLNode("cac:PostalAddress",
+		newXMLText("cbc:StreetName", a.AddressLine),
+		newXMLText("cbc:AdditionalStreetName", a.AddressLine2),
+		newXMLText("cbc:CityName", a.CityName),
+		newXMLText("cbc:PostalZone", a.PostalZone),
+		newXMLText("cbc:CountrySubentity", a.CountrySubentity),
+		newXMLText("cbc:District", a.Locality),
+		newXMLNode("cac:Country", newXMLText("cbc:IdentificationCode", a.CountryID)).group(),
+	).group())
+
+	for _, ts := range append([]PartyTaxScheme{p.PartyTaxScheme}, p.AdditionalPartyTaxSchemes...) {
+		if ts.Company.ID == "" {
+			continue
+		}
+		scheme := ts.Company.SchemeID
+		if scheme == "" || scheme == "VA" {
+			scheme = TaxSchemeVAT
+		}
+		n.add(newXMLNode("cac:PartyTaxScheme",
+			newXMLText("cbc:CompanyID", ts.Company.ID),
+			newXMLNode("cac:TaxScheme", newXMLText("cbc:ID", scheme)),
+		))
+	}
+
+	for _, le := range p.PartyLegalEntities {
+		n.add(newXMLNode("cac:PartyLegalEntity",
+			newXMLText("cbc:RegistrationName", le.RegistrationName),
+			newXMLText("cbc:CompanyID", le.CompanyID, "schemeID", le.SchemeID),
+		).group())
+	}
+
+	n.add(newXMLNode("cac:Contact",
+		newXMLText("cbc:Name", p.Contact.Name),
+		newXMLText("cbc:Telephone", p.Contact.Telephone),
+		newXMLText("cbc:Telefax", p.Contact.Telefax),
+		newXMLText("cbc:ElectronicMail", p.Contact.ElectronicMail),
+	).group())
+	return n
+}
+
+func (c *ublConverter) ublPaymentMeans(pm PaymentMeans, docType DocumentType) *xmlNode {
+	dueDate := ""
+	if docType == DocumentTypeCreditNote {
+		dueDate = pm.PaymentDueDate
+	}
+	if pm.PaymentMeansCode == "" {
+		if dueDate != "" {
+			c.warn("paymentMeans.paymentDueDate", "a credit note has its due date in the payment means, which has no code, dropped \"%s\"", dueDate)
+		}
+		if len(pm.FinancialAccount) > 0 || pm.PaymentIdentifier.ID != "" {
+			c.warn("paymentMeans.paymentMeansCode", "payment means without code can't be written to UBL and was dropped")
+		}
+		return nil
+	}
+
+	n := newXMLNode("cac:PaymentMeans",
+		newXMLText("cbc:PaymentMeansCode", pm.PaymentMeansCode),
+		newXMLText("cbc:PaymentDueDate", dueDate),
+		newXMLText("cbc:PaymentID", pm.PaymentIdentifier.ID, "schemeID", pm.PaymentIdentifier.SchemeID),
+	)
+
+	for i, fa := range pm.FinancialAccount {
+		path := fmt.Sprintf("paymentMeans.financialAccount[%d]", i)
+		if i > 0 {
+			c.warn(path, "UBL has a single payee account per payment means, dropped \"%s\"", fa.accountID().ID)
+			continue
+		}
+		if fa.Accounting.VirtualBankBarcode.VirtualBankBarCode != "" {
+			c.warn(path+".accounting.virtualBankBarcode", "UBL has no virtual bank barcode, dropped \"%s\"", fa.Accounting.VirtualBankBarcode.VirtualBankBarCode)
+		}
+
+		id := fa.accountID()
+		account := newXMLNode("cac:PayeeFinancialAccount", newXMLText("cbc:ID", id.ID, "schemeID", id.SchemeID))
+		isBIC := fa.FinancialInstitutionIDSchemeID == "" || fa.FinancialInstitutionIDSchemeID == "BIC"
+		if isBIC && fa.FinancialInstitutionBranchID == "" {
+			// Peppol BIS puts the BIC directly on the branch
+			account.add(newXMLNode("cac:FinancialInstitutionBranch",
+				newXMLText("cbc:ID", fa.FinancialInstitutionID),
+				newXMLText("cbc:Name", fa.FinancialInstitutionName),
+			).group())
+		} else {
+			account.add(newXMLNode("cac:FinancialInstitutionBranch",
+				newXMLText("cbc:ID", fa.FinancialInstitutionBranchID, "schemeID", fa.FinancialInstitutionBranchSchemeID),
+				newXMLNode("cac:FinancialInstitution",
+					newXMLText("cbc:ID", fa.FinancialInstitutionID, "schemeID", fa.FinancialInstitutionIDSchemeID),
+					newXMLText("cbc:Name", fa.FinancialInstitutionName),
+				).group(),
+			).group())
+		}
+		n.add(account)
+	}
+	return n
+}
+
+func (c *ublConverter) ublTaxSubtotal(path string, sub TaxSubTotalItem, currency string) *xmlNode {
+	if sub.CurrencyID != "" {
+		currency = sub.CurrencyID
+	}
+	return newXMLNode("cac:TaxSubtotal",
+		newXMLText("cbc:TaxableAmount", formatAmount(sub.TaxableAmount), "currencyID", currency),
+		newXMLText("cbc:TaxAmount", formatAmount(sub.Amount), "currencyID", currency),
+		newXMLNode("cac:TaxCategory",
+			newXMLText("cbc:ID", c.categoryCode(path, sub.TaxCategoryID, sub.Percent)),
+			newXMLText("cbc:Percent", formatDecimal(sub.Percent)),
+			newXMLText("cbc:TaxExemptionReasonCode", sub.TaxExemptionReasonCode),
+			newXMLText("cbc:TaxExemptionReason", sub.TaxExemptionReason),
+			newXMLNode("cac:TaxScheme", newXMLText("cbc:ID", sub.taxScheme())),
+		),
+	)
+}
+
+func (c *ublConverter) ublLine(path string, name string, quantityName string, l InvoiceLine, currency string) *xmlNode {
+	if l.InternalID != "" {
+		c.warn(path+".internalId", "UBL has no internal line identifier, dropped \"%s\"", l.InternalID)
+	}
+	if l.Quantity.AmountUninvoiced != 0 {
+		c.warn(path+".quantity.amountUninvoiced", "UBL has no uninvoiced quantity, dropped %s", formatDecimal(l.Quantity.AmountUninvoiced))
+	}
+	if l.ServiceIndicator {
+		c.warn(path+".serviceIndicator", "UBL has no service indicator, dropped")
+	}
+	if l.AllowanceCharge != nil && *l.AllowanceCharge != (AllowanceCharge{}) {
+		c.warn(path+".allowanceCharge", "line level charges are not converted to UBL")
+	}
+
+	lineCurrency := l.LineExtension.CurrencyID
+	if lineCurrency == "" {
+		lineCurrency = currency
+	}
+	n := newXMLNode(name,
+		newXMLText("cbc:ID", l.ID),
+		newXMLText(quantityName, formatDecimal(l.Quantity.Amount), "unitCode", l.Quantity.UnitCode),
+		newXMLText("cbc:LineExtensionAmount", formatAmount(l.LineExtension.Amount), "currencyID", lineCurrency),
+		newXMLNode("cac:OrderLineReference",
+			newXMLText("cbc:LineID", l.OrderLineReference.LineID),
+			newXMLNode("cac:OrderReference", newXMLText("cbc:ID", l.OrderLineReference.OrderReference)).group(),
+		).group(),
+		newXMLNode("cac:Delivery", newXMLText("cbc:ActualDeliveryDate", l.Delivery.ActualDeliveryDate)).group(),
+	)
+
+	for i, t := range l.TaxTotal {
+		taxTotal := newXMLNode("cac:TaxTotal", newXMLText("cbc:TaxAmount", formatAmount(t.Amount), "currencyID", t.CurrencyID))
+		for j, sub := range t.TaxSubTotal {
+			taxTotal.add(c.ublTaxSubtotal(fmt.Sprintf("%s.taxTotal[%d].taxSubTotal[%d]", path, i, j), sub, t.CurrencyID))
+		}
+		n.add(taxTotal)
+	}
+
+	item := newXMLNode("cac:Item")
+	for _, d := range l.Item.Description {
+		item.add(newXMLText("cbc:Description", string(d)))
+	}
+	item.add(
+		newXMLText("cbc:Name", l.Item.Name),
+		newXMLNode("cac:SellersItemIdentification",
+			newXMLText("cbc:ID", l.Item.SellersItem.ID, "schemeID", l.Item.SellersItem.SchemeID),
+		).group(),
+		newXMLNode("cac:ClassifiedTaxCategory",
+			newXMLText("cbc:ID", c.categoryCode(path+".item.taxPercent", l.Item.TaxCategoryID, l.Item.TaxPercent)),
+			newXMLText("cbc:Percent", formatDecimal(l.Item.TaxPercent)),
+			newXMLText("cbc:TaxExemptionReasonCode", l.Item.TaxExemptionReasonCode),
+			newXMLText("cbc:TaxExemptionReason", l.Item.TaxExemptionReason),
+			newXMLNode("cac:TaxScheme", newXMLText("cbc:ID", l.Item.taxScheme())),
+		),
+	)
+	n.add(item)
+
+	priceCurrency := l.Price.CurrencyID
+	if priceCurrency == "" {
+		priceCurrency = lineCurrency
+	}
+	n.add(newXMLNode("cac:Price", newXMLText("cbc:PriceAmount", formatDecimal(l.Price.Amount), "currencyID", priceCurrency)))
+	return n
+}
@@ -0,0 +1,193 @@
+package basware_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+var ublInvoice = `<?xml version="1.0" encoding="UTF-8"?>
+<Invoice xmlns="urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"
+	xmlns:cac="urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2"
+	xmlns:cbc="urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2">
+	<cbc:CustomizationID>urn:cen.eu:en16931:2017</cbc:CustomizationID>
+	<cbc:ID>INV-1001</cbc:ID>
+	<cbc:IssueDate>2017-09-01</cbc:IssueDate>
+	<cbc:DueDate>2017-09-30</cbc:DueDate>
+	<cbc:InvoiceTypeCode>380</cbc:InvoiceTypeCode>
+	<cbc:Note>Thank you</cbc:Note>
+	<cbc:TaxPointDate>2017-09-01</cbc:TaxPointDate>
+	<cbc:DocumentCurrencyCode>EUR</cbc:DocumentCurrencyCode>
+	<cbc:BuyerReference>REF-7</cbc:BuyerReference>
+	<cac:OrderReference><cbc:ID>PO-12</cbc:ID></cac:OrderReference>
+	<cac:AccountingSupplierParty>
+		<cac:Party>
+			<cbc:EndpointID schemeID="0088">7300010000001</cbc:EndpointID>
+			<cac:PartyName><cbc:Name>Supplier Oy</cbc:Name></cac:PartyName>
+			<cac:PostalAddress>
+				<cbc:StreetName>Main street 1</cbc:StreetName>
+				<cbc:CityName>Helsinki</cbc:CityName>
+				<cbc:PostalZone>00100</cbc:PostalZone>
+				<cac:Country><cbc:IdentificationCode>FI</cbc:IdentificationCode></cac:Country>
+			</cac:PostalAddress>
+			<cac:PartyTaxScheme>
+				<cbc:CompanyID>FI12345671</cbc:CompanyID>
+				<cac:TaxScheme><cbc:ID>VAT</cbc:ID></cac:TaxScheme>
+			</cac:PartyTaxScheme>
+		</cac:Party>
+	</cac:AccountingSupplierParty>
+	<cac:AccountingCustomerParty>
+		<cac:Party>
+			<cac:PostalAddress>
+				<cbc:StreetName>Storgatan 2</cbc:StreetName>
+				<cbc:CityName>Stockholm</cbc:CityName>
+				<cbc:PostalZone>111 22</cbc:PostalZone>
+				<cac:Country><cbc:IdentificationCode>SE</cbc:IdentificationCode></cac:Country>
+			</cac:PostalAddress>
+			<cac:PartyLegalEntity><cbc:RegistrationName>Buyer AB</cbc:RegistrationName></cac:PartyLegalEntity>
+		</cac:Party>
+	</cac:AccountingCustomerParty>
+	<cac:PaymentMeans>
+		<cbc:PaymentMeansCode>58</cbc:PaymentMeansCode>
+		<cbc:PaymentID>RF18539007547034</cbc:PaymentID>
+		<cac:PayeeFinancialAccount>
+			<cbc:ID>FI2112345600000785</cbc:ID>
+			<cac:FinancialInstitutionBranch><cbc:ID>NDEAFIHH</cbc:ID></cac:FinancialInstitutionBranch>
+		</cac:PayeeFinancialAccount>
+	</cac:PaymentMeans>
+	<cac:TaxTotal>
+		<cbc:TaxAmount currencyID="EUR">24.00</cbc:TaxAmount>
+		<cac:TaxSubtotal>
+			<cbc:TaxableAmount currencyID="EUR">100.00</cbc:TaxableAmount>
+			<cbc:TaxAmount currencyID="EUR">24.00</cbc:TaxAmount>
+			<cac:TaxCategory><cbc:Percent>24</cbc:Percent></cac:TaxCategory>
+		</cac:TaxSubtotal>
+	</cac:TaxTotal>
+	<cac:LegalMonetaryTotal>
+		<cbc:LineExtensionAmount currencyID="EUR">100.00</cbc:LineExtensionAmount>
+		<cbc:PayableAmount currencyID="EUR">124.00</cbc:PayableAmount>
+	</cac:LegalMonetaryTotal>
+	<cac:InvoiceLine>
+		<cbc:ID>1</cbc:ID>
+		<cbc:InvoicedQuantity unitCode="EA">2</cbc:InvoicedQuantity>
+		<cbc:LineExtensionAmount currencyID="EUR">100.00</cbc:LineExtensionAmount>
+		<cac:Item>
+			<cbc:Name>Widget</cbc:Name>
+			<cac:ClassifiedTaxCategory><cbc:Percent>24</cbc:Percent></cac:ClassifiedTaxCategory>
+		</cac:Item>
+		<cac:Price><cbc:PriceAmount currencyID="EUR">50.00</cbc:PriceAmount></cac:Price>
+	</cac:InvoiceLine>
+</Invoice>`
+
+func TestParseUBL(t *testing.T) {
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Type != basware.DocumentTypeInvoice {
+		t.Errorf("expected Invoice, got %s", doc.Type)
+	}
+
+	inv := doc.Invoice
+	if inv.ID != "INV-1001" || inv.IssueDate != "2017-09-01" || inv.DocumentCurrencyCode != "EUR" {
+		t.Errorf("unexpected header: %+v", inv)
+	}
+	if inv.PaymentMeans.PaymentDueDate != "2017-09-30" {
+		t.Errorf("expected due date from document, got %s", inv.PaymentMeans.PaymentDueDate)
+	}
+	if inv.AccountingSupplierParty.Endpoint.SchemeID != "0088" {
+		t.Errorf("unexpected supplier endpoint: %+v", inv.AccountingSupplierParty.Endpoint)
+	}
+	if inv.AccountingSupplierParty.PartyTaxScheme.Company.SchemeID != "VAT" {
+		t.Errorf("unexpected supplier tax scheme: %+v", inv.AccountingSupplierParty.PartyTaxScheme)
+	}
+	if inv.AccountingCustomerParty.PartyName != "Buyer AB" {
+		t.Errorf("expected customer name from legal entity, got %s", inv.AccountingCustomerParty.PartyName)
+	}
+	if fa := inv.PaymentMeans.FinancialAccount; len(fa) != 1 || fa[0].FinancialInstitutionID != "NDEAFIHH" {
+		t.Errorf("unexpected financial account: %+v", fa)
+	}
+	if inv.LegalMonetaryTotal.PayableAmount.Amount != 124 {
+		t.Errorf("unexpected payable amount: %v", inv.LegalMonetaryTotal.PayableAmount)
+	}
+	if len(inv.InvoiceLine) != 1 || inv.InvoiceLine[0].Quantity.UnitCode != "EA" || inv.InvoiceLine[0].Item.TaxPercent != 24 {
+		t.Errorf("unexpected invoice lines: %+v", inv.InvoiceLine)
+	}
+
+	if len(doc.Warnings) != 1 || doc.Warnings[0].Path != "Invoice/TaxPointDate" {
+		t.Errorf("expected a warning for TaxPointDate, got:\n%s", doc.Warnings)
+	}
+}
+
+func TestParseUBLFinancialAccounts(t *testing.T) {
+	branch := "<cac:FinancialInstitutionBranch><cbc:ID>NDEAFIHH</cbc:ID></cac:FinancialInstitutionBranch>"
+	tests := []struct {
+		branch   string
+		expected basware.FinancialAccountItem
+	}{
+		{
+			`<cac:FinancialInstitutionBranch><cbc:ID schemeID="SWIFT">NDEAFIHH</cbc:ID><cbc:Name>Nordea</cbc:Name></cac:FinancialInstitutionBranch>`,
+			basware.FinancialAccountItem{FinancialInstitutionID: "NDEAFIHH", FinancialInstitutionIDSchemeID: "SWIFT", FinancialInstitutionName: "Nordea"},
+		},
+		{
+			`<cac:FinancialInstitutionBranch><cbc:ID>001</cbc:ID><cbc:Name>Helsinki</cbc:Name><cac:FinancialInstitution><cbc:ID>NDEAFIHH</cbc:ID></cac:FinancialInstitution></cac:FinancialInstitutionBranch>`,
+			basware.FinancialAccountItem{FinancialInstitutionID: "NDEAFIHH", FinancialInstitutionName: "Helsinki", FinancialInstitutionBranchID: "001"},
+		},
+	}
+	for _, test := range tests {
+		doc, err := basware.ParseUBL(strings.NewReader(strings.Replace(ublInvoice, branch, test.branch, 1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := test.expected
+		expected.Ids = []basware.ID{{ID: "FI2112345600000785"}}
+		if fa := doc.Invoice.PaymentMeans.FinancialAccount; len(fa) != 1 || !reflect.DeepEqual(fa[0], expected) {
+			t.Errorf("expected %+v, got %+v", expected, fa)
+		}
+	}
+}
+
+func TestParseUBLUnknownRoot(t *testing.T) {
+	_, err := basware.ParseUBL(strings.NewReader(`<Order/>`))
+	if err == nil {
+		t.Error("expected an error for a non invoice document")
+	}
+}
+
+func TestMarshalUBLRoundTrip(t *testing.T) {
+	doc, err := basware.ParseUBL(strings.NewReader(ublInvoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, docType := range []basware.DocumentType{basware.DocumentTypeInvoice, basware.DocumentTypeCreditNote} {
+		b, warnings, err := basware.MarshalUBL(doc.Invoice, docType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(warnings) != 0 {
+			t.Errorf("unexpected warnings:\n%s", warnings)
+		}
+		if !bytes.Contains(b, []byte(`<cbc:TaxInclusiveAmount currencyID="EUR">124.00</cbc:TaxInclusiveAmount>`)) {
+			t.Errorf("expected the derived totals:\n%s", b)
+		}
+
+		ubl, err := basware.ParseUBL(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ubl.Type != docType {
+			t.Errorf("expected %s, got %s", docType, ubl.Type)
+		}
+		if len(ubl.Warnings) != 0 {
+			t.Errorf("unexpected warnings:\n%s", ubl.Warnings)
+		}
+		if !reflect.DeepEqual(ubl.Invoice, doc.Invoice) {
+			t.Errorf("%s changed:\n%+v\n%+v", docType, ubl.Invoice, doc.Invoice)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package basware
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var virtualBarcodeFormat = regexp.MustCompile(`^[45][0-9]{53}$`)
+
+// VirtualBarcode holds the payment details of a Finnish virtual bank barcode
+// (virtuaaliviivakoodi). Version 4 carries a Finnish reference number, version
+// 5 an RF creditor reference.
+type VirtualBarcode struct {
+	// Finnish IBAN of the payee
+	IBAN string
+
+	// Amount in euros; zero when the payer fills it in
+	Amount float64
+
+	// Finnish reference number or RF creditor reference
+	Reference string
+
+	// Zero when there's no due date
+	DueDate time.Time
+}
+
+// NewVirtualBarcode collects the barcode details from the payment means and
+// payable amount of the invoice: the first Finnish IBAN, the payment reference
+// and the due date
+func NewVirtualBarcode(inv Invoice) (VirtualBarcode, error) {
+	b := VirtualBarcode{Reference: inv.PaymentMeans.PaymentIdentifier.ID}
+	for _, fa := range inv.PaymentMeans.FinancialAccount {
+		if iban := NormalizeIBAN(fa.accountID().ID); strings.HasPrefix(iban, "FI") {
+			b.IBAN = iban
+			break
+		}
+	}
+	if b.IBAN == "" {
+		return b, fmt.Errorf("Expected a Finnish IBAN in the financial accounts")
+	}
+
+	payable := inv.LegalMonetaryTotal.PayableAmount
+	if payable.CurrencyID != "" && payable.CurrencyID != "EUR" {
+		return b, fmt.Errorf("Expected a payable amount in EUR, got %s", payable.CurrencyID)
+	}
+	b.Amount = payable.Amount
+
+	if due := inv.PaymentMeans.PaymentDueDate; due != "" {
+		if len(due) < 10 {
+			return b, fmt.Errorf("Expected a due date in format CCYY-MM-DD, got \"%s\"", due)
+		}
+		date, err := time.Parse("2006-01-02", due[:10])
+		if err != nil {
+			return b, err
+		}
+		b.DueDate = date
+	}
+	return b, nil
+}
+
+// Encode returns the 54 digits of the barcode. Amounts over 999999.99 euros
+// and due dates beyond 2099 are left out as the barcode has no room for them.
+func (b VirtualBarcode) Encode() (string, error) {
+	iban := NormalizeIBAN(b.IBAN)
+	if err := ValidateIBAN(iban); err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(iban, "FI") {
+		return "", fmt.Errorf("Expected a Finnish IBAN, got \"%s\"", b.IBAN)
+	}
+
+	cents := int64(math.Round(b.Amount * 100))
+	if cents < 0 {
+		return "", fmt.Errorf("Expected a positive amount, got %s", formatDecimal(b.Amount))
+	}
+	if cents > 99999999 {
+		cents = 0
+	}
+
+	date := "000000"
+	if !b.DueDate.IsZero() && b.DueDate.Year() >= 2000 && b.DueDate.Year() < 2100 {
+		date = b.DueDate.Format("060102")
+	}
+
+	ref := strings.ToUpper(strings.Replace(b.Reference, " ", "", -1))
+	var version, reference string
+	if strings.HasPrefix(ref, "RF") {
+		if err := ValidatePaymentReference(PaymentReferenceRF, ref); err != nil {
+			return "", err
+		}
+		if !numericReference.MatchString(ref[4:]) {
+			return "", fmt.Errorf("Expected an RF reference of digits, got \"%s\"", b.Reference)
+		}
+		version, reference = "5", ref[2:4]+fmt.Sprintf("%021s", ref[4:])
+	} else {
+		if err := ValidatePaymentReference(PaymentReferenceFI, ref); err != nil {
+			return "", err
+		}
+		version, reference = "4", "000"+fmt.Sprintf("%020s", ref)
+	}
+
+	return fmt.Sprintf("%s%s%08d%s%s", version, iban[2:], cents, reference, date), nil
+}
+
+// DecodeVirtualBarcode parses the 54 digits of a version 4 or 5 Finnish
+// virtual bank barcode
+func DecodeVirtualBarcode(code string) (VirtualBarcode, error) {
+	b := VirtualBarcode{}
+	if !virtualBarcodeFormat.MatchString(code) {
+		return b, fmt.Errorf("Expected a version 4 or 5 virtual bank barcode of 54 digits, got \"%s\"", code)
+	}
+
+	b.IBAN = "FI" + code[1:17]
+	if err := ValidateIBAN(b.IBAN); err != nil {
+		return b, err
+	}
+
+	cents, _ := strconv.Atoi(code[17:25])
+	b.Amount = float64(cents) / 100
+
+	if code[0] == '4' {
+		b.Reference = strings.TrimLeft(code[28:48], "0")
+		if err := ValidatePaymentReference(PaymentReferenceFI, b.Reference); err != nil {
+			return b, err
+		}
+	} else {
+		b.Reference = "RF" + code[25:27] + strings.TrimLeft(code[27:48], "0")
+		if err := ValidatePaymentReference(PaymentReferenceRF, b.Reference); err != nil {
+			return b, err
+		}
+	}
+
+	if code[48:] != "000000" {
+		date, err := time.Parse("060102", code[48:])
+		if err != nil {
+			return b, fmt.Errorf("Invalid due date \"%s\" in virtual bank barcode", code[48:])
+		}
+		b.DueDate = date
+	}
+	return b, nil
+}
+
+// SetVirtualBankBarcode adds the virtual bank barcode of the invoice to the
+// financial account with the Finnish IBAN
+func (inv *Invoice) SetVirtualBankBarcode() error {
+	b, err := NewVirtualBarcode(*inv)
+	if err != nil {
+		return err
+	}
+	code, err := b.Encode()
+	if err != nil {
+		return err
+	}
+
+	for i, fa := range inv.PaymentMeans.FinancialAccount {
+		if NormalizeIBAN(fa.accountID().ID) == b.IBAN {
+			inv.PaymentMeans.FinancialAccount[i].Accounting.VirtualBankBarcode = VirtualBankBarcode{
+				VirtualBankBarCode:            code,
+				SchemeIDForVirtualBankBarCode: "FI",
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// ValidateVirtualBankBarcode checks that the virtual bank barcodes of the
+// financial accounts match the account, payable amount, payment reference and
+// due date of the invoice
+func ValidateVirtualBankBarcode(inv Invoice) error {
+	for _, fa := range inv.PaymentMeans.FinancialAccount {
+		if err := checkVirtualBankBarcode(inv, fa); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkVirtualBankBarcode(inv Invoice, fa FinancialAccountItem) error {
+	code := fa.Accounting.VirtualBankBarcode.VirtualBankBarCode
+	if code == "" {
+		return nil
+	}
+	if _, err := DecodeVirtualBarcode(code); err != nil {
+		return err
+	}
+
+	b, err := NewVirtualBarcode(inv)
+	if err != nil {
+		return err
+	}
+	b.IBAN = NormalizeIBAN(fa.accountID().ID)
+	expected, err := b.Encode()
+	if err != nil {
+		return err
+	}
+	if code != expected {
+		return fmt.Errorf("Expected virtual bank barcode %s, got %s", expected, code)
+	}
+	return nil
+}
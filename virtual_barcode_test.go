@@ -0,0 +1,89 @@
+package basware_test
+
+import (
+	"testing"
+	"time"
+
+	basware "github.com/tim-online/go-basware"
+)
+
+// examples of the bank barcode guide of Finance Finland
+var virtualBarcodeExamples = []struct {
+	barcode basware.VirtualBarcode
+	code    string
+}{
+	{
+		basware.VirtualBarcode{IBAN: "FI79 4405 2020 0360 82", Amount: 4883.15, Reference: "86851 62596 19897", DueDate: time.Date(2010, 6, 12, 0, 0, 0, 0, time.UTC)},
+		"479440520200360820048831500000000868516259619897100612",
+	},
+	{
+		basware.VirtualBarcode{IBAN: "FI58 1017 1000 0001 22", Amount: 482.99, Reference: "55958 22432 94671", DueDate: time.Date(2012, 1, 31, 0, 0, 0, 0, time.UTC)},
+		"458101710000001220004829900000000559582243294671120131",
+	},
+	{
+		basware.VirtualBarcode{IBAN: "FI79 4405 2020 0360 82", Amount: 4883.15, Reference: "RF09 8685 1625 9619 897", DueDate: time.Date(2010, 6, 12, 0, 0, 0, 0, time.UTC)},
+		"579440520200360820048831509000000868516259619897100612",
+	},
+	{
+		basware.VirtualBarcode{IBAN: "FI58 1017 1000 0001 22", Amount: 482.99, Reference: "RF06 5595 8224 3294 671", DueDate: time.Date(2012, 1, 31, 0, 0, 0, 0, time.UTC)},
+		"558101710000001220004829906000000559582243294671120131",
+	},
+}
+
+func TestVirtualBarcodeEncode(t *testing.T) {
+	for _, example := range virtualBarcodeExamples {
+		code, err := example.barcode.Encode()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if code != example.code {
+			t.Errorf("expected %s, got %s", example.code, code)
+		}
+	}
+}
+
+func TestDecodeVirtualBarcode(t *testing.T) {
+	for _, example := range virtualBarcodeExamples {
+		b, err := basware.DecodeVirtualBarcode(example.code)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if b.IBAN != basware.NormalizeIBAN(example.barcode.IBAN) || b.Amount != example.barcode.Amount || !b.DueDate.Equal(example.barcode.DueDate) {
+			t.Errorf("unexpected barcode %+v", b)
+		}
+
+		code, err := b.Encode()
+		if err != nil || code != example.code {
+			t.Errorf("expected round trip to %s, got %s (%v)", example.code, code, err)
+		}
+	}
+
+	if _, err := basware.DecodeVirtualBarcode("479440520200360820048831500000000868516259619898100612"); err == nil {
+		t.Error("expected an error for an invalid reference")
+	}
+}
+
+func TestSetVirtualBankBarcode(t *testing.T) {
+	inv := basware.Invoice{}
+	inv.PaymentMeans.PaymentDueDate = "2010-06-12"
+	inv.PaymentMeans.PaymentIdentifier.ID = "868516259619897"
+	inv.PaymentMeans.FinancialAccount = []basware.FinancialAccountItem{{Ids: []basware.ID{{ID: "FI7944052020036082", SchemeID: "IBAN"}}}}
+	inv.LegalMonetaryTotal.PayableAmount = basware.Amount{Amount: 4883.15, CurrencyID: "EUR"}
+
+	if err := inv.SetVirtualBankBarcode(); err != nil {
+		t.Fatal(err)
+	}
+	if code := inv.PaymentMeans.FinancialAccount[0].Accounting.VirtualBankBarcode.VirtualBankBarCode; code != virtualBarcodeExamples[0].code {
+		t.Errorf("expected %s, got %s", virtualBarcodeExamples[0].code, code)
+	}
+	if err := basware.ValidateVirtualBankBarcode(inv); err != nil {
+		t.Error(err)
+	}
+
+	inv.LegalMonetaryTotal.PayableAmount.Amount = 4883.16
+	if basware.ValidateVirtualBankBarcode(inv) == nil {
+		t.Error("expected an error for a changed amount")
+	}
+}
@@ -0,0 +1,201 @@
+package basware
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xmlNode is a generic representation of an XML element. It is used by the
+// document converters so every element can be visited regardless of the
+// namespace prefixes used by the sender. Nodes keep track of whether they have
+// been read so the elements without a Basware equivalent can be reported.
+type xmlNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Content  string     `xml:",chardata"`
+	Children []*xmlNode `xml:",any"`
+
+	used bool
+}
+
+func parseXMLNode(r io.Reader) (*xmlNode, error) {
+	root := &xmlNode{}
+	err := xml.NewDecoder(r).Decode(root)
+	if err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// name returns the local name of the element
+func (n *xmlNode) name() string {
+	return n.XMLName.Local
+}
+
+// child returns the first descendant matching the path of local names or nil
+// if it doesn't exist
+func (n *xmlNode) child(path ...string) *xmlNode {
+	if n == nil {
+		return nil
+	}
+	if len(path) == 0 {
+		return n
+	}
+
+	for _, c := range n.Children {
+		if c.name() == path[0] {
+			return c.child(path[1:]...)
+		}
+	}
+	return nil
+}
+
+// children returns all direct children with the specified local name
+func (n *xmlNode) children(name string) []*xmlNode {
+	if n == nil {
+		return nil
+	}
+
+	nodes := []*xmlNode{}
+	for _, c := range n.Children {
+		if c.name() == name {
+			nodes = append(nodes, c)
+		}
+	}
+	return nodes
+}
+
+// text returns the trimmed content of the descendant matching path and marks
+// it as used
+func (n *xmlNode) text(path ...string) string {
+	c := n.child(path...)
+	if c == nil {
+		return ""
+	}
+	c.used = true
+	return strings.TrimSpace(c.Content)
+}
+
+// attr returns the value of the attribute with the specified local name
+func (n *xmlNode) attr(name string) string {
+	if n == nil {
+		return ""
+	}
+
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// markUsed marks the node and all of its descendants as used
+func (n *xmlNode) markUsed() {
+	if n == nil {
+		return
+	}
+
+	n.used = true
+	for _, c := range n.Children {
+		c.markUsed()
+	}
+}
+
+// unused returns the paths of all leaf elements with content that haven't
+// been read
+func (n *xmlNode) unused() []string {
+	paths := []string{}
+	n.walkUnused(n.name(), &paths)
+	return paths
+}
+
+func (n *xmlNode) walkUnused(path string, paths *[]string) {
+	if len(n.Children) == 0 {
+		if !n.used && strings.TrimSpace(n.Content) != "" {
+			*paths = append(*paths, path)
+		}
+		return
+	}
+
+	counts := map[string]int{}
+	for _, c := range n.Children {
+		counts[c.name()] = counts[c.name()] + 1
+	}
+
+	seen := map[string]int{}
+	for _, c := range n.Children {
+		p := path + "/" + c.name()
+		if counts[c.name()] > 1 {
+			seen[c.name()] = seen[c.name()] + 1
+			p = p + "[" + strconv.Itoa(seen[c.name()]) + "]"
+		}
+		c.walkUnused(p, paths)
+	}
+}
+
+// newXMLNode creates an element for writing. The name may contain a namespace
+// prefix (ram:ID) which is written as is.
+func newXMLNode(name string, children ...*xmlNode) *xmlNode {
+	return &xmlNode{
+		XMLName:  xml.Name{Local: name},
+		Children: compactXMLNodes(children),
+	}
+}
+
+// newXMLText creates an element with text content and optional attribute
+// name/value pairs. Empty values result in a nil node so optional elements can
+// be built inline; attributes without a value are skipped.
+func newXMLText(name string, value string, attrs ...string) *xmlNode {
+	if value == "" {
+		return nil
+	}
+
+	n := &xmlNode{
+		XMLName: xml.Name{Local: name},
+		Content: value,
+	}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		if attrs[i+1] == "" {
+			continue
+		}
+		n.Attrs = append(n.Attrs, xml.Attr{Name: xml.Name{Local: attrs[i]}, Value: attrs[i+1]})
+	}
+	return n
+}
+
+// group returns nil for elements without children so empty optional groups
+// are left out
+func (n *xmlNode) group() *xmlNode {
+	if n == nil || len(n.Children) == 0 {
+		return nil
+	}
+	return n
+}
+
+// add appends the non nil children to the element
+func (n *xmlNode) add(children ...*xmlNode) *xmlNode {
+	n.Children = append(n.Children, compactXMLNodes(children)...)
+	return n
+}
+
+func compactXMLNodes(nodes []*xmlNode) []*xmlNode {
+	compact := []*xmlNode{}
+	for _, n := range nodes {
+		if n != nil {
+			compact = append(compact, n)
+		}
+	}
+	return compact
+}
+
+// marshal writes the element as an indented XML document
+func (n *xmlNode) marshal() ([]byte, error) {
+	b, err := xml.MarshalIndent(n, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}